@@ -3,11 +3,14 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
 	"symbol-quest/internal/config"
 	"symbol-quest/internal/database"
 	"symbol-quest/internal/handlers"
 	"symbol-quest/internal/middleware"
+	"symbol-quest/internal/prompts"
 	"symbol-quest/internal/services"
+	"symbol-quest/internal/tarot"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -29,26 +32,120 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// Load extra or replacement decks on top of the embedded ones
+	if cfg.DeckPath != "" {
+		if err := tarot.LoadDecks(cfg.DeckPath); err != nil {
+			log.Fatal("Failed to load decks:", err)
+		}
+	}
+
+	// Replace the built-in question matching lexicon
+	if cfg.LexiconPath != "" {
+		if err := tarot.LoadLexicon(cfg.LexiconPath); err != nil {
+			log.Fatal("Failed to load lexicon:", err)
+		}
+	}
+
+	// Replace or add prompt templates and personas
+	if cfg.PromptsPath != "" {
+		if err := prompts.Load(cfg.PromptsPath); err != nil {
+			log.Fatal("Failed to load prompts:", err)
+		}
+	}
+
 	authService := services.NewAuthService(db, cfg.JWTSecret)
+	authService.SetTokenTTLs(cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	mailer, err := services.NewMailer(cfg.Mailer, cfg.MailOutboxPath, services.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.MailFrom,
+	}, cfg.IsDevelopment())
+	if err != nil {
+		log.Fatal("Failed to configure mailer:", err)
+	}
+	authService.SetMailer(mailer, strings.TrimRight(cfg.AppURL, "/"))
+	loginPolicy := services.DefaultLoginPolicy
+	loginPolicy.AccountThreshold = cfg.LoginLockoutThreshold
+	loginPolicy.IPThreshold = cfg.LoginIPLockoutThreshold
+	loginPolicy.LockoutDuration = cfg.LoginLockoutDuration
+	authService.SetLoginPolicy(loginPolicy)
 	cardService := services.NewCardService(db)
-	openaiService := services.NewOpenAIService(cfg.OpenAIAPIKey)
+	cardService.SetReversalProbability(cfg.ReversalProbability)
+	if err := cardService.SetDefaultSelector(cfg.SelectionStrategy); err != nil {
+		log.Fatal("Invalid SELECTION_STRATEGY:", err)
+	}
+	moderationProvider, err := services.NewModerationProvider(cfg.ModerationProvider, cfg.OpenAIAPIKey)
+	if err != nil {
+		log.Fatal("Invalid MODERATION_PROVIDER:", err)
+	}
+	moderationService := services.NewModerationService(db, moderationProvider)
+	if cfg.ModerationBlocklistPath != "" {
+		blocklist, err := services.LoadBlocklist(cfg.ModerationBlocklistPath)
+		if err != nil {
+			log.Fatal("Failed to load MODERATION_BLOCKLIST_PATH:", err)
+		}
+		moderationService.SetBlocklist(blocklist)
+		log.Printf("Loaded %d blocklist entries", len(blocklist))
+	}
+	cardService.SetModeration(moderationService)
+	interpreter, err := services.NewInterpreter(services.InterpreterConfig{
+		Order:        strings.Split(cfg.Interpreters, ","),
+		OpenAIAPIKey: cfg.OpenAIAPIKey,
+		BaseURL:      cfg.InterpreterBaseURL,
+		APIKey:       cfg.InterpreterAPIKey,
+		Model:        cfg.InterpreterModel,
+		Timeout:      cfg.InterpreterTimeout,
+		Retry: services.RetryPolicy{
+			MaxRetries: cfg.InterpreterMaxRetries,
+			BaseDelay:  services.DefaultRetryPolicy.BaseDelay,
+			MaxDelay:   services.DefaultRetryPolicy.MaxDelay,
+		},
+		BreakerThreshold: cfg.InterpreterBreakerThreshold,
+		BreakerCooldown:  cfg.InterpreterBreakerCooldown,
+	})
+	if err != nil {
+		log.Fatal("Invalid INTERPRETERS:", err)
+	}
+	log.Printf("Interpreters: %s", interpreter.Name())
+	usageService := services.NewUsageService(db)
+	usageService.SetQuota("free", cfg.AIQuotaFreeTokens)
+	usageService.SetQuota("premium", cfg.AIQuotaPremiumTokens)
+	interpretationService := services.NewInterpretationService(db, interpreter)
+	interpretationService.SetRegenerationLimit(cfg.RegenerationsPerDay)
+	interpretationService.SetHistoryLimit(cfg.HistoryLimit)
+	interpretationService.SetUsage(usageService)
+	interpretationService.SetModeration(moderationService)
+	chatService := services.NewChatService(db, interpreter)
+	chatService.SetUsage(usageService)
+	chatService.SetModeration(moderationService)
+	spreadService := services.NewSpreadService(db, cardService)
 	stripeService := services.NewStripeService(cfg.StripeSecretKey)
 	stripeService.SetDatabase(db)
 	stripeService.SetWebhookSecret(cfg.StripeWebhookSecret)
+	entitlementService := services.NewEntitlementService(db, cfg.EntitlementCacheTTL)
+	stripeService.SetEntitlements(entitlementService)
 
 	authHandler := handlers.NewAuthHandler(authService)
-	cardHandler := handlers.NewCardHandler(cardService, openaiService)
+	cardHandler := handlers.NewCardHandler(cardService, interpretationService)
+	spreadHandler := handlers.NewSpreadHandler(spreadService)
+	chatHandler := handlers.NewChatHandler(chatService)
+	usageHandler := handlers.NewUsageHandler(usageService)
+	moderationHandler := handlers.NewModerationHandler(moderationService)
 	subscriptionHandler := handlers.NewSubscriptionHandler(stripeService)
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: middleware.ErrorHandler,
+		// Behind a proxy, login throttling needs the client's address
+		ProxyHeader: cfg.ProxyHeader,
 	})
 
 	app.Use(logger.New())
 	app.Use(helmet.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: cfg.CORSOrigins,
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Timezone",
 		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
 	}))
 
@@ -58,26 +155,61 @@ func main() {
 	auth := api.Group("/auth")
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/refresh", authHandler.Refresh)
 	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/forgot", authHandler.ForgotPassword)
+	auth.Post("/reset", authHandler.ResetPassword)
+	auth.Post("/verify", authHandler.VerifyEmail)
+	auth.Post("/verify/resend", middleware.AuthRequired(authService), authHandler.ResendVerification)
+	auth.Post("/unlock", authHandler.UnlockAccount)
+	auth.Post("/mfa/login", authHandler.CompleteMFALogin)
+	auth.Post("/mfa/setup", middleware.AuthRequired(authService), authHandler.SetupMFA)
+	auth.Post("/mfa/enable", middleware.AuthRequired(authService), authHandler.EnableMFA)
+	auth.Post("/mfa/disable", middleware.AuthRequired(authService), authHandler.DisableMFA)
 	auth.Get("/profile", middleware.AuthRequired(authService), authHandler.Profile)
+	auth.Put("/preferences", middleware.AuthRequired(authService), authHandler.UpdatePreferences)
 
 	// Card draw routes
 	draws := api.Group("/draws", middleware.AuthRequired(authService))
 	draws.Post("/daily", cardHandler.DailyDraw)
 	draws.Get("/history", cardHandler.History)
 	draws.Get("/today", cardHandler.TodayStatus)
+	draws.Get("/chats", chatHandler.List)
+	draws.Get("/:id/verify", cardHandler.VerifyDraw)
+	draws.Get("/:id/explain", cardHandler.ExplainDraw)
+	draws.Get("/:id/chat", chatHandler.Thread)
+	draws.Post("/:id/chat", middleware.VerifiedRequired(authService), chatHandler.Ask)
+
+	// Spread routes
+	spreads := api.Group("/spreads", middleware.AuthRequired(authService))
+	spreads.Get("/", spreadHandler.List)
+	spreads.Post("/", middleware.PremiumRequired(entitlementService), spreadHandler.Create)
+	spreads.Delete("/:type", middleware.PremiumRequired(entitlementService), spreadHandler.Delete)
+	spreads.Post("/:type/draw", spreadHandler.Draw)
+	spreads.Get("/readings/:id/verify", spreadHandler.VerifyReading)
 
 	// Interpretation routes
 	interpretations := api.Group("/interpretations", middleware.AuthRequired(authService))
-	interpretations.Post("/enhanced", middleware.PremiumRequired(), cardHandler.EnhancedInterpretation)
+	interpretations.Post("/enhanced", middleware.PremiumRequired(entitlementService), cardHandler.EnhancedInterpretation)
+	interpretations.Get("/enhanced/stream", middleware.PremiumRequired(entitlementService), cardHandler.StreamEnhancedInterpretation)
 
 	// Card info routes
 	cards := api.Group("/cards")
 	cards.Get("/:id/meaning", cardHandler.BasicMeaning)
+	api.Get("/decks", cardHandler.Decks)
+	api.Get("/selectors", cardHandler.Selectors)
+	api.Get("/personas", cardHandler.Personas)
+
+	// AI usage routes
+	api.Get("/usage", middleware.AuthRequired(authService), usageHandler.Summary)
+	admin := api.Group("/admin", middleware.AuthRequired(authService), middleware.AdminRequired(strings.Split(cfg.AdminEmails, ",")))
+	admin.Get("/usage", usageHandler.Report)
+	admin.Get("/moderation", moderationHandler.List)
+	admin.Post("/moderation/:id", moderationHandler.Review)
 
 	// Subscription routes
 	subscriptions := api.Group("/subscriptions", middleware.AuthRequired(authService))
-	subscriptions.Post("/create", subscriptionHandler.Create)
+	subscriptions.Post("/create", middleware.VerifiedRequired(authService), subscriptionHandler.Create)
 	subscriptions.Get("/status", subscriptionHandler.Status)
 
 	// Webhook routes
@@ -2,9 +2,12 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
+	Environment      string
 	DatabaseURL      string
 	JWTSecret       string
 	OpenAIAPIKey    string
@@ -12,10 +15,53 @@ type Config struct {
 	StripeWebhookSecret string
 	CORSOrigins     string
 	Port           string
+	ReversalProbability float64
+	DeckPath        string
+	SelectionStrategy string
+	LexiconPath     string
+	Interpreters    string
+	InterpreterBaseURL string
+	InterpreterAPIKey  string
+	InterpreterModel   string
+	InterpreterTimeout time.Duration
+	InterpreterMaxRetries int
+	InterpreterBreakerThreshold int
+	InterpreterBreakerCooldown  time.Duration
+	RegenerationsPerDay int
+	HistoryLimit        int
+	AIQuotaFreeTokens    int
+	AIQuotaPremiumTokens int
+	AdminEmails          string
+	ModerationProvider      string
+	ModerationBlocklistPath string
+	AccessTokenTTL          time.Duration
+	RefreshTokenTTL         time.Duration
+	EntitlementCacheTTL     time.Duration
+	AppURL                  string
+	Mailer                  string
+	MailFrom                string
+	MailOutboxPath          string
+	SMTPHost                string
+	SMTPPort                int
+	SMTPUsername            string
+	SMTPPassword            string
+	LoginLockoutThreshold   int
+	LoginIPLockoutThreshold int
+	LoginLockoutDuration    time.Duration
+	ProxyHeader             string
+	PromptsPath        string
 }
 
+// Environments accepted in APP_ENV. Anything but development is treated
+// as production.
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+)
+
 func Load() *Config {
 	return &Config{
+		Environment:      getEnv("APP_ENV", EnvironmentProduction),
 		DatabaseURL:      getEnv("DATABASE_URL", "postgres://localhost/symbol_quest?sslmode=disable"),
 		JWTSecret:       getEnv("JWT_SECRET", "your-256-bit-secret"),
 		OpenAIAPIKey:    getEnv("OPENAI_API_KEY", ""),
@@ -23,12 +69,81 @@ func Load() *Config {
 		StripeWebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
 		CORSOrigins:     getEnv("CORS_ORIGINS", "http://localhost:5173,https://symbol-quest.vercel.app"),
 		Port:           getEnv("PORT", "8080"),
+		ReversalProbability: getEnvFloat("REVERSAL_PROBABILITY", 0.25),
+		DeckPath:        getEnv("DECK_PATH", ""),
+		SelectionStrategy: getEnv("SELECTION_STRATEGY", "history"),
+		LexiconPath:     getEnv("LEXICON_PATH", ""),
+		Interpreters:    getEnv("INTERPRETERS", "openai,compatible,template"),
+		InterpreterBaseURL: getEnv("INTERPRETER_BASE_URL", ""),
+		InterpreterAPIKey:  getEnv("INTERPRETER_API_KEY", ""),
+		InterpreterModel:   getEnv("INTERPRETER_MODEL", "gpt-3.5-turbo"),
+		InterpreterTimeout: getEnvDuration("INTERPRETER_TIMEOUT", 30*time.Second),
+		InterpreterMaxRetries: getEnvInt("INTERPRETER_MAX_RETRIES", 2),
+		InterpreterBreakerThreshold: getEnvInt("INTERPRETER_BREAKER_THRESHOLD", 5),
+		InterpreterBreakerCooldown:  getEnvDuration("INTERPRETER_BREAKER_COOLDOWN", 30*time.Second),
+		RegenerationsPerDay: getEnvInt("REGENERATIONS_PER_DAY", 3),
+		HistoryLimit:        getEnvInt("HISTORY_LIMIT", 10),
+		AIQuotaFreeTokens:    getEnvInt("AI_QUOTA_FREE_TOKENS", 20000),
+		AIQuotaPremiumTokens: getEnvInt("AI_QUOTA_PREMIUM_TOKENS", 500000),
+		AdminEmails:          getEnv("ADMIN_EMAILS", ""),
+		ModerationProvider:      getEnv("MODERATION_PROVIDER", "openai"),
+		ModerationBlocklistPath: getEnv("MODERATION_BLOCKLIST_PATH", ""),
+		AccessTokenTTL:          getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:         getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		EntitlementCacheTTL:     getEnvDuration("ENTITLEMENT_CACHE_TTL", 30*time.Second),
+		AppURL:                  getEnv("APP_URL", "http://localhost:5173"),
+		Mailer:                  getEnv("MAILER", "log"),
+		MailFrom:                getEnv("MAIL_FROM", "Symbol Quest <no-reply@symbol-quest.app>"),
+		MailOutboxPath:          getEnv("MAIL_OUTBOX_PATH", ""),
+		SMTPHost:                getEnv("SMTP_HOST", ""),
+		SMTPPort:                getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:            getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:            getEnv("SMTP_PASSWORD", ""),
+		LoginLockoutThreshold:   getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginIPLockoutThreshold: getEnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		LoginLockoutDuration:    getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		ProxyHeader:             getEnv("PROXY_HEADER", ""),
+		PromptsPath:        getEnv("PROMPTS_PATH", ""),
 	}
 }
 
+// IsDevelopment reports whether the server runs on a developer's machine,
+// where mail may be logged instead of delivered.
+func (c *Config) IsDevelopment() bool {
+	return c.Environment == EnvironmentDevelopment
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration reads a duration such as "30s" or "1m30s".
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -68,6 +68,211 @@ func RunMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_card_draws_user_date ON card_draws(user_id, draw_date);`,
 		`CREATE INDEX IF NOT EXISTS idx_daily_usage_user_date ON daily_usage(user_id, usage_date);`,
 		`CREATE INDEX IF NOT EXISTS idx_subscriptions_user ON subscriptions(user_id);`,
+
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS orientation VARCHAR(10) NOT NULL DEFAULT 'upright';`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS reversals_enabled BOOLEAN NOT NULL DEFAULT TRUE;`,
+
+		`CREATE TABLE IF NOT EXISTS spread_readings (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			spread_type VARCHAR(50) NOT NULL,
+			spread_name VARCHAR(100) NOT NULL,
+			draw_date DATE NOT NULL,
+			mood VARCHAR(50),
+			question TEXT,
+			created_at TIMESTAMP DEFAULT NOW()
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS spread_cards (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			reading_id UUID NOT NULL REFERENCES spread_readings(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			position_name VARCHAR(100) NOT NULL,
+			card_id INTEGER NOT NULL,
+			card_name VARCHAR(100) NOT NULL,
+			orientation VARCHAR(10) NOT NULL DEFAULT 'upright',
+			interpretation_basic TEXT,
+			UNIQUE(reading_id, position),
+			UNIQUE(reading_id, card_id)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS custom_spreads (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			spread_type VARCHAR(50) NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			description TEXT,
+			positions JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE(user_id, spread_type)
+		);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_spread_readings_user_date ON spread_readings(user_id, draw_date);`,
+		`CREATE INDEX IF NOT EXISTS idx_custom_spreads_user ON custom_spreads(user_id);`,
+
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS deck VARCHAR(50) NOT NULL DEFAULT 'rider-waite';`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS deck VARCHAR(50) NOT NULL DEFAULT 'rider-waite';`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS selection_strategy VARCHAR(20) NOT NULL DEFAULT '';`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS selector VARCHAR(20) NOT NULL DEFAULT 'history';`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS selector VARCHAR(20) NOT NULL DEFAULT 'history';`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS seed BIGINT;`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS scope VARCHAR(10) NOT NULL DEFAULT 'full';`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS recent_cards JSONB;`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS reversal_probability DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS commitment VARCHAR(64);`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS seed BIGINT;`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS scope VARCHAR(10) NOT NULL DEFAULT 'full';`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS recent_cards JSONB;`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS reversal_probability DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS positions JSONB;`,
+		`ALTER TABLE spread_readings ADD COLUMN IF NOT EXISTS commitment VARCHAR(64);`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS explanation JSONB;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';`,
+
+		// One daily draw per user and date. Duplicates left by earlier
+		// concurrent requests are kept but moved out of the 'daily' kind
+		// before the unique index is built.
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'daily';`,
+		`UPDATE card_draws SET kind = 'duplicate-' || ranked.rn
+		 FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, draw_date ORDER BY created_at, id) AS rn
+			FROM card_draws
+			WHERE kind = 'daily'
+		 ) ranked
+		 WHERE card_draws.id = ranked.id AND ranked.rn > 1;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_card_draws_user_date_kind ON card_draws(user_id, draw_date, kind);`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS interpretation_provider VARCHAR(20);`,
+		`ALTER TABLE daily_usage ADD COLUMN IF NOT EXISTS regenerations_count INTEGER NOT NULL DEFAULT 0;`,
+
+		// Follow-up questions and answers about a draw
+		`CREATE TABLE IF NOT EXISTS reading_messages (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			draw_id UUID NOT NULL REFERENCES card_draws(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(10) NOT NULL,
+			content TEXT NOT NULL,
+			provider VARCHAR(20),
+			tokens INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_reading_messages_draw ON reading_messages(draw_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_reading_messages_user ON reading_messages(user_id, created_at);`,
+
+		// Persona and prompt template version behind each generated text
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS interpretation_persona VARCHAR(30);`,
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS interpretation_template VARCHAR(50);`,
+		`ALTER TABLE reading_messages ADD COLUMN IF NOT EXISTS template VARCHAR(50);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS history_in_readings BOOLEAN NOT NULL DEFAULT TRUE;`,
+
+		// One row per model call, for quotas and spend reports
+		`CREATE TABLE IF NOT EXISTS ai_usage (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			feature VARCHAR(20) NOT NULL,
+			provider VARCHAR(20) NOT NULL,
+			model VARCHAR(100) NOT NULL DEFAULT '',
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			estimated BOOLEAN NOT NULL DEFAULT FALSE,
+			latency_ms INTEGER NOT NULL DEFAULT 0,
+			cost_usd NUMERIC(12, 6) NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_usage_user ON ai_usage(user_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_usage_created ON ai_usage(created_at);`,
+
+		// Questions and answers held back or flagged by content screening
+		`CREATE TABLE IF NOT EXISTS moderation_flags (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			source VARCHAR(20) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			action VARCHAR(10) NOT NULL,
+			category VARCHAR(50) NOT NULL DEFAULT '',
+			reason TEXT NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			reviewed_by VARCHAR(255),
+			reviewed_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_moderation_flags_status ON moderation_flags(status, created_at);`,
+
+		// Rotating refresh tokens, one family per login
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id UUID NOT NULL,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id, expires_at);`,
+
+		// Email verification. The column is added with a default so that
+		// accounts created before verification existed count as verified;
+		// dropping the default leaves new accounts unverified.
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ DEFAULT NOW();`,
+		`ALTER TABLE users ALTER COLUMN email_verified_at DROP DEFAULT;`,
+
+		// Single-use tokens sent by email for password resets and verification
+		`CREATE TABLE IF NOT EXISTS email_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose VARCHAR(20) NOT NULL,
+			email VARCHAR(255) NOT NULL,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_email_tokens_user ON email_tokens(user_id, purpose, created_at);`,
+
+		// Failed logins per account (normalised email) and per IP address
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			scope VARCHAR(10) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			locked_until TIMESTAMPTZ,
+			PRIMARY KEY (scope, subject)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure ON login_attempts(last_failure_at);`,
+		`CREATE TABLE IF NOT EXISTS auth_audit_log (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			event VARCHAR(30) NOT NULL,
+			email VARCHAR(255) NOT NULL DEFAULT '',
+			ip VARCHAR(64) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_auth_audit_log_user ON auth_audit_log(user_id, created_at);`,
+
+		// TOTP two-factor authentication. enabled_at is NULL until the user
+		// confirms a code; last_counter is the last time step used.
+		`CREATE TABLE IF NOT EXISTS user_mfa (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret VARCHAR(64) NOT NULL,
+			enabled_at TIMESTAMPTZ,
+			last_counter BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash CHAR(64) NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id, code_hash);`,
+
+		// When the local day of a daily draw ends, in the timezone it was
+		// drawn in, so that changing timezone cannot start a new day early
+		`ALTER TABLE card_draws ADD COLUMN IF NOT EXISTS day_ends_at TIMESTAMPTZ;`,
 	}
 
 	for _, migration := range migrations {
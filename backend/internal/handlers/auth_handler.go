@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"symbol-quest/internal/models"
 	"symbol-quest/internal/services"
 
@@ -8,6 +9,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// MinPasswordLength is the shortest password accepted.
+const MinPasswordLength = 8
+
 type AuthHandler struct {
 	authService *services.AuthService
 }
@@ -19,96 +23,88 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req models.RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid request body",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
 	// Basic validation
 	if req.Email == "" || req.Password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Email and password are required",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Email and password are required")
 	}
 
-	if len(req.Password) < 8 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Password must be at least 8 characters long",
-		})
+	if len(req.Password) < MinPasswordLength {
+		return fiber.NewError(fiber.StatusBadRequest, "Password must be at least 8 characters long")
 	}
 
 	user, err := h.authService.Register(req.Email, req.Password)
 	if err != nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-			"error":   true,
-			"message": err.Error(),
-		})
+		return err
 	}
 
-	token, err := h.authService.GenerateToken(user.ID, user.Email, user.SubscriptionTier)
+	tokens, err := h.authService.StartSession(user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": "Failed to generate token",
-		})
+		return err
 	}
 
 	return c.JSON(models.AuthResponse{
-		Token: token,
-		User:  *user,
+		TokenResponse: *tokens,
+		User:          *user,
 	})
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req models.LoginRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid request body",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
 	// Basic validation
 	if req.Email == "" || req.Password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Email and password are required",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Email and password are required")
 	}
 
-	user, token, err := h.authService.Login(req.Email, req.Password)
+	user, tokens, challenge, err := h.authService.Login(req.Email, req.Password, c.IP())
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error":   true,
-			"message": err.Error(),
-		})
+		return err
+	}
+	if challenge != nil {
+		return c.JSON(challenge)
 	}
 
 	return c.JSON(models.AuthResponse{
-		Token: token,
-		User:  *user,
+		TokenResponse: *tokens,
+		User:          *user,
 	})
 }
 
+// Refresh exchanges a refresh token for a new access token and refresh
+// token. Each refresh token works once.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Refresh token is required")
+	}
+
+	tokens, err := h.authService.Refresh(req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokens)
+}
+
 func (h *AuthHandler) Profile(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
 	user, err := h.authService.GetUserByID(userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error":   true,
-			"message": "User not found",
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -116,11 +112,238 @@ func (h *AuthHandler) Profile(c *fiber.Ctx) error {
 	})
 }
 
+func (h *AuthHandler) UpdatePreferences(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req models.UpdatePreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	user, err := h.authService.UpdatePreferences(userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
+
+// Logout revokes the session of the given refresh token. The access token
+// stays valid until it expires, so clients should drop it as well.
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	// In JWT implementation, logout is typically handled client-side
-	// by removing the token. We could implement a token blacklist here
-	// if needed for enhanced security.
+	var req models.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.RefreshToken == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Refresh token is required")
+	}
+
+	if err := h.authService.Logout(req.RefreshToken); err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Logged out successfully",
 	})
-}
\ No newline at end of file
+}
+
+// ForgotPassword mails a password reset link. The response is the same
+// whether or not the address has an account.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req models.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Email == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Email is required")
+	}
+
+	if err := h.authService.RequestPasswordReset(req.Email); err != nil {
+		return fmt.Errorf("request password reset: %w", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "If an account exists for this email, a reset link is on its way",
+	})
+}
+
+// ResetPassword sets a new password with the token from a reset link and
+// signs the user out everywhere.
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Token == "" || req.Password == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Token and password are required")
+	}
+	if len(req.Password) < MinPasswordLength {
+		return fiber.NewError(fiber.StatusBadRequest, "Password must be at least 8 characters long")
+	}
+
+	if err := h.authService.ResetPassword(req.Token, req.Password); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password reset - please log in again",
+	})
+}
+
+// VerifyEmail confirms the user's address with the token from a
+// verification link.
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req models.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Token is required")
+	}
+
+	if err := h.authService.VerifyEmail(req.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email verified",
+	})
+}
+
+// ResendVerification mails the signed-in user a new verification link.
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.authService.SendVerification(userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "If your email is not yet verified, a new link is on its way",
+	})
+}
+
+// UnlockAccount lifts a lockout with the token from the link mailed when
+// the account was locked.
+func (h *AuthHandler) UnlockAccount(c *fiber.Ctx) error {
+	var req models.UnlockAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Token is required")
+	}
+
+	if err := h.authService.UnlockAccount(req.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Account unlocked",
+	})
+}
+
+// CompleteMFALogin is the second login step for users with two-factor
+// authentication: the token from Login plus a code from their app or a
+// recovery code.
+func (h *AuthHandler) CompleteMFALogin(c *fiber.Ctx) error {
+	var req models.MFALoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.MFAToken == "" || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "MFA token and code are required")
+	}
+
+	user, tokens, err := h.authService.CompleteMFALogin(req.MFAToken, req.Code, c.IP())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(models.AuthResponse{
+		TokenResponse: *tokens,
+		User:          *user,
+	})
+}
+
+// SetupMFA starts two-factor enrolment and returns the secret to add to an
+// authenticator app.
+func (h *AuthHandler) SetupMFA(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	setup, err := h.authService.SetupMFA(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(setup)
+}
+
+// EnableMFA finishes enrolment with a code from the app. The recovery codes
+// in the response are not shown again.
+func (h *AuthHandler) EnableMFA(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req models.MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Code is required")
+	}
+
+	codes, err := h.authService.EnableMFA(userID, req.Code)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"mfa_enabled":    true,
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA turns two-factor authentication off after the user confirms
+// their password and a code.
+func (h *AuthHandler) DisableMFA(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req models.DisableMFARequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Password == "" || req.Code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Password and code are required")
+	}
+
+	if err := h.authService.DisableMFA(userID, req.Password, req.Code, c.IP()); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"mfa_enabled": false,
+	})
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"io"
 	"net/http/httptest"
+	"symbol-quest/internal/middleware"
 	"symbol-quest/internal/services"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 func TestAuthHandler_Creation(t *testing.T) {
@@ -184,22 +186,103 @@ func TestAuthHandler_Logout(t *testing.T) {
 	
 	app := fiber.New()
 	app.Post("/logout", handler.Logout)
+	app.Post("/refresh", handler.Refresh)
 
-	req := httptest.NewRequest("POST", "/logout", nil)
-	resp, err := app.Test(req)
-	if err != nil {
-		t.Fatalf("Request failed: %v", err)
+	for _, path := range []string{"/logout", "/refresh"} {
+		t.Run("MissingRefreshToken"+path, func(t *testing.T) {
+			req := httptest.NewRequest("POST", path, bytes.NewBufferString("{}"))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			bodyStr := string(body)
+			
+			if !contains(bodyStr, "Refresh token is required") {
+				t.Errorf("Expected refresh token error message, got: %s", bodyStr)
+			}
+		})
 	}
+}
 
-	if resp.StatusCode != fiber.StatusOK {
-		t.Errorf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+func TestAuthHandler_EmailLinks_Validation(t *testing.T) {
+	handler := NewAuthHandler(&services.AuthService{})
+
+	app := fiber.New()
+	app.Post("/forgot", handler.ForgotPassword)
+	app.Post("/reset", handler.ResetPassword)
+	app.Post("/verify", handler.VerifyEmail)
+	app.Post("/unlock", handler.UnlockAccount)
+
+	tests := []struct {
+		path    string
+		body    string
+		message string
+	}{
+		{"/forgot", `{}`, "Email is required"},
+		{"/reset", `{"password": "validpassword123"}`, "Token and password are required"},
+		{"/reset", `{"token": "abc", "password": "short"}`, "Password must be at least 8 characters long"},
+		{"/verify", `{}`, "Token is required"},
+		{"/verify", `invalid json`, "Invalid request body"},
+		{"/unlock", `{}`, "Token is required"},
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-	bodyStr := string(body)
-	
-	if !contains(bodyStr, "Logged out successfully") {
-		t.Errorf("Expected logout success message, got: %s", bodyStr)
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusBadRequest || !contains(string(body), test.message) {
+			t.Errorf("%s %s: expected 400 %q, got %d %s", test.path, test.body, test.message, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestAuthHandler_MFA_Validation(t *testing.T) {
+	handler := NewAuthHandler(&services.AuthService{})
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", uuid.New().String())
+		return c.Next()
+	})
+	app.Post("/mfa/login", handler.CompleteMFALogin)
+	app.Post("/mfa/enable", handler.EnableMFA)
+	app.Post("/mfa/disable", handler.DisableMFA)
+
+	tests := []struct {
+		path    string
+		body    string
+		message string
+	}{
+		{"/mfa/login", `{"mfa_token": "abc"}`, "MFA token and code are required"},
+		{"/mfa/enable", `{}`, "Code is required"},
+		{"/mfa/disable", `{"code": "123456"}`, "Password and code are required"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusBadRequest || !contains(string(body), test.message) {
+			t.Errorf("%s %s: expected 400 %q, got %d %s", test.path, test.body, test.message, resp.StatusCode, body)
+		}
 	}
 }
 
@@ -232,6 +315,51 @@ func TestAuthHandler_Profile_Validation(t *testing.T) {
 	}
 }
 
+func TestAuthHandler_UpdatePreferences_Validation(t *testing.T) {
+	mockAuthService := &services.AuthService{}
+	handler := NewAuthHandler(mockAuthService)
+
+	t.Run("InvalidUserID", func(t *testing.T) {
+		app := fiber.New()
+		app.Put("/preferences", func(c *fiber.Ctx) error {
+			c.Locals("user_id", "invalid-uuid")
+			return handler.UpdatePreferences(c)
+		})
+
+		req := httptest.NewRequest("PUT", "/preferences", bytes.NewBufferString(`{"reversals_enabled": false}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d for invalid UUID, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		app := fiber.New()
+		app.Put("/preferences", func(c *fiber.Ctx) error {
+			c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+			return handler.UpdatePreferences(c)
+		})
+
+		req := httptest.NewRequest("PUT", "/preferences", bytes.NewBufferString("invalid json"))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
+
 func TestRequestValidation(t *testing.T) {
 	// Test various request validation scenarios
 	
@@ -283,7 +411,9 @@ func TestJSONResponseStructure(t *testing.T) {
 	mockAuthService := &services.AuthService{}
 	handler := NewAuthHandler(mockAuthService)
 	
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
 	app.Post("/register", handler.Register)
 
 	// Test that error responses have consistent structure
@@ -321,6 +451,10 @@ func TestJSONResponseStructure(t *testing.T) {
 	if response["error"] != true {
 		t.Error("Error response 'error' field should be true")
 	}
+
+	if response["code"] != "invalid_request" {
+		t.Errorf("Expected code 'invalid_request', got %v", response["code"])
+	}
 }
 
 // Helper function to check if string contains substring
@@ -1,23 +1,34 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"symbol-quest/internal/middleware"
 	"symbol-quest/internal/models"
+	"symbol-quest/internal/prompts"
 	"symbol-quest/internal/services"
+	"symbol-quest/internal/tarot"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+// TimezoneHeader carries the client's IANA timezone. It sets the daily
+// boundary for users who have not stored a timezone in their preferences.
+const TimezoneHeader = "X-Timezone"
+
 type CardHandler struct {
-	cardService   *services.CardService
-	openaiService *services.OpenAIService
+	cardService     *services.CardService
+	interpretations *services.InterpretationService
 }
 
-func NewCardHandler(cardService *services.CardService, openaiService *services.OpenAIService) *CardHandler {
+func NewCardHandler(cardService *services.CardService, interpretations *services.InterpretationService) *CardHandler {
 	return &CardHandler{
-		cardService:   cardService,
-		openaiService: openaiService,
+		cardService:     cardService,
+		interpretations: interpretations,
 	}
 }
 
@@ -25,10 +36,7 @@ func (h *CardHandler) DailyDraw(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
 	var req models.DailyDrawRequest
@@ -36,33 +44,30 @@ func (h *CardHandler) DailyDraw(c *fiber.Ctx) error {
 		// If body parsing fails, continue with empty mood/question
 		req.Mood = ""
 		req.Question = ""
+		req.Deck = ""
+		req.Scope = ""
+		req.Strategy = ""
 	}
 
-	draw, err := h.cardService.PerformDailyDraw(userID, req.Mood, req.Question)
+	scope, err := tarot.ParseDeckScope(req.Scope)
 	if err != nil {
-		if err.Error() == "daily draw already completed" {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error":   true,
-				"message": "You have already drawn your card for today",
-				"card":    draw,
-			})
-		}
-		if err.Error() == "daily limit reached - upgrade to premium for unlimited draws" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error":   true,
-				"message": "Daily limit reached. Upgrade to premium for unlimited draws.",
-				"upgrade_required": true,
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": err.Error(),
+		return fiber.NewError(fiber.StatusBadRequest, "Scope must be either 'full' or 'major'")
+	}
+
+	result, err := h.cardService.PerformDailyDraw(c.UserContext(), userID, req.Mood, req.Question, req.Deck, req.Strategy, c.Get(TimezoneHeader), scope)
+	if err != nil {
+		return err
+	}
+
+	if result.AlreadyDrawn {
+		return services.ErrAlreadyDrawn.WithDetails(map[string]interface{}{
+			"card": result.Draw,
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
-		"card":    draw,
+		"card":    result.Draw,
 	})
 }
 
@@ -70,10 +75,7 @@ func (h *CardHandler) History(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
 	limitStr := c.Query("limit", "20")
@@ -84,10 +86,7 @@ func (h *CardHandler) History(c *fiber.Ctx) error {
 
 	draws, err := h.cardService.GetDrawHistory(userID, limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": "Failed to fetch draw history",
-		})
+		return fmt.Errorf("fetch draw history: %w", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -100,18 +99,12 @@ func (h *CardHandler) TodayStatus(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
-	status, err := h.cardService.GetTodayStatus(userID)
+	status, err := h.cardService.GetTodayStatus(userID, c.Get(TimezoneHeader))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": "Failed to get today's status",
-		})
+		return err
 	}
 
 	return c.JSON(status)
@@ -121,77 +114,231 @@ func (h *CardHandler) BasicMeaning(c *fiber.Ctx) error {
 	cardIDStr := c.Params("id")
 	cardID, err := strconv.Atoi(cardIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid card ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid card ID")
 	}
 
-	card, err := h.cardService.GetCardMeaning(cardID)
+	orientation, err := tarot.ParseOrientation(c.Query("orientation"))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error":   true,
-			"message": "Card not found",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Orientation must be either 'upright' or 'reversed'")
+	}
+
+	deckName := c.Query("deck", tarot.DefaultDeckName)
+	card, err := h.cardService.GetCardMeaning(deckName, cardID)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(fiber.Map{
 		"card": models.TarotCard{
 			ID:           card.ID,
 			Name:         card.Name,
+			Deck:         deckName,
+			Orientation:  string(orientation),
+			Arcana:       card.Arcana,
+			Suit:         card.Suit,
+			Rank:         card.Rank,
 			Keywords:     card.Keywords,
 			Element:      card.Elements[0], // Take first element
 			Astrology:    card.Astrology,
-			BasicMeaning: card.TraditionalMeaning,
+			BasicMeaning: card.Meaning(orientation),
 		},
 	})
 }
 
+// EnhancedInterpretation reads the user's daily draw on draw_date. The
+// card, orientation, mood and question come from the stored draw, not the
+// request.
 func (h *CardHandler) EnhancedInterpretation(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
 	var req struct {
-		CardID   int    `json:"card_id"`
-		Mood     string `json:"mood"`
-		Question string `json:"question"`
-		DrawDate string `json:"draw_date"`
+		DrawDate   string `json:"draw_date"`
+		Persona    string `json:"persona"`
+		Regenerate bool   `json:"regenerate"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid request body",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
-	// Generate enhanced interpretation using OpenAI
-	interpretation, err := h.openaiService.GenerateEnhancedInterpretation(
-		req.CardID, req.Mood, req.Question,
-	)
+	reading, deck, err := h.drawRequest(userID, req.DrawDate, req.Persona)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": "Failed to generate enhanced interpretation: " + err.Error(),
-		})
+		return err
+	}
+
+	// Serve the stored interpretation, or generate and save a new one
+	interpretation, err := h.interpretations.Interpret(c.UserContext(), userID, reading, services.InterpretationOptions{
+		DrawDate:   req.DrawDate,
+		Deck:       deck,
+		Regenerate: req.Regenerate,
+		Timezone:   c.Get(TimezoneHeader),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(interpretation)
+}
+
+// drawRequest loads the reading request for the user's draw on drawDate.
+func (h *CardHandler) drawRequest(userID uuid.UUID, drawDate, persona string) (services.InterpretationRequest, string, error) {
+	if drawDate == "" {
+		return services.InterpretationRequest{}, "", fiber.NewError(fiber.StatusBadRequest, "Draw date is required")
 	}
 
-	// Save the enhanced interpretation to the database
-	if req.DrawDate != "" {
-		err = h.cardService.SaveEnhancedInterpretation(userID, req.DrawDate, interpretation)
+	reading, deck, err := h.interpretations.DrawRequest(userID, drawDate)
+	if err != nil {
+		return services.InterpretationRequest{}, "", err
+	}
+	reading.Persona = persona
+	return reading, deck, nil
+}
+
+// StreamEnhancedInterpretation relays an enhanced interpretation as
+// Server-Sent Events: "token" events carry text as it is written, then a
+// single "done" or "error" event ends the stream. The draw_date, persona
+// and regenerate options come from the query string and, as for
+// EnhancedInterpretation, the card comes from the stored draw. Like every
+// authenticated route it needs the
+// Authorization header, which EventSource cannot send, so clients read
+// the stream from a fetch response instead. Stored and saved readings
+// work as for EnhancedInterpretation.
+func (h *CardHandler) StreamEnhancedInterpretation(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	drawDate := c.Query("draw_date")
+	req, deck, err := h.drawRequest(userID, drawDate, c.Query("persona"))
+	if err != nil {
+		return err
+	}
+	opts := services.InterpretationOptions{
+		DrawDate:   drawDate,
+		Deck:       deck,
+		Regenerate: c.QueryBool("regenerate"),
+		Timezone:   c.Get(TimezoneHeader),
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The writer runs after the handler returns, so it must not touch c.
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		interpretation, err := h.interpretations.InterpretStream(ctx, userID, req, opts, func(token string) error {
+			// A failed flush means the client has gone away
+			if err := writeEvent(w, "token", fiber.Map{"text": token}); err != nil {
+				cancel()
+				return err
+			}
+			return nil
+		})
 		if err != nil {
-			// Log the error but don't fail the request
-			// The user still gets their interpretation
+			if ctx.Err() == nil {
+				_, body := middleware.ErrorResponse(err)
+				writeEvent(w, "error", body)
+			}
+			return
 		}
+
+		writeEvent(w, "done", interpretation)
+	})
+
+	return nil
+}
+
+// writeEvent writes one Server-Sent Event with a JSON payload and flushes
+// it to the client.
+func writeEvent(w *bufio.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (h *CardHandler) Decks(c *fiber.Ctx) error {
+	var decks []fiber.Map
+	for _, deck := range tarot.Decks() {
+		decks = append(decks, fiber.Map{
+			"name":        deck.Name,
+			"title":       deck.Title,
+			"description": deck.Description,
+			"card_count":  len(deck.Cards),
+		})
 	}
 
 	return c.JSON(fiber.Map{
-		"interpretation": interpretation,
+		"decks":   decks,
+		"default": tarot.DefaultDeckName,
 	})
-}
\ No newline at end of file
+}
+
+func (h *CardHandler) Selectors(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"selectors": tarot.SelectorNames(),
+		"default":   h.cardService.DefaultSelector(),
+	})
+}
+
+func (h *CardHandler) Personas(c *fiber.Ctx) error {
+	defaultPersona, _ := prompts.GetPersona("")
+	return c.JSON(fiber.Map{
+		"personas": prompts.Personas(),
+		"default":  defaultPersona.Name,
+	})
+}
+
+func (h *CardHandler) VerifyDraw(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	drawID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid draw ID")
+	}
+
+	verification, err := h.cardService.VerifyDraw(userID, drawID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(verification)
+}
+
+func (h *CardHandler) ExplainDraw(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	drawID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid draw ID")
+	}
+
+	explanation, err := h.cardService.ExplainDraw(userID, drawID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(explanation)
+}
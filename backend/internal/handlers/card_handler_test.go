@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"symbol-quest/internal/middleware"
+	"symbol-quest/internal/services"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCardHandler_EnhancedInterpretation(t *testing.T) {
+	handler := NewCardHandler(services.NewCardService(nil), services.NewInterpretationService(nil, services.NewTemplateInterpreter()))
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Post("/interpretations/enhanced", func(c *fiber.Ctx) error {
+		c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+		return handler.EnhancedInterpretation(c)
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		// A card named by the client is not a draw
+		{"MissingDrawDate", `{"card_id": 16, "orientation": "reversed"}`, fiber.StatusBadRequest},
+		{"InvalidDrawDate", `{"draw_date": "yesterday"}`, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/interpretations/enhanced", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestCardHandler_StreamEnhancedInterpretation(t *testing.T) {
+	handler := NewCardHandler(services.NewCardService(nil), services.NewInterpretationService(nil, services.NewTemplateInterpreter()))
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Get("/interpretations/enhanced/stream", func(c *fiber.Ctx) error {
+		c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+		return handler.StreamEnhancedInterpretation(c)
+	})
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"MissingDrawDate", "card_id=16&orientation=reversed", fiber.StatusBadRequest},
+		{"InvalidDrawDate", "draw_date=yesterday", fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/interpretations/enhanced/stream?"+tt.query, nil)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/event-stream") {
+				t.Errorf("Expected no event stream, got %q", ct)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/services"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// MaxChatMessageLength is the longest follow-up question accepted, in
+// characters.
+const MaxChatMessageLength = 1000
+
+type ChatHandler struct {
+	chatService *services.ChatService
+}
+
+func NewChatHandler(chatService *services.ChatService) *ChatHandler {
+	return &ChatHandler{chatService: chatService}
+}
+
+func (h *ChatHandler) List(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	threads, err := h.chatService.ListThreads(userID)
+	if err != nil {
+		return fmt.Errorf("list chat threads: %w", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"threads": threads,
+		"count":   len(threads),
+	})
+}
+
+func (h *ChatHandler) Thread(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	drawID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid draw ID")
+	}
+
+	thread, err := h.chatService.GetThread(userID, drawID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(thread)
+}
+
+func (h *ChatHandler) Ask(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	drawID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid draw ID")
+	}
+
+	var req models.ChatMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Message is required")
+	}
+	if utf8.RuneCountInString(message) > MaxChatMessageLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Message must be at most %d characters", MaxChatMessageLength))
+	}
+
+	thread, err := h.chatService.Ask(c.UserContext(), userID, drawID, message)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(thread)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"symbol-quest/internal/middleware"
+	"symbol-quest/internal/services"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestChatHandler_AskValidation(t *testing.T) {
+	handler := NewChatHandler(services.NewChatService(nil, services.NewTemplateInterpreter()))
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Post("/draws/:id/chat", func(c *fiber.Ctx) error {
+		c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+		return handler.Ask(c)
+	})
+
+	tests := []struct {
+		name   string
+		drawID string
+		body   string
+	}{
+		{"InvalidDrawID", "not-a-uuid", `{"message": "What now?"}`},
+		{"EmptyMessage", "0b6f0e6e-2a11-4b8e-9d5b-7b0f4a4e4c1e", `{"message": "   "}`},
+		{"TooLong", "0b6f0e6e-2a11-4b8e-9d5b-7b0f4a4e4c1e", `{"message": "` + strings.Repeat("a", MaxChatMessageLength+1) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/draws/"+tt.drawID+"/chat", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Bounds of the review queue listing.
+const (
+	DefaultFlagLimit = 50
+	MaxFlagLimit     = 200
+)
+
+type ModerationHandler struct {
+	moderation *services.ModerationService
+}
+
+func NewModerationHandler(moderation *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{moderation: moderation}
+}
+
+// List shows the review queue, newest first. "status" picks pending
+// (the default), dismissed, confirmed or all items.
+func (h *ModerationHandler) List(c *fiber.Ctx) error {
+	status := c.Query("status", services.ReviewPending)
+	switch status {
+	case services.ReviewPending, services.ReviewDismissed, services.ReviewConfirmed:
+	case "all":
+		status = ""
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "Status must be 'pending', 'dismissed', 'confirmed' or 'all'")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(DefaultFlagLimit)))
+	if err != nil || limit <= 0 {
+		limit = DefaultFlagLimit
+	}
+	limit = min(limit, MaxFlagLimit)
+
+	flags, err := h.moderation.ListFlags(status, limit)
+	if err != nil {
+		return fmt.Errorf("list moderation flags: %w", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
+
+// Review records a decision on a queued item.
+func (h *ModerationHandler) Review(c *fiber.Ctx) error {
+	flagID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid flag ID")
+	}
+
+	var req models.ReviewModerationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.Status != services.ReviewDismissed && req.Status != services.ReviewConfirmed {
+		return fiber.NewError(fiber.StatusBadRequest, "Status must be either 'dismissed' or 'confirmed'")
+	}
+
+	reviewer, _ := c.Locals("user_email").(string)
+	if err := h.moderation.ReviewFlag(flagID, req.Status, reviewer); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"status":  req.Status,
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"symbol-quest/internal/middleware"
+	"symbol-quest/internal/services"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestModerationHandler_Validation(t *testing.T) {
+	handler := NewModerationHandler(services.NewModerationService(nil, nil))
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Get("/admin/moderation", handler.List)
+	app.Post("/admin/moderation/:id", handler.Review)
+
+	tests := map[string]struct {
+		method string
+		path   string
+		body   string
+	}{
+		"UnknownStatus": {"GET", "/admin/moderation?status=ignored", ""},
+		"InvalidID":     {"POST", "/admin/moderation/not-a-uuid", `{"status": "dismissed"}`},
+		"InvalidBody":   {"POST", "/admin/moderation/6f1c2a4e-0d7b-4b8e-9a51-3c2d1e0f9a87", `{"status":`},
+		"InvalidReview": {"POST", "/admin/moderation/6f1c2a4e-0d7b-4b8e-9a51-3c2d1e0f9a87", `{"status": "pending"}`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
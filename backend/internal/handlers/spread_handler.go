@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/services"
+	"symbol-quest/internal/tarot"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SpreadHandler struct {
+	spreadService *services.SpreadService
+}
+
+func NewSpreadHandler(spreadService *services.SpreadService) *SpreadHandler {
+	return &SpreadHandler{spreadService: spreadService}
+}
+
+func (h *SpreadHandler) List(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	spreads, err := h.spreadService.ListSpreads(userID)
+	if err != nil {
+		return fmt.Errorf("list spreads: %w", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"spreads": spreads,
+		"count":   len(spreads),
+	})
+}
+
+func (h *SpreadHandler) Draw(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req models.SpreadDrawRequest
+	if err := c.BodyParser(&req); err != nil {
+		// If body parsing fails, continue with empty mood/question
+		req = models.SpreadDrawRequest{}
+	}
+
+	scope, err := tarot.ParseDeckScope(req.Scope)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Scope must be either 'full' or 'major'")
+	}
+
+	reading, err := h.spreadService.DrawSpread(c.UserContext(), userID, c.Params("type"), req.Mood, req.Question, req.Deck, req.Strategy, c.Get(TimezoneHeader), scope)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"reading": reading,
+	})
+}
+
+func (h *SpreadHandler) Create(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req models.CreateSpreadRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	spread, err := h.spreadService.SaveCustomSpread(userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"spread": spread,
+	})
+}
+
+func (h *SpreadHandler) Delete(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	err = h.spreadService.DeleteCustomSpread(userID, c.Params("type"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Spread deleted",
+	})
+}
+
+func (h *SpreadHandler) VerifyReading(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	drawID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid reading ID")
+	}
+
+	verification, err := h.spreadService.VerifyReading(userID, drawID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(verification)
+}
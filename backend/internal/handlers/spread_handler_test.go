@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"symbol-quest/internal/services"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSpreadHandler_Draw_Validation(t *testing.T) {
+	handler := NewSpreadHandler(&services.SpreadService{})
+
+	t.Run("InvalidUserID", func(t *testing.T) {
+		app := fiber.New()
+		app.Post("/spreads/:type/draw", func(c *fiber.Ctx) error {
+			c.Locals("user_id", "invalid-uuid")
+			return handler.Draw(c)
+		})
+
+		req := httptest.NewRequest("POST", "/spreads/three-card/draw", nil)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	t.Run("InvalidScope", func(t *testing.T) {
+		app := fiber.New()
+		app.Post("/spreads/:type/draw", func(c *fiber.Ctx) error {
+			c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+			return handler.Draw(c)
+		})
+
+		req := httptest.NewRequest("POST", "/spreads/three-card/draw", bytes.NewBufferString(`{"scope": "tiny"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
+
+func TestSpreadHandler_Create_Validation(t *testing.T) {
+	handler := NewSpreadHandler(&services.SpreadService{})
+
+	app := fiber.New()
+	app.Post("/spreads", func(c *fiber.Ctx) error {
+		c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+		return handler.Create(c)
+	})
+
+	req := httptest.NewRequest("POST", "/spreads", bytes.NewBufferString("invalid json"))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestSpreadHandler_VerifyReading_Validation(t *testing.T) {
+	handler := NewSpreadHandler(&services.SpreadService{})
+
+	app := fiber.New()
+	app.Get("/spreads/readings/:id/verify", func(c *fiber.Ctx) error {
+		c.Locals("user_id", "7b0f4a4e-4b8e-4c1e-9d5b-0c6f0e6e2a11")
+		return handler.VerifyReading(c)
+	})
+
+	req := httptest.NewRequest("GET", "/spreads/readings/not-a-uuid/verify", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"io"
+	"errors"
+	"fmt"
 	"symbol-quest/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -20,26 +21,17 @@ func (h *SubscriptionHandler) Create(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
 	userEmail := c.Locals("user_email").(string)
 	if userEmail == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "User email not found",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "User email not found")
 	}
 
 	clientSecret, err := h.stripeService.CreateSubscription(userID, userEmail)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   true,
-			"message": "Failed to create subscription: " + err.Error(),
-		})
+		return fmt.Errorf("create subscription: %w", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -52,21 +44,20 @@ func (h *SubscriptionHandler) Status(c *fiber.Ctx) error {
 	userIDStr := c.Locals("user_id").(string)
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Invalid user ID",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
 	subscription, err := h.stripeService.GetSubscriptionStatus(userID)
-	if err != nil {
-		// No active subscription found
+	if errors.Is(err, services.ErrNoSubscription) {
 		return c.JSON(fiber.Map{
 			"subscription": nil,
 			"status":      "free",
 			"message":     "No active subscription",
 		})
 	}
+	if err != nil {
+		return err
+	}
 
 	return c.JSON(fiber.Map{
 		"subscription": subscription,
@@ -77,26 +68,13 @@ func (h *SubscriptionHandler) Status(c *fiber.Ctx) error {
 func (h *SubscriptionHandler) StripeWebhook(c *fiber.Ctx) error {
 	signature := c.Get("Stripe-Signature")
 	if signature == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Missing Stripe signature",
-		})
-	}
-
-	payload, err := io.ReadAll(c.Context().RequestBodyStream())
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Failed to read request body",
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Missing Stripe signature")
 	}
 
-	err = h.stripeService.HandleWebhook(payload, signature)
+	// The signature covers the raw body, so it must not be parsed first
+	err := h.stripeService.HandleWebhook(c.Body(), signature)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   true,
-			"message": "Webhook processing failed: " + err.Error(),
-		})
+		return fiber.NewError(fiber.StatusBadRequest, "Webhook processing failed: "+err.Error())
 	}
 
 	return c.JSON(fiber.Map{
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"symbol-quest/internal/database"
+	"symbol-quest/internal/middleware"
+	"symbol-quest/internal/services"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// signedEvent returns a Stripe event of the given type around object,
+// with the signature header Stripe would send.
+func signedEvent(t *testing.T, eventType string, object interface{}) ([]byte, string) {
+	t.Helper()
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		t.Fatalf("Failed to encode event object: %v", err)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          "evt_" + uuid.NewString(),
+		"object":      "event",
+		"api_version": stripe.APIVersion,
+		"type":        eventType,
+		"data":        map[string]json.RawMessage{"object": raw},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode event: %v", err)
+	}
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: testWebhookSecret})
+	return signed.Payload, signed.Header
+}
+
+func webhookApp(stripeService *services.StripeService) *fiber.App {
+	stripeService.SetWebhookSecret(testWebhookSecret)
+	handler := NewSubscriptionHandler(stripeService)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Post("/webhooks/stripe", handler.StripeWebhook)
+	return app
+}
+
+func postWebhook(t *testing.T, app *fiber.App, payload []byte, signature string) int {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/webhooks/stripe", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signature)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestSubscriptionHandler_StripeWebhook(t *testing.T) {
+	app := webhookApp(services.NewStripeService(""))
+
+	t.Run("SignedEvent", func(t *testing.T) {
+		payload, signature := signedEvent(t, "invoice.created", map[string]string{"id": "in_test", "object": "invoice"})
+		if status := postWebhook(t, app, payload, signature); status != fiber.StatusOK {
+			t.Errorf("Expected status %d, got %d", fiber.StatusOK, status)
+		}
+	})
+
+	t.Run("BadSignature", func(t *testing.T) {
+		payload, _ := signedEvent(t, "invoice.created", map[string]string{"id": "in_test", "object": "invoice"})
+		if status := postWebhook(t, app, payload, "t=1,v1=bad"); status != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("MissingSignature", func(t *testing.T) {
+		if status := postWebhook(t, app, []byte(`{}`), ""); status != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, status)
+		}
+	})
+}
+
+func TestSubscriptionHandler_StripeWebhookUpdatesTier(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" || testing.Short() {
+		t.Skip("Set TEST_DATABASE_URL to run database tests")
+	}
+	db, err := database.Connect(databaseURL)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer db.Close()
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+
+	userID := uuid.New()
+	if _, err := db.Exec("INSERT INTO users (id, email, password_hash) VALUES ($1, $2, 'x')", userID, userID.String()+"@example.com"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer db.Exec("DELETE FROM users WHERE id = $1", userID)
+
+	subscriptionID := "sub_" + uuid.NewString()
+	_, err = db.Exec(`
+		INSERT INTO subscriptions (user_id, stripe_subscription_id, stripe_customer_id, status)
+		VALUES ($1, $2, 'cus_test', 'incomplete')
+	`, userID, subscriptionID)
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+
+	entitlements := services.NewEntitlementService(db, time.Hour)
+	stripeService := services.NewStripeService("")
+	stripeService.SetDatabase(db)
+	stripeService.SetEntitlements(entitlements)
+	app := webhookApp(stripeService)
+
+	// Cache the free tier, which only the webhook can drop within the TTL
+	if premium, err := entitlements.IsPremium(userID); err != nil || premium {
+		t.Fatalf("Expected a free user, got %v, %v", premium, err)
+	}
+
+	payload, signature := signedEvent(t, "customer.subscription.updated", map[string]interface{}{
+		"id":       subscriptionID,
+		"object":   "subscription",
+		"status":   "active",
+		"customer": "cus_test",
+		"metadata": map[string]string{"user_id": userID.String()},
+	})
+	if status := postWebhook(t, app, payload, signature); status != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, status)
+	}
+
+	if premium, _ := entitlements.IsPremium(userID); !premium {
+		t.Error("Expected the webhook to upgrade the user and drop the cached tier")
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"symbol-quest/internal/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Bounds of the admin usage report, in days.
+const (
+	DefaultReportDays = 30
+	MaxReportDays     = 366
+)
+
+type UsageHandler struct {
+	usage *services.UsageService
+}
+
+func NewUsageHandler(usage *services.UsageService) *UsageHandler {
+	return &UsageHandler{usage: usage}
+}
+
+// Summary shows the user's AI usage this month and what is left of their
+// quota.
+func (h *UsageHandler) Summary(c *fiber.Ctx) error {
+	userIDStr := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	summary, err := h.usage.Summary(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(summary)
+}
+
+// Report totals AI spend by day and by user between the "from" and "to"
+// dates (YYYY-MM-DD, UTC, inclusive). It covers the last 30 days by
+// default.
+func (h *UsageHandler) Report(c *fiber.Ctx) error {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(services.DateLayout, value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "to must be a date in YYYY-MM-DD format")
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, 1-DefaultReportDays)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(services.DateLayout, value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "from must be a date in YYYY-MM-DD format")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return fiber.NewError(fiber.StatusBadRequest, "from must not be after to")
+	}
+	if to.Sub(from) >= MaxReportDays*24*time.Hour {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("The report can cover at most %d days", MaxReportDays))
+	}
+
+	report, err := h.usage.Report(from, to)
+	if err != nil {
+		return fmt.Errorf("usage report: %w", err)
+	}
+
+	return c.JSON(report)
+}
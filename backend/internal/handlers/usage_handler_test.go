@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"symbol-quest/internal/middleware"
+	"symbol-quest/internal/services"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUsageHandler_ReportValidation(t *testing.T) {
+	handler := NewUsageHandler(services.NewUsageService(nil))
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	app.Get("/admin/usage", handler.Report)
+
+	tests := map[string]string{
+		"InvalidFrom": "?from=March",
+		"InvalidTo":   "?to=2024-13-01",
+		"Reversed":    "?from=2024-03-02&to=2024-03-01",
+		"TooLong":     "?from=2023-01-01&to=2024-03-01",
+	}
+
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/admin/usage"+query, nil))
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -1,51 +1,147 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"symbol-quest/internal/services"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
+// CodeInternal is the code of any error that is not a domain error.
+const CodeInternal = "internal_error"
+
+// statusByCode maps every domain error code to its HTTP status.
+var statusByCode = map[string]int{
+	services.CodeNotFound:                  fiber.StatusNotFound,
+	services.CodeAlreadyDrawn:              fiber.StatusConflict,
+	services.CodeDailyLimit:                fiber.StatusForbidden,
+	services.CodeUserExists:                fiber.StatusConflict,
+	services.CodeInvalidCredentials:        fiber.StatusUnauthorized,
+	services.CodeInvalidToken:              fiber.StatusUnauthorized,
+	services.CodePremiumRequired:           fiber.StatusForbidden,
+	services.CodeUnknownDeck:               fiber.StatusBadRequest,
+	services.CodeUnknownSelector:           fiber.StatusBadRequest,
+	services.CodeUnknownPersona:            fiber.StatusBadRequest,
+	services.CodeInvalidTimezone:           fiber.StatusBadRequest,
+	services.CodeInvalidSpread:             fiber.StatusBadRequest,
+	services.CodeNotVerifiable:             fiber.StatusUnprocessableEntity,
+	services.CodeNoExplanation:             fiber.StatusNotFound,
+	services.CodeInterpretationUnavailable: fiber.StatusServiceUnavailable,
+	services.CodeRegenerationLimit:         fiber.StatusTooManyRequests,
+	services.CodeChatLimit:                 fiber.StatusForbidden,
+	services.CodeUsageQuota:                fiber.StatusTooManyRequests,
+	services.CodeAdminRequired:             fiber.StatusForbidden,
+	services.CodeQuestionTooLong:           fiber.StatusBadRequest,
+	services.CodeContentBlocked:            fiber.StatusUnprocessableEntity,
+	services.CodeCrisisSupport:             fiber.StatusUnprocessableEntity,
+	services.CodeContentWithheld:           fiber.StatusBadGateway,
+	services.CodeRefreshTokenReused:        fiber.StatusUnauthorized,
+	services.CodeInvalidLink:               fiber.StatusBadRequest,
+	services.CodeEmailNotVerified:          fiber.StatusForbidden,
+	services.CodeTooManyAttempts:           fiber.StatusTooManyRequests,
+	services.CodeAccountLocked:             fiber.StatusLocked,
+	services.CodeInvalidMFACode:            fiber.StatusUnauthorized,
+	services.CodeMFAAlreadyEnabled:         fiber.StatusConflict,
+	services.CodeMFANotEnabled:             fiber.StatusConflict,
+}
+
+// codeByStatus names the plain fiber errors that handlers return for
+// malformed requests.
+var codeByStatus = map[int]string{
+	fiber.StatusBadRequest:            "invalid_request",
+	fiber.StatusUnauthorized:          "unauthorized",
+	fiber.StatusForbidden:             "forbidden",
+	fiber.StatusNotFound:              "not_found",
+	fiber.StatusMethodNotAllowed:      "method_not_allowed",
+	fiber.StatusRequestEntityTooLarge: "request_too_large",
+	fiber.StatusTooManyRequests:       "rate_limited",
+	fiber.StatusServiceUnavailable:    "unavailable",
+}
+
+// ErrorHandler writes every error as {"error": true, "code", "message"}
+// and logs errors that are not expected.
 func ErrorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
+	status, body := ErrorResponse(err)
+	if body["code"] == CodeInternal {
+		log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+	}
+	if seconds, ok := body["retry_after"].(int); ok {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	}
+	return c.Status(status).JSON(body)
+}
+
+// ErrorResponse maps err to an HTTP status and error body. Domain errors
+// from the services package keep their code and message and add their
+// details; fiber errors get a code from their status; anything else is an
+// internal error whose details are not exposed.
+func ErrorResponse(err error) (int, fiber.Map) {
+	status := fiber.StatusInternalServerError
+	code := CodeInternal
 	message := "Internal Server Error"
+	var details map[string]interface{}
 
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
-		message = e.Message
+	var domainErr *services.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &domainErr):
+		if mapped, exists := statusByCode[domainErr.Code]; exists {
+			status = mapped
+		}
+		code = domainErr.Code
+		message = capitalize(err.Error())
+		details = domainErr.Details
+	case errors.As(err, &fiberErr):
+		status = fiberErr.Code
+		if mapped, exists := codeByStatus[status]; exists {
+			code = mapped
+		} else if status < fiber.StatusInternalServerError {
+			code = "error"
+		}
+		message = fiberErr.Message
 	}
 
-	return c.Status(code).JSON(fiber.Map{
+	body := fiber.Map{
 		"error":   true,
+		"code":    code,
 		"message": message,
-	})
+	}
+	for key, value := range details {
+		if _, reserved := body[key]; !reserved {
+			body[key] = value
+		}
+	}
+
+	return status, body
+}
+
+// capitalize turns a Go error string into a sentence for the response.
+func capitalize(message string) string {
+	if message == "" {
+		return message
+	}
+	return strings.ToUpper(message[:1]) + message[1:]
 }
 
 func AuthRequired(authService *services.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error":   true,
-				"message": "Authorization header required",
-			})
+			return ErrorHandler(c, fiber.NewError(fiber.StatusUnauthorized, "Authorization header required"))
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error":   true,
-				"message": "Bearer token required",
-			})
+			return ErrorHandler(c, fiber.NewError(fiber.StatusUnauthorized, "Bearer token required"))
 		}
 
 		claims, err := authService.ValidateToken(tokenString)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error":   true,
-				"message": "Invalid token",
-			})
+			return ErrorHandler(c, err)
 		}
 
 		c.Locals("user_id", claims["user_id"])
@@ -55,15 +151,64 @@ func AuthRequired(authService *services.AuthService) fiber.Handler {
 	}
 }
 
-func PremiumRequired() fiber.Handler {
+// AdminRequired lets through only users whose email is listed in admins.
+// It must run after AuthRequired.
+func AdminRequired(admins []string) fiber.Handler {
+	allowed := make(map[string]bool)
+	for _, email := range admins {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			allowed[email] = true
+		}
+	}
+
 	return func(c *fiber.Ctx) error {
-		subscriptionTier := c.Locals("subscription_tier")
-		if subscriptionTier == nil || subscriptionTier.(string) != "premium" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error":   true,
-				"message": "Premium subscription required",
-			})
+		email, _ := c.Locals("user_email").(string)
+		if !allowed[strings.ToLower(email)] {
+			return ErrorHandler(c, services.ErrAdminRequired)
 		}
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// PremiumRequired lets through only premium users. The tier is looked up
+// through entitlements rather than taken from the token, so upgrades and
+// cancellations apply straight away. It must run after AuthRequired.
+func PremiumRequired(entitlements *services.EntitlementService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userIDStr, _ := c.Locals("user_id").(string)
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return ErrorHandler(c, services.ErrPremiumRequired)
+		}
+
+		tier, err := entitlements.Tier(userID)
+		if err != nil {
+			return ErrorHandler(c, err)
+		}
+		if tier != "premium" {
+			return ErrorHandler(c, services.ErrPremiumRequired)
+		}
+		c.Locals("subscription_tier", tier)
+		return c.Next()
+	}
+}
+// VerifiedRequired lets through only users who have verified their email
+// address. It must run after AuthRequired.
+func VerifiedRequired(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userIDStr, _ := c.Locals("user_id").(string)
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return ErrorHandler(c, services.ErrEmailNotVerified)
+		}
+
+		verified, err := authService.EmailVerified(userID)
+		if err != nil {
+			return ErrorHandler(c, err)
+		}
+		if !verified {
+			return ErrorHandler(c, services.ErrEmailNotVerified)
+		}
+		return c.Next()
+	}
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http/httptest"
 	"symbol-quest/internal/services"
@@ -53,6 +56,107 @@ func TestErrorHandler(t *testing.T) {
 			t.Errorf("Expected status %d, got %d", fiber.StatusInternalServerError, resp.StatusCode)
 		}
 	})
+
+	t.Run("DomainError", func(t *testing.T) {
+		app.Get("/test-domain", func(c *fiber.Ctx) error {
+			return services.ErrAlreadyDrawn.WithDetails(map[string]interface{}{"card": "The Fool"})
+		})
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/test-domain", nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusConflict {
+			t.Errorf("Expected status %d, got %d", fiber.StatusConflict, resp.StatusCode)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Response is not valid JSON: %v", err)
+		}
+		if body["code"] != services.CodeAlreadyDrawn || body["card"] != "The Fool" {
+			t.Errorf("Expected already_drawn code with the card, got %v", body)
+		}
+		if body["message"] != "Daily draw already completed" {
+			t.Errorf("Unexpected message: %v", body["message"])
+		}
+	})
+
+	t.Run("WrappedDomainError", func(t *testing.T) {
+		app.Get("/test-wrapped", func(c *fiber.Ctx) error {
+			return fmt.Errorf("%w: %q is a built-in spread", services.ErrInvalidSpread, "three-card")
+		})
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/test-wrapped", nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		if !contains(string(body), `"code":"invalid_spread"`) || !contains(string(body), "built-in spread") {
+			t.Errorf("Expected invalid_spread code and detail, got: %s", body)
+		}
+	})
+
+	t.Run("UnexpectedError", func(t *testing.T) {
+		app.Get("/test-unexpected", func(c *fiber.Ctx) error {
+			return errors.New("pq: connection refused")
+		})
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/test-unexpected", nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", fiber.StatusInternalServerError, resp.StatusCode)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		if contains(string(body), "connection refused") || !contains(string(body), `"code":"internal_error"`) {
+			t.Errorf("Expected a generic internal error, got: %s", body)
+		}
+	})
+}
+
+func TestErrorHandler_RetryAfter(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/login", func(c *fiber.Ctx) error {
+		return services.ErrTooManyAttempts.WithDetails(map[string]interface{}{"retry_after": 8})
+	})
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/login", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get("Retry-After") != "8" {
+		t.Errorf("Expected 429 with Retry-After 8, got %d with %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestErrorCodesHaveStatuses(t *testing.T) {
+	codes := []string{
+		services.CodeNotFound, services.CodeAlreadyDrawn, services.CodeDailyLimit,
+		services.CodeUserExists, services.CodeInvalidCredentials, services.CodeInvalidToken,
+		services.CodePremiumRequired, services.CodeUnknownDeck, services.CodeUnknownSelector,
+		services.CodeInvalidTimezone, services.CodeInvalidSpread, services.CodeNotVerifiable,
+		services.CodeNoExplanation, services.CodeInterpretationUnavailable,
+		services.CodeUsageQuota, services.CodeAdminRequired,
+		services.CodeQuestionTooLong, services.CodeContentBlocked, services.CodeCrisisSupport, services.CodeContentWithheld,
+		services.CodeRefreshTokenReused, services.CodeInvalidLink, services.CodeEmailNotVerified,
+		services.CodeTooManyAttempts, services.CodeAccountLocked,
+		services.CodeInvalidMFACode, services.CodeMFAAlreadyEnabled, services.CodeMFANotEnabled,
+	}
+	for _, code := range codes {
+		if _, exists := statusByCode[code]; !exists {
+			t.Errorf("No HTTP status for error code %q", code)
+		}
+	}
 }
 
 func TestAuthRequired(t *testing.T) {
@@ -121,11 +225,27 @@ func TestAuthRequired(t *testing.T) {
 	})
 }
 
+// testEntitlements resolves tiers from a map instead of the database.
+func testEntitlements(tiers map[uuid.UUID]string) *services.EntitlementService {
+	entitlements := services.NewEntitlementService(nil, 0)
+	entitlements.SetLoader(func(userID uuid.UUID) (string, error) {
+		tier, exists := tiers[userID]
+		if !exists {
+			return "", services.ErrUserNotFound
+		}
+		return tier, nil
+	})
+	return entitlements
+}
+
 func TestPremiumRequired(t *testing.T) {
 	app := fiber.New()
+	freeUser, premiumUser := uuid.New(), uuid.New()
+	tiers := map[uuid.UUID]string{freeUser: "free", premiumUser: "premium"}
+	entitlements := testEntitlements(tiers)
 
 	// Set up test route with premium middleware
-	app.Get("/premium", PremiumRequired(), func(c *fiber.Ctx) error {
+	app.Get("/premium", PremiumRequired(entitlements), func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"message": "premium content"})
 	})
 
@@ -151,8 +271,9 @@ func TestPremiumRequired(t *testing.T) {
 
 	t.Run("FreeTier", func(t *testing.T) {
 		app.Get("/premium-test", func(c *fiber.Ctx) error {
+			c.Locals("user_id", freeUser.String())
 			c.Locals("subscription_tier", "free")
-			return PremiumRequired()(c)
+			return PremiumRequired(entitlements)(c)
 		}, func(c *fiber.Ctx) error {
 			return c.JSON(fiber.Map{"message": "should not reach here"})
 		})
@@ -167,21 +288,97 @@ func TestPremiumRequired(t *testing.T) {
 			t.Errorf("Expected status %d for free tier, got %d", fiber.StatusForbidden, resp.StatusCode)
 		}
 	})
+
+	// The token's tier is ignored in favour of the stored one
+	app.Get("/premium-token", func(c *fiber.Ctx) error {
+		c.Locals("user_id", c.Query("user"))
+		c.Locals("subscription_tier", c.Query("claim"))
+		return c.Next()
+	}, PremiumRequired(entitlements), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("subscription_tier").(string))
+	})
+
+	tests := []struct {
+		name   string
+		user   uuid.UUID
+		claim  string
+		status int
+	}{
+		{"Upgraded", freeUser, "free", fiber.StatusOK},
+		{"Cancelled", premiumUser, "premium", fiber.StatusForbidden},
+		{"UnknownUser", uuid.New(), "premium", fiber.StatusNotFound},
+	}
+	tiers[freeUser], tiers[premiumUser] = "premium", "free"
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/premium-token?user="+test.user.String()+"&claim="+test.claim, nil)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if resp.StatusCode != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestVerifiedRequired(t *testing.T) {
+	app := fiber.New()
+	app.Get("/verified", VerifiedRequired(services.NewAuthService(nil, "test-secret")), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/verified", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("Expected status %d without a user, got %d", fiber.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestAdminRequired(t *testing.T) {
+	app := fiber.New()
+	app.Get("/admin", func(c *fiber.Ctx) error {
+		c.Locals("user_email", c.Query("email"))
+		return c.Next()
+	}, AdminRequired([]string{" Ops@Example.com", ""}), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	tests := map[string]int{
+		"ops@example.com":  fiber.StatusOK,
+		"user@example.com": fiber.StatusForbidden,
+		"":                 fiber.StatusForbidden,
+	}
+	for email, want := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/admin?email="+email, nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("Email %q: expected status %d, got %d", email, want, resp.StatusCode)
+		}
+	}
 }
 
 func TestMiddlewareIntegration(t *testing.T) {
 	app := fiber.New()
+	userID := uuid.New()
+	entitlements := testEntitlements(map[uuid.UUID]string{userID: "premium"})
 
 	// Mock successful authentication by setting locals
 	app.Use("/api", func(c *fiber.Ctx) error {
 		// Simulate authenticated user
-		c.Locals("user_id", uuid.New().String())
+		c.Locals("user_id", userID.String())
 		c.Locals("user_email", "test@example.com")
 		c.Locals("subscription_tier", "premium")
 		return c.Next()
 	})
 
-	app.Get("/api/premium", PremiumRequired(), func(c *fiber.Ctx) error {
+	app.Get("/api/premium", PremiumRequired(entitlements), func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"message": "premium success"})
 	})
 
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 	"github.com/google/uuid"
 )
@@ -10,6 +11,12 @@ type User struct {
 	Email           string    `json:"email" db:"email"`
 	PasswordHash    string    `json:"-" db:"password_hash"`
 	SubscriptionTier string    `json:"subscription_tier" db:"subscription_tier"`
+	ReversalsEnabled bool      `json:"reversals_enabled" db:"reversals_enabled"`
+	SelectionStrategy string   `json:"selection_strategy,omitempty" db:"selection_strategy"`
+	Timezone        string    `json:"timezone,omitempty" db:"timezone"`
+	HistoryInReadings bool     `json:"history_in_readings" db:"history_in_readings"`
+	EmailVerified   bool      `json:"email_verified"`
+	MFAEnabled      bool      `json:"mfa_enabled"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -19,14 +26,175 @@ type CardDraw struct {
 	UserID                uuid.UUID `json:"user_id" db:"user_id"`
 	CardID                int       `json:"card_id" db:"card_id"`
 	CardName              string    `json:"card_name" db:"card_name"`
+	Deck                  string    `json:"deck" db:"deck"`
+	Orientation           string    `json:"orientation" db:"orientation"`
+	Selector              string    `json:"selector" db:"selector"`
+	Seed                  int64     `json:"seed,omitempty" db:"seed"`
+	Commitment            string    `json:"commitment,omitempty" db:"commitment"`
 	DrawDate              string    `json:"draw_date" db:"draw_date"`
 	InterpretationBasic   string    `json:"interpretation_basic" db:"interpretation_basic"`
 	InterpretationEnhanced string   `json:"interpretation_enhanced,omitempty" db:"interpretation_enhanced"`
+	InterpretationPersona  string   `json:"interpretation_persona,omitempty" db:"interpretation_persona"`
+	InterpretationTemplate string   `json:"interpretation_template,omitempty" db:"interpretation_template"`
 	Mood                  string    `json:"mood,omitempty" db:"mood"`
 	Question              string    `json:"question,omitempty" db:"question"`
 	CreatedAt             time.Time `json:"created_at" db:"created_at"`
 }
 
+// DailyDrawResult is the outcome of a daily draw. When the user has already
+// drawn today, Draw is that earlier draw and AlreadyDrawn is set.
+type DailyDrawResult struct {
+	Draw         *CardDraw `json:"card"`
+	AlreadyDrawn bool      `json:"already_drawn"`
+}
+
+type SpreadReading struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	UserID     uuid.UUID    `json:"user_id" db:"user_id"`
+	SpreadType string       `json:"spread_type" db:"spread_type"`
+	SpreadName string       `json:"spread_name" db:"spread_name"`
+	Deck       string       `json:"deck" db:"deck"`
+	Selector   string       `json:"selector" db:"selector"`
+	Seed       int64        `json:"seed,omitempty" db:"seed"`
+	Commitment string       `json:"commitment,omitempty" db:"commitment"`
+	DrawDate   string       `json:"draw_date" db:"draw_date"`
+	Mood       string       `json:"mood,omitempty" db:"mood"`
+	Question   string       `json:"question,omitempty" db:"question"`
+	Cards      []SpreadCard `json:"cards"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+type SpreadCard struct {
+	Position            int    `json:"position" db:"position"`
+	PositionName        string `json:"position_name" db:"position_name"`
+	PositionMeaning     string `json:"position_meaning"`
+	CardID              int    `json:"card_id" db:"card_id"`
+	CardName            string `json:"card_name" db:"card_name"`
+	Orientation         string `json:"orientation" db:"orientation"`
+	InterpretationBasic string `json:"interpretation_basic" db:"interpretation_basic"`
+}
+
+// DrawExplanation is the stored reasoning behind a selected card.
+type DrawExplanation struct {
+	DrawID      uuid.UUID       `json:"draw_id"`
+	CardID      int             `json:"card_id"`
+	CardName    string          `json:"card_name"`
+	Deck        string          `json:"deck"`
+	Selector    string          `json:"selector"`
+	Explanation json.RawMessage `json:"explanation"`
+}
+
+// DrawVerification is the result of re-deriving a stored draw or spread
+// reading from its seed and inputs.
+type DrawVerification struct {
+	DrawID            uuid.UUID      `json:"draw_id"`
+	Seed              int64          `json:"seed"`
+	Commitment        string         `json:"commitment"`
+	CommitmentMatches bool           `json:"commitment_matches"`
+	Cards             []VerifiedCard `json:"cards"`
+	Verified          bool           `json:"verified"`
+}
+
+type VerifiedCard struct {
+	Position           int    `json:"position"`
+	CardID             int    `json:"card_id"`
+	Orientation        string `json:"orientation"`
+	DerivedCardID      int    `json:"derived_card_id"`
+	DerivedOrientation string `json:"derived_orientation"`
+	Matches            bool   `json:"matches"`
+}
+
+// ChatMessage is one question or answer in a reading chat.
+type ChatMessage struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Role      string    `json:"role" db:"role"` // "user" or "assistant"
+	Content   string    `json:"content" db:"content"`
+	Provider  string    `json:"provider,omitempty" db:"provider"`
+	Template  string    `json:"template,omitempty" db:"template"`
+	Tokens    int       `json:"tokens" db:"tokens"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ChatThread is the follow-up conversation about one draw and how much of
+// the tier's allowance it has used.
+type ChatThread struct {
+	DrawID     uuid.UUID     `json:"draw_id"`
+	CardName   string        `json:"card_name"`
+	DrawDate   string        `json:"draw_date"`
+	Messages   []ChatMessage `json:"messages"`
+	TurnsUsed  int           `json:"turns_used"`
+	TurnLimit  int           `json:"turn_limit"`
+	TokensUsed int           `json:"tokens_used"`
+	TokenLimit int           `json:"token_limit"`
+}
+
+type ChatThreadSummary struct {
+	DrawID        uuid.UUID `json:"draw_id"`
+	CardName      string    `json:"card_name"`
+	DrawDate      string    `json:"draw_date"`
+	Turns         int       `json:"turns"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// UsageSummary is a user's AI usage this month against their quota.
+type UsageSummary struct {
+	Tier        string    `json:"tier"`
+	PeriodStart string    `json:"period_start"`
+	ResetsAt    time.Time `json:"resets_at"`
+	Requests    int       `json:"requests"`
+	TokensUsed  int       `json:"tokens_used"`
+	// TokenQuota and TokensRemaining are 0 when Unlimited is set.
+	TokenQuota      int  `json:"token_quota"`
+	TokensRemaining int  `json:"tokens_remaining"`
+	Unlimited       bool `json:"unlimited"`
+}
+
+// UsageTotals adds up model calls.
+type UsageTotals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+type DayUsage struct {
+	Date string `json:"date"`
+	UsageTotals
+}
+
+type UserUsage struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+	UsageTotals
+}
+
+// UsageReport is AI spend over a date range, by day and by user.
+type UsageReport struct {
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Total UsageTotals `json:"total"`
+	Days  []DayUsage  `json:"days"`
+	Users []UserUsage `json:"users"`
+}
+
+// ModerationFlag is a question or answer queued for review by content
+// screening.
+type ModerationFlag struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Email      string     `json:"email"`
+	Source     string     `json:"source" db:"source"`       // "draw", "spread", "interpretation" or "chat"
+	Direction  string     `json:"direction" db:"direction"` // "input" for user text, "output" for model answers
+	Action     string     `json:"action" db:"action"`       // "flag", "block" or "crisis"
+	Category   string     `json:"category" db:"category"`
+	Reason     string     `json:"reason" db:"reason"`
+	Content    string     `json:"content" db:"content"`
+	Status     string     `json:"status" db:"status"` // "pending", "dismissed" or "confirmed"
+	ReviewedBy string     `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
 type DailyUsage struct {
 	ID         uuid.UUID `json:"id" db:"id"`
 	UserID     uuid.UUID `json:"user_id" db:"user_id"`
@@ -57,19 +225,118 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type UpdatePreferencesRequest struct {
+	ReversalsEnabled  *bool   `json:"reversals_enabled,omitempty"`
+	SelectionStrategy *string `json:"selection_strategy,omitempty"` // "" resets to the server default
+	Timezone          *string `json:"timezone,omitempty"`           // IANA name such as "Australia/Sydney"; "" clears it
+	HistoryInReadings *bool   `json:"history_in_readings,omitempty"` // false keeps past draws out of AI readings
+}
+
+// TokenResponse is a new access token and the refresh token to get the
+// next one with.
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"` // when Token expires
+}
+
 type AuthResponse struct {
+	TokenResponse
+	User User `json:"user"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailRequest struct {
 	Token string `json:"token"`
-	User  User   `json:"user"`
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token"`
+}
+
+// MFAChallenge is the login response for users with two-factor
+// authentication. The token is exchanged with a code for a session.
+type MFAChallenge struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type MFASetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
 }
 
 type DailyDrawRequest struct {
 	Mood     string `json:"mood,omitempty"`
 	Question string `json:"question,omitempty"`
+	Deck     string `json:"deck,omitempty"`  // deck name, e.g. "rider-waite" (default) or "thoth"
+	Scope    string `json:"scope,omitempty"` // "full" (default) or "major"
+	Strategy string `json:"strategy,omitempty"` // "random", "weighted" or "history"; overrides the user's preference
+}
+
+type SpreadDrawRequest struct {
+	Mood     string `json:"mood,omitempty"`
+	Question string `json:"question,omitempty"`
+	Deck     string `json:"deck,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	Strategy string `json:"strategy,omitempty"`
+}
+
+type CreateSpreadRequest struct {
+	Type        string                  `json:"type,omitempty"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description,omitempty"`
+	Positions   []SpreadPositionRequest `json:"positions"`
+}
+
+type SpreadPositionRequest struct {
+	Name    string `json:"name"`
+	Meaning string `json:"meaning,omitempty"`
+}
+
+type ChatMessageRequest struct {
+	Message string `json:"message"`
+}
+
+type ReviewModerationRequest struct {
+	Status string `json:"status"` // "dismissed" or "confirmed"
 }
 
 type TarotCard struct {
 	ID           int      `json:"id"`
 	Name         string   `json:"name"`
+	Deck         string   `json:"deck"`
+	Orientation  string   `json:"orientation"`
+	Arcana       string   `json:"arcana"`
+	Suit         string   `json:"suit,omitempty"`
+	Rank         string   `json:"rank,omitempty"`
 	Description  string   `json:"description"`
 	Keywords     []string `json:"keywords"`
 	Element      string   `json:"element,omitempty"`
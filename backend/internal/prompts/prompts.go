@@ -0,0 +1,364 @@
+// Package prompts holds the versioned prompt templates and reader personas
+// used to ask a language model for a reading.
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"symbol-quest/internal/tarot"
+	"sync"
+	"text/template"
+)
+
+// Named templates every prompt template file must define.
+const (
+	sectionSystem   = "system"
+	sectionUser     = "user"
+	sectionFollowUp = "follow_up"
+)
+
+//go:embed templates/*.tmpl personas.json
+var embedded embed.FS
+
+// Persona is a reader voice with its own template and model settings.
+type Persona struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Template is the versioned template the persona uses, e.g. "reading.v1".
+	Template string `json:"template"`
+	// Model is used by the openai interpreter. Other interpreters keep
+	// their configured model.
+	Model       string  `json:"model,omitempty"`
+	Temperature float64 `json:"temperature"`
+	MaxTokens   int     `json:"max_tokens"`
+	// Voice describes the persona to the model and is available to
+	// templates as {{.Persona.Voice}}.
+	Voice string `json:"voice"`
+}
+
+type personaFile struct {
+	Default  string    `json:"default"`
+	Personas []Persona `json:"personas"`
+}
+
+// PastReading is one earlier draw, as shown to the model.
+type PastReading struct {
+	Date        string
+	Card        string
+	Orientation string
+	Mood        string
+}
+
+// CardCount is a card and how often it was drawn.
+type CardCount struct {
+	Card  string
+	Count int
+}
+
+// HistoryPatterns summarises a user's recent readings so that a reading can
+// refer to what keeps coming up. Empty fields mean nothing stood out.
+type HistoryPatterns struct {
+	// Readings is how many past readings the summary covers.
+	Readings int
+	// CurrentCard is how often the card being read appeared among them.
+	CurrentCard int
+	// RepeatedCards lists the cards drawn more than once, most frequent first.
+	RepeatedCards   []CardCount
+	DominantElement string
+	MajorArcana     int
+	// CommonMood is the mood the user reported most often, and MoodTrend
+	// whether their moods are "brightening", "darkening" or "steady".
+	CommonMood string
+	MoodTrend  string
+}
+
+// Data is what a template can refer to.
+type Data struct {
+	Card        tarot.Card
+	Orientation tarot.Orientation
+	Reversed    bool
+	// Meaning is the card's meaning in its orientation.
+	Meaning  string
+	Mood     string
+	Question string
+	History  []PastReading
+	// Patterns is nil when the user's history is not used.
+	Patterns *HistoryPatterns
+	Persona  Persona
+}
+
+// Prompt is a rendered prompt with the settings to send it with.
+type Prompt struct {
+	System   string
+	User     string
+	FollowUp string
+	// Version names the template that produced the prompt, e.g.
+	// "reading.v1", so that readings can be traced back to it.
+	Version     string
+	Persona     string
+	Model       string
+	Temperature float64
+	MaxTokens   int
+}
+
+// library is a loaded set of templates and personas.
+type library struct {
+	templates      map[string]*template.Template
+	personas       map[string]Persona
+	defaultPersona string
+}
+
+var (
+	libraryMu sync.RWMutex
+	current   *library
+)
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+}
+
+func init() {
+	loaded, err := load("")
+	if err != nil {
+		panic("prompts: invalid embedded prompts: " + err.Error())
+	}
+	current = loaded
+}
+
+// Load replaces the registered templates and personas with the embedded
+// ones plus any found in dir: "*.tmpl" files add or replace templates by
+// file name, and a "personas.json" file replaces the personas. It is meant
+// to be called once during startup.
+func Load(dir string) error {
+	loaded, err := load(dir)
+	if err != nil {
+		return err
+	}
+
+	libraryMu.Lock()
+	current = loaded
+	libraryMu.Unlock()
+	return nil
+}
+
+func load(dir string) (*library, error) {
+	lib := &library{templates: make(map[string]*template.Template)}
+
+	entries, err := embedded.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		data, err := embedded.ReadFile("templates/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := lib.addTemplate(entry.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+
+	personas, err := embedded.ReadFile("personas.json")
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("failed to open prompts path: %w", err)
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template %s: %w", file, err)
+			}
+			if err := lib.addTemplate(filepath.Base(file), data); err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "personas.json"))
+		if err == nil {
+			personas = data
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read personas: %w", err)
+		}
+	}
+
+	if err := lib.check(); err != nil {
+		return nil, err
+	}
+	if err := lib.setPersonas(personas); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+// addTemplate parses a template file. Its version is the file name without
+// the extension, so "reading.v2.tmpl" is "reading.v2".
+func (l *library) addTemplate(fileName string, data []byte) error {
+	version := strings.TrimSuffix(fileName, ".tmpl")
+	tmpl, err := template.New(version).Funcs(funcs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid template %s: %w", fileName, err)
+	}
+	for _, section := range []string{sectionSystem, sectionUser, sectionFollowUp} {
+		if tmpl.Lookup(section) == nil {
+			return fmt.Errorf("template %s does not define %q", fileName, section)
+		}
+	}
+
+	l.templates[version] = tmpl
+	return nil
+}
+
+// check renders a sample reading with every template, including the
+// optional sections, so that template errors show up at startup.
+func (l *library) check() error {
+	sample, _ := tarot.GetCard(0)
+	data := NewData(sample, tarot.OrientationReversed, "curious", "What now?", []PastReading{
+		{Date: "2024-01-01", Card: sample.Name, Orientation: string(tarot.OrientationUpright), Mood: "hopeful"},
+	})
+	data.Patterns = &HistoryPatterns{
+		Readings:        4,
+		CurrentCard:     2,
+		RepeatedCards:   []CardCount{{Card: sample.Name, Count: 2}},
+		DominantElement: "air",
+		MajorArcana:     3,
+		CommonMood:      "hopeful",
+		MoodTrend:       "brightening",
+	}
+
+	for version := range l.templates {
+		if _, err := l.render(Persona{Name: "sample", Template: version}, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPersonas decodes and validates the personas.
+func (l *library) setPersonas(data []byte) error {
+	var file personaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid personas JSON: %w", err)
+	}
+
+	l.personas = make(map[string]Persona)
+	for _, persona := range file.Personas {
+		_, duplicate := l.personas[persona.Name]
+		switch {
+		case strings.TrimSpace(persona.Name) == "":
+			return errors.New("every persona needs a name")
+		case duplicate:
+			return fmt.Errorf("duplicate persona %q", persona.Name)
+		case l.templates[persona.Template] == nil:
+			return fmt.Errorf("persona %s uses unknown template %q", persona.Name, persona.Template)
+		case persona.Temperature < 0 || persona.Temperature > 2:
+			return fmt.Errorf("persona %s temperature must be between 0 and 2, got %g", persona.Name, persona.Temperature)
+		case persona.MaxTokens <= 0:
+			return fmt.Errorf("persona %s needs a positive max_tokens", persona.Name)
+		}
+		l.personas[persona.Name] = persona
+	}
+
+	if _, exists := l.personas[file.Default]; !exists {
+		return fmt.Errorf("default persona %q is not defined", file.Default)
+	}
+	l.defaultPersona = file.Default
+	return nil
+}
+
+func (l *library) render(persona Persona, data Data) (*Prompt, error) {
+	tmpl := l.templates[persona.Template]
+	data.Persona = persona
+
+	sections := make(map[string]string)
+	for _, section := range []string{sectionSystem, sectionUser, sectionFollowUp} {
+		var b bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&b, section, data); err != nil {
+			return nil, fmt.Errorf("render %s: %w", persona.Template, err)
+		}
+		sections[section] = strings.TrimSpace(b.String())
+	}
+
+	return &Prompt{
+		System:      sections[sectionSystem],
+		User:        sections[sectionUser],
+		FollowUp:    sections[sectionFollowUp],
+		Version:     persona.Template,
+		Persona:     persona.Name,
+		Model:       persona.Model,
+		Temperature: persona.Temperature,
+		MaxTokens:   persona.MaxTokens,
+	}, nil
+}
+
+// NewData fills in the template data for a card in an orientation.
+func NewData(card tarot.Card, orientation tarot.Orientation, mood, question string, history []PastReading) Data {
+	return Data{
+		Card:        card,
+		Orientation: orientation,
+		Reversed:    orientation == tarot.OrientationReversed,
+		Meaning:     card.Meaning(orientation),
+		Mood:        mood,
+		Question:    question,
+		History:     history,
+	}
+}
+
+// Render renders the named persona's template. An empty name uses the
+// default persona.
+func Render(personaName string, data Data) (*Prompt, error) {
+	libraryMu.RLock()
+	lib := current
+	libraryMu.RUnlock()
+
+	persona, exists := lib.persona(personaName)
+	if !exists {
+		return nil, fmt.Errorf("unknown persona %q", personaName)
+	}
+	return lib.render(persona, data)
+}
+
+// GetPersona looks up a persona by name. An empty name returns the default
+// persona.
+func GetPersona(name string) (Persona, bool) {
+	libraryMu.RLock()
+	defer libraryMu.RUnlock()
+	return current.persona(name)
+}
+
+func (l *library) persona(name string) (Persona, bool) {
+	if name == "" {
+		name = l.defaultPersona
+	}
+	persona, exists := l.personas[name]
+	return persona, exists
+}
+
+// Personas lists the registered personas by name.
+func Personas() []Persona {
+	libraryMu.RLock()
+	defer libraryMu.RUnlock()
+
+	list := make([]Persona, 0, len(current.personas))
+	for _, persona := range current.personas {
+		list = append(list, persona)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
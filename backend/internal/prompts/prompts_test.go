@@ -0,0 +1,152 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"symbol-quest/internal/tarot"
+	"testing"
+)
+
+func TestEmbeddedPersonas(t *testing.T) {
+	card, _ := tarot.GetCard(16)
+	data := NewData(card, tarot.OrientationUpright, "anxious", "What about my job?", []PastReading{
+		{Date: "2024-03-01", Card: "The Fool", Orientation: "reversed", Mood: "curious"},
+	})
+
+	for _, persona := range Personas() {
+		t.Run(persona.Name, func(t *testing.T) {
+			prompt, err := Render(persona.Name, data)
+			if err != nil {
+				t.Fatalf("Render returned error: %v", err)
+			}
+			if prompt.System != persona.Voice {
+				t.Errorf("Expected the persona's voice as system prompt, got %q", prompt.System)
+			}
+			if prompt.Version != persona.Template || prompt.MaxTokens != persona.MaxTokens {
+				t.Errorf("Expected the persona's settings, got %+v", prompt)
+			}
+			for _, want := range []string{"Card: The Tower", "Current Mood: anxious", "Recent Readings:", "- 2024-03-01: The Fool (reversed), feeling curious"} {
+				if !strings.Contains(prompt.User, want) {
+					t.Errorf("Expected %q in prompt, got: %s", want, prompt.User)
+				}
+			}
+		})
+	}
+
+	if persona, exists := GetPersona(""); !exists || persona.Name != "classic" {
+		t.Errorf("Expected classic as the default persona, got %+v", persona)
+	}
+	if _, err := Render("oracle", data); err == nil {
+		t.Error("Expected unknown persona to be rejected")
+	}
+}
+
+func TestRenderPatterns(t *testing.T) {
+	card, _ := tarot.GetCard(16)
+	data := NewData(card, tarot.OrientationUpright, "", "", nil)
+
+	prompt, err := Render("", data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if strings.Contains(prompt.User, "Patterns across") {
+		t.Errorf("Expected no patterns section without history, got: %s", prompt.User)
+	}
+
+	data.Patterns = &HistoryPatterns{
+		Readings:        8,
+		CurrentCard:     1,
+		RepeatedCards:   []CardCount{{Card: "The Tower", Count: 2}, {Card: "The Star", Count: 2}},
+		DominantElement: "fire",
+		MajorArcana:     5,
+		MoodTrend:       "darkening",
+	}
+	prompt, err = Render("", data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Patterns across their last 8 readings:",
+		"- This card has appeared 1 time before",
+		"- Recurring cards: The Tower (2 times), The Star (2 times)",
+		"- Dominant element: fire",
+		"- Major Arcana: 5 of 8",
+		"- Mood trend: darkening",
+		"name them and connect them to this card",
+	} {
+		if !strings.Contains(prompt.User, want) {
+			t.Errorf("Expected %q in prompt, got: %s", want, prompt.User)
+		}
+	}
+	if strings.Contains(prompt.User, "Most frequent mood") {
+		t.Errorf("Expected empty patterns to be left out, got: %s", prompt.User)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Cleanup(func() { Load("") })
+
+	write := func(dir, name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const template = `{{define "system"}}{{.Persona.Voice}}{{end}}{{define "user"}}Tell me about {{.Card.Name}}{{end}}{{define "follow_up"}}Be brief.{{end}}`
+
+	t.Run("Overrides", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "reading.v2.tmpl", template)
+		write(dir, "personas.json", `{"default": "stoic", "personas": [
+			{"name": "stoic", "template": "reading.v2", "temperature": 0.3, "max_tokens": 200, "voice": "You are a stoic."}
+		]}`)
+
+		if err := Load(dir); err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+
+		card, _ := tarot.GetCard(0)
+		prompt, err := Render("", NewData(card, tarot.OrientationUpright, "", "", nil))
+		if err != nil {
+			t.Fatalf("Render returned error: %v", err)
+		}
+		if prompt.Persona != "stoic" || prompt.Version != "reading.v2" || prompt.User != "Tell me about The Fool" {
+			t.Errorf("Expected the loaded persona and template, got %+v", prompt)
+		}
+		if _, exists := GetPersona("classic"); exists {
+			t.Error("Expected personas.json to replace the built-in personas")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := map[string]map[string]string{
+			"MissingSection": {"reading.v2.tmpl": `{{define "system"}}x{{end}}`},
+			"UnknownField":   {"reading.v1.tmpl": strings.Replace(template, ".Card.Name", ".Card.Colour", 1)},
+			"UnknownTemplate": {"personas.json": `{"default": "a", "personas": [
+				{"name": "a", "template": "reading.v9", "temperature": 0.5, "max_tokens": 100}
+			]}`},
+			"MissingDefault": {"personas.json": `{"default": "b", "personas": [
+				{"name": "a", "template": "reading.v1", "temperature": 0.5, "max_tokens": 100}
+			]}`},
+			"BadTemperature": {"personas.json": `{"default": "a", "personas": [
+				{"name": "a", "template": "reading.v1", "temperature": 3, "max_tokens": 100}
+			]}`},
+		}
+
+		for name, files := range tests {
+			t.Run(name, func(t *testing.T) {
+				dir := t.TempDir()
+				for file, content := range files {
+					write(dir, file, content)
+				}
+				if err := Load(dir); err == nil {
+					t.Error("Expected Load to fail")
+				}
+			})
+		}
+
+		if err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("Expected a missing directory to be rejected")
+		}
+	})
+}
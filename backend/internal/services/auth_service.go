@@ -3,7 +3,9 @@ package services
 import (
 	"database/sql"
 	"errors"
+	"log"
 	"symbol-quest/internal/models"
+	"symbol-quest/internal/tarot"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,15 +13,44 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Default lifetimes of the two kinds of token. Access tokens are short
+// so that a stolen one is soon useless; refresh tokens keep a session
+// going and can be revoked.
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type AuthService struct {
-	db        *sql.DB
-	jwtSecret []byte
+	db         *sql.DB
+	jwtSecret  []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	mailer     Mailer
+	appURL     string
+
+	loginPolicy LoginPolicy
 }
 
 func NewAuthService(db *sql.DB, jwtSecret string) *AuthService {
 	return &AuthService{
-		db:        db,
-		jwtSecret: []byte(jwtSecret),
+		db:         db,
+		jwtSecret:  []byte(jwtSecret),
+		accessTTL:  DefaultAccessTokenTTL,
+		refreshTTL: DefaultRefreshTokenTTL,
+
+		loginPolicy: DefaultLoginPolicy,
+	}
+}
+
+// SetTokenTTLs sets how long access and refresh tokens are valid. A
+// refresh token's lifetime starts again each time it is rotated.
+func (s *AuthService) SetTokenTTLs(access, refresh time.Duration) {
+	if access > 0 {
+		s.accessTTL = access
+	}
+	if refresh > 0 {
+		s.refreshTTL = refresh
 	}
 }
 
@@ -28,7 +59,7 @@ func (s *AuthService) Register(email, password string) (*models.User, error) {
 	var existingUser models.User
 	err := s.db.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&existingUser.ID)
 	if err == nil {
-		return nil, errors.New("user already exists")
+		return nil, ErrUserExists
 	}
 
 	// Hash password
@@ -48,10 +79,16 @@ func (s *AuthService) Register(email, password string) (*models.User, error) {
 		return nil, errors.New("failed to create user")
 	}
 
+	if err := s.SendVerification(userID); err != nil {
+		log.Printf("send verification email to %s: %v", email, err)
+	}
+
 	user := &models.User{
 		ID:               userID,
 		Email:           email,
 		SubscriptionTier: "free",
+		ReversalsEnabled: true,
+		HistoryInReadings: true,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
@@ -59,68 +96,120 @@ func (s *AuthService) Register(email, password string) (*models.User, error) {
 	return user, nil
 }
 
-func (s *AuthService) Login(email, password string) (*models.User, string, error) {
+// Login checks the user's password and starts a new session. For users
+// with two-factor authentication it returns a challenge instead, to be
+// completed with CompleteMFALogin. Failures are counted against the
+// account and the caller's IP address, which are throttled and eventually
+// locked out.
+func (s *AuthService) Login(email, password, ip string) (*models.User, *models.TokenResponse, *models.MFAChallenge, error) {
+	account := normalizeEmail(email)
+	if err := s.checkLogin(account, ip); err != nil {
+		return nil, nil, nil, err
+	}
+
 	var user models.User
 	var passwordHash string
 
 	err := s.db.QueryRow(`
-		SELECT id, email, password_hash, subscription_tier, created_at, updated_at
+		SELECT id, email, password_hash, subscription_tier, reversals_enabled, selection_strategy, timezone,
+		       history_in_readings, email_verified_at IS NOT NULL,
+		       EXISTS (SELECT 1 FROM user_mfa WHERE user_id = users.id AND enabled_at IS NOT NULL),
+		       created_at, updated_at
 		FROM users WHERE email = $1
 	`, email).Scan(
 		&user.ID, &user.Email, &passwordHash, &user.SubscriptionTier,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.ReversalsEnabled, &user.SelectionStrategy, &user.Timezone,
+		&user.HistoryInReadings, &user.EmailVerified, &user.MFAEnabled, &user.CreatedAt, &user.UpdatedAt,
 	)
 
+	if err == sql.ErrNoRows {
+		if err := s.recordLoginFailure(account, ip, nil); err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		return nil, nil, nil, err
 	}
 
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		if err := s.recordLoginFailure(account, ip, &user); err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, ErrInvalidCredentials
+	}
+
+	// Failures are only forgotten once both steps succeed, so that the
+	// password can't be used to reset the count while guessing codes
+	if user.MFAEnabled {
+		challenge, err := s.mfaChallenge(&user)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, challenge, nil
 	}
+	s.clearLoginFailures(account)
 
-	// Generate JWT token
-	token, err := s.GenerateToken(user.ID, user.Email, user.SubscriptionTier)
+	tokens, err := s.StartSession(&user)
 	if err != nil {
-		return nil, "", errors.New("failed to generate token")
+		return nil, nil, nil, err
 	}
 
-	return &user, token, nil
+	return &user, tokens, nil, nil
 }
 
 func (s *AuthService) GetUserByID(userID uuid.UUID) (*models.User, error) {
 	var user models.User
 
 	err := s.db.QueryRow(`
-		SELECT id, email, subscription_tier, created_at, updated_at
+		SELECT id, email, subscription_tier, reversals_enabled, selection_strategy, timezone,
+		       history_in_readings, email_verified_at IS NOT NULL,
+		       EXISTS (SELECT 1 FROM user_mfa WHERE user_id = users.id AND enabled_at IS NOT NULL),
+		       created_at, updated_at
 		FROM users WHERE id = $1
 	`, userID).Scan(
 		&user.ID, &user.Email, &user.SubscriptionTier,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.ReversalsEnabled, &user.SelectionStrategy, &user.Timezone,
+		&user.HistoryInReadings, &user.EmailVerified, &user.MFAEnabled, &user.CreatedAt, &user.UpdatedAt,
 	)
 
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
 	}
 
 	return &user, nil
 }
 
+// GenerateToken issues a short-lived access token.
 func (s *AuthService) GenerateToken(userID uuid.UUID, email, subscriptionTier string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":           userID.String(),
 		"email":            email,
 		"subscription_tier": subscriptionTier,
-		"exp":              time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
+		"exp":              time.Now().Add(s.accessTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(s.jwtSecret)
 }
 
+// ValidateToken checks an access token and returns its claims.
 func (s *AuthService) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil || claims["typ"] == mfaPendingType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// parseToken checks the signature and expiry of any token this service
+// signed.
+func (s *AuthService) parseToken(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -129,14 +218,14 @@ func (s *AuthService) ValidateToken(tokenString string) (jwt.MapClaims, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, ErrInvalidToken
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		return claims, nil
 	}
 
-	return nil, errors.New("invalid token")
+	return nil, ErrInvalidToken
 }
 
 func (s *AuthService) UpdateSubscriptionTier(userID uuid.UUID, tier string) error {
@@ -146,4 +235,48 @@ func (s *AuthService) UpdateSubscriptionTier(userID uuid.UUID, tier string) erro
 	`, tier, userID)
 
 	return err
+}
+
+func (s *AuthService) UpdatePreferences(userID uuid.UUID, prefs models.UpdatePreferencesRequest) (*models.User, error) {
+	var reversalsEnabled sql.NullBool
+	if prefs.ReversalsEnabled != nil {
+		reversalsEnabled = sql.NullBool{Bool: *prefs.ReversalsEnabled, Valid: true}
+	}
+
+	var historyInReadings sql.NullBool
+	if prefs.HistoryInReadings != nil {
+		historyInReadings = sql.NullBool{Bool: *prefs.HistoryInReadings, Valid: true}
+	}
+
+	var timezone sql.NullString
+	if prefs.Timezone != nil {
+		if *prefs.Timezone != "" {
+			if _, err := ParseTimezone(*prefs.Timezone); err != nil {
+				return nil, err
+			}
+		}
+		timezone = sql.NullString{String: *prefs.Timezone, Valid: true}
+	}
+
+	var selectionStrategy sql.NullString
+	if prefs.SelectionStrategy != nil {
+		if _, exists := tarot.GetSelector(*prefs.SelectionStrategy); !exists {
+			return nil, ErrUnknownSelector
+		}
+		selectionStrategy = sql.NullString{String: *prefs.SelectionStrategy, Valid: true}
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE users SET reversals_enabled = COALESCE($1, reversals_enabled),
+		       selection_strategy = COALESCE($2, selection_strategy),
+		       timezone = COALESCE($3, timezone),
+		       history_in_readings = COALESCE($4, history_in_readings), updated_at = NOW()
+		WHERE id = $5
+	`, reversalsEnabled, selectionStrategy, timezone, historyInReadings, userID)
+
+	if err != nil {
+		return nil, errors.New("failed to update preferences")
+	}
+
+	return s.GetUserByID(userID)
 }
\ No newline at end of file
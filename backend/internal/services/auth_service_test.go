@@ -13,9 +13,7 @@ import (
 )
 
 func TestAuthService_GenerateToken(t *testing.T) {
-	service := &AuthService{
-		jwtSecret: []byte("test-secret-key"),
-	}
+	service := NewAuthService(nil, "test-secret-key")
 
 	userID := uuid.New()
 	email := "test@example.com"
@@ -50,9 +48,7 @@ func TestAuthService_GenerateToken(t *testing.T) {
 }
 
 func TestAuthService_ValidateToken(t *testing.T) {
-	service := &AuthService{
-		jwtSecret: []byte("test-secret-key"),
-	}
+	service := NewAuthService(nil, "test-secret-key")
 
 	t.Run("ValidToken", func(t *testing.T) {
 		userID := uuid.New()
@@ -118,9 +114,7 @@ func TestPasswordHashing(t *testing.T) {
 }
 
 func TestTokenExpiration(t *testing.T) {
-	service := &AuthService{
-		jwtSecret: []byte("test-secret-key"),
-	}
+	service := NewAuthService(nil, "test-secret-key")
 
 	userID := uuid.New()
 	token, err := service.GenerateToken(userID, "test@example.com", "free")
@@ -133,7 +127,7 @@ func TestTokenExpiration(t *testing.T) {
 		t.Fatalf("Failed to validate token: %v", err)
 	}
 
-	// Check expiration is set (should be 7 days from now)
+	// Check expiration is set (should be 15 minutes from now)
 	exp, ok := claims["exp"]
 	if !ok {
 		t.Error("Token missing expiration claim")
@@ -146,11 +140,11 @@ func TestTokenExpiration(t *testing.T) {
 		t.Error("Token expiration is in the past")
 	}
 
-	// Check it's approximately 7 days from now (within 1 minute tolerance)
-	expectedExp := time.Now().Add(time.Hour * 24 * 7)
+	// Check it's approximately 15 minutes from now (within 1 minute tolerance)
+	expectedExp := time.Now().Add(DefaultAccessTokenTTL)
 	diff := expTime.Sub(expectedExp)
 	if diff > time.Minute || diff < -time.Minute {
-		t.Errorf("Token expiration not approximately 7 days: %v", diff)
+		t.Errorf("Token expiration not approximately 15 minutes: %v", diff)
 	}
 }
 
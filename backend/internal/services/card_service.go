@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"symbol-quest/internal/models"
 	"symbol-quest/internal/tarot"
@@ -11,98 +13,207 @@ import (
 )
 
 type CardService struct {
-	db *sql.DB
+	db                  *sql.DB
+	reversalProbability float64
+	defaultSelector     string
+	moderation          *ModerationService
 }
 
 func NewCardService(db *sql.DB) *CardService {
-	return &CardService{db: db}
+	return &CardService{
+		db:                  db,
+		reversalProbability: tarot.DefaultReversalProbability,
+		defaultSelector:     tarot.DefaultSelectorName,
+	}
 }
 
-func (s *CardService) PerformDailyDraw(userID uuid.UUID, mood, question string) (*models.CardDraw, error) {
-	// Check if user already drew today
-	today := time.Now().Format("2006-01-02")
-	var existingDraw models.CardDraw
+// SetReversalProbability sets the chance, from 0 to 1, that a drawn card
+// comes up reversed for users who have reversals enabled.
+func (s *CardService) SetReversalProbability(probability float64) {
+	if probability < 0 {
+		probability = 0
+	}
+	if probability > 1 {
+		probability = 1
+	}
+	s.reversalProbability = probability
+}
 
-	err := s.db.QueryRow(`
-		SELECT id, card_id, card_name, interpretation_basic, mood, question, created_at
-		FROM card_draws 
-		WHERE user_id = $1 AND draw_date = $2
-	`, userID, today).Scan(
-		&existingDraw.ID, &existingDraw.CardID, &existingDraw.CardName,
-		&existingDraw.InterpretationBasic, &existingDraw.Mood,
-		&existingDraw.Question, &existingDraw.CreatedAt,
-	)
+// SetDefaultSelector sets the card selection strategy used when neither
+// the request nor the user's preferences name one.
+func (s *CardService) SetDefaultSelector(name string) error {
+	if _, exists := tarot.GetSelector(name); !exists {
+		return ErrUnknownSelector
+	}
+	s.defaultSelector = name
+	return nil
+}
 
-	if err == nil {
-		existingDraw.UserID = userID
-		existingDraw.DrawDate = today
-		return &existingDraw, errors.New("daily draw already completed")
+// SetModeration screens the questions of draws and spreads.
+func (s *CardService) SetModeration(moderation *ModerationService) {
+	s.moderation = moderation
+}
+
+// DefaultSelector returns the name of the fallback selection strategy.
+func (s *CardService) DefaultSelector() string {
+	return s.defaultSelector
+}
+
+// PerformDailyDraw draws the user's card for the current day in their
+// timezone. timezone is the request's fallback for users without a stored
+// timezone and may be empty.
+//
+// The whole draw runs in one transaction holding a lock on the user's row,
+// and card_draws is unique per user, date and kind, so parallel requests
+// cannot create two daily draws. When the user has already drawn today the
+// existing draw is returned with AlreadyDrawn set. The question is
+// screened before anything is drawn.
+func (s *CardService) PerformDailyDraw(ctx context.Context, userID uuid.UUID, mood, question, deckName, strategy, timezone string, scope tarot.DeckScope) (*models.DailyDrawResult, error) {
+	if err := s.moderation.ScreenQuestion(ctx, userID, ModerationSourceDraw, question); err != nil {
+		return nil, err
 	}
 
-	if err != sql.ErrNoRows {
+	deck, err := resolveDeck(deckName)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := lockDrawUser(tx, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Check subscription for usage limits
-	isUnlimited, err := s.checkUserLimits(userID)
+	selector, err := s.selectorFor(strategy, user.selectionStrategy)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isUnlimited {
+	location, err := resolveLocation(user.timezone, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if user already drew today. A draw made in another timezone
+	// counts until its own day is over, so switching timezone cannot give
+	// a second draw on the same real day.
+	now := time.Now()
+	today := localDate(now, location)
+	existingDraw, err := findDailyDraw(tx, userID, today)
+	if err == sql.ErrNoRows {
+		existingDraw, err = findUnfinishedDailyDraw(tx, userID, now)
+	}
+	if err == nil {
+		return &models.DailyDrawResult{Draw: existingDraw, AlreadyDrawn: true}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if !user.isPremium() {
 		// Check daily usage for free users
-		var drawsToday int
-		err = s.db.QueryRow(`
-			SELECT COALESCE(draws_count, 0) FROM daily_usage 
-			WHERE user_id = $1 AND usage_date = $2
-		`, userID, today).Scan(&drawsToday)
-
-		if err == nil && drawsToday >= 1 {
-			return nil, errors.New("daily limit reached - upgrade to premium for unlimited draws")
+		if err := checkDailyUsage(tx, userID, today); err != nil {
+			return nil, err
 		}
 	}
 
-	// Select intelligent card
-	cardID := tarot.SelectIntelligentCard(userID, s.db, mood, question)
-	card, exists := tarot.MajorArcana[cardID]
+	reversalProbability := 0.0
+	if user.reversalsEnabled {
+		reversalProbability = s.reversalProbability
+	}
+
+	// Select a card with the chosen strategy from a fresh, recorded seed
+	input := tarot.DrawInput{
+		Seed:                tarot.NewSeed(),
+		Deck:                deck.Name,
+		Scope:               scope,
+		Selector:            selector.Name(),
+		Mood:                mood,
+		Question:            question,
+		Recent:              tarot.RecentCards(userID, s.db, tarot.HistoryWindow),
+		ReversalProbability: reversalProbability,
+	}
+	result, err := tarot.Draw(input)
+	if err != nil {
+		return nil, err
+	}
+
+	cardID := result.CardID
+	card, exists := deck.Card(cardID)
 	if !exists {
 		return nil, errors.New("invalid card selected")
 	}
 
-	// Create card draw record
-	drawID := uuid.New()
-	_, err = s.db.Exec(`
-		INSERT INTO card_draws (id, user_id, card_id, card_name, draw_date, 
-		                       interpretation_basic, mood, question, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
-	`, drawID, userID, cardID, card.Name, today, card.TraditionalMeaning, mood, question)
+	orientation := result.Orientation
+	meaning := card.Meaning(orientation)
+
+	recentJSON, err := json.Marshal(input.Recent)
+	if err != nil {
+		return nil, err
+	}
+	explanationJSON, err := json.Marshal(result.Explanation)
+	if err != nil {
+		return nil, err
+	}
+	commitment := input.Commitment()
 
+	// Create card draw record. The unique index on (user_id, draw_date, kind)
+	// is the last line of defence if another draw slipped in.
+	drawID := uuid.New()
+	res, err := tx.Exec(`
+		INSERT INTO card_draws (id, user_id, card_id, card_name, deck, orientation, selector, draw_date, kind,
+		                       interpretation_basic, mood, question, seed, scope, recent_cards,
+		                       reversal_probability, commitment, explanation, day_ends_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, NOW())
+		ON CONFLICT (user_id, draw_date, kind) DO NOTHING
+	`, drawID, userID, cardID, card.Name, deck.Name, string(orientation), selector.Name(), today, DrawKindDaily,
+		meaning, mood, question, input.Seed, string(scope), recentJSON, reversalProbability, commitment, explanationJSON,
+		nextMidnight(now, location))
 	if err != nil {
 		return nil, err
 	}
 
+	if inserted, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if inserted == 0 {
+		existingDraw, err := findDailyDraw(tx, userID, today)
+		if err != nil {
+			return nil, err
+		}
+		return &models.DailyDrawResult{Draw: existingDraw, AlreadyDrawn: true}, nil
+	}
+
 	// Update daily usage
-	_, err = s.db.Exec(`
-		INSERT INTO daily_usage (user_id, usage_date, draws_count)
-		VALUES ($1, $2, 1)
-		ON CONFLICT (user_id, usage_date)
-		DO UPDATE SET draws_count = daily_usage.draws_count + 1
-	`, userID, today)
+	if err := incrementDailyUsage(tx, userID, today); err != nil {
+		return nil, err
+	}
 
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return &models.CardDraw{
-		ID:                  drawID,
-		UserID:             userID,
-		CardID:             cardID,
-		CardName:           card.Name,
-		DrawDate:           today,
-		InterpretationBasic: card.TraditionalMeaning,
-		Mood:               mood,
-		Question:           question,
-		CreatedAt:          time.Now(),
+	return &models.DailyDrawResult{
+		Draw: &models.CardDraw{
+			ID:                  drawID,
+			UserID:             userID,
+			CardID:             cardID,
+			CardName:           card.Name,
+			Deck:               deck.Name,
+			Orientation:        string(orientation),
+			Selector:           selector.Name(),
+			Seed:               input.Seed,
+			Commitment:         commitment,
+			DrawDate:           today,
+			InterpretationBasic: meaning,
+			Mood:               mood,
+			Question:           question,
+			CreatedAt:          time.Now(),
+		},
 	}, nil
 }
 
@@ -112,8 +223,9 @@ func (s *CardService) GetDrawHistory(userID uuid.UUID, limit int) ([]models.Card
 	}
 
 	rows, err := s.db.Query(`
-		SELECT id, card_id, card_name, draw_date, interpretation_basic, 
-		       COALESCE(interpretation_enhanced, ''), COALESCE(mood, ''), 
+		SELECT id, card_id, card_name, deck, orientation, selector, draw_date, interpretation_basic, 
+		       COALESCE(interpretation_enhanced, ''), COALESCE(interpretation_persona, ''),
+		       COALESCE(interpretation_template, ''), COALESCE(mood, ''), 
 		       COALESCE(question, ''), created_at
 		FROM card_draws 
 		WHERE user_id = $1 
@@ -130,9 +242,10 @@ func (s *CardService) GetDrawHistory(userID uuid.UUID, limit int) ([]models.Card
 	for rows.Next() {
 		var draw models.CardDraw
 		err := rows.Scan(
-			&draw.ID, &draw.CardID, &draw.CardName,
-			&draw.DrawDate, &draw.InterpretationBasic,
-			&draw.InterpretationEnhanced, &draw.Mood,
+			&draw.ID, &draw.CardID, &draw.CardName, &draw.Deck, &draw.Orientation,
+			&draw.Selector, &draw.DrawDate, &draw.InterpretationBasic,
+			&draw.InterpretationEnhanced, &draw.InterpretationPersona,
+			&draw.InterpretationTemplate, &draw.Mood,
 			&draw.Question, &draw.CreatedAt,
 		)
 		if err != nil {
@@ -145,18 +258,33 @@ func (s *CardService) GetDrawHistory(userID uuid.UUID, limit int) ([]models.Card
 	return draws, nil
 }
 
-func (s *CardService) GetTodayStatus(userID uuid.UUID) (map[string]interface{}, error) {
-	today := time.Now().Format("2006-01-02")
+// GetTodayStatus reports whether the user has drawn on the current day in
+// their timezone and when their next daily draw becomes available.
+func (s *CardService) GetTodayStatus(userID uuid.UUID, timezone string) (map[string]interface{}, error) {
+	location, err := userLocation(s.db, userID, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	today := localDate(now, location)
+	nextDrawAt := nextMidnight(now, location)
 
 	var drawID uuid.UUID
 	var cardID int
 	var cardName string
+	var deckName string
+	var orientation string
+	var dayEndsAt sql.NullTime
 
-	err := s.db.QueryRow(`
-		SELECT id, card_id, card_name
+	// A draw made in another timezone counts until its own day is over
+	err = s.db.QueryRow(`
+		SELECT id, card_id, card_name, deck, orientation, day_ends_at
 		FROM card_draws 
-		WHERE user_id = $1 AND draw_date = $2
-	`, userID, today).Scan(&drawID, &cardID, &cardName)
+		WHERE user_id = $1 AND kind = $3 AND (draw_date = $2 OR day_ends_at > $4)
+		ORDER BY draw_date DESC
+		LIMIT 1
+	`, userID, today, DrawKindDaily, now).Scan(&drawID, &cardID, &cardName, &deckName, &orientation, &dayEndsAt)
 
 	if err == sql.ErrNoRows {
 		return map[string]interface{}{
@@ -165,21 +293,30 @@ func (s *CardService) GetTodayStatus(userID uuid.UUID) (map[string]interface{},
 			"card":         nil,
 			"draws_today":  0,
 			"limit":        1,
+			"date":         today,
+			"timezone":     location.String(),
+			"next_draw_at": now,
 		}, nil
 	}
 
 	if err != nil {
 		return nil, err
 	}
+	if dayEndsAt.Valid && dayEndsAt.Time.After(nextDrawAt) {
+		nextDrawAt = dayEndsAt.Time
+	}
 
 	// Get usage count
 	var drawsToday int
-	s.db.QueryRow(`
+	err = s.db.QueryRow(`
 		SELECT COALESCE(draws_count, 0) FROM daily_usage 
 		WHERE user_id = $1 AND usage_date = $2
 	`, userID, today).Scan(&drawsToday)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
 
-	card := tarot.MajorArcana[cardID]
+	card, _ := s.lookupCard(deckName, cardID)
 
 	return map[string]interface{}{
 		"has_drawn":   true,
@@ -187,41 +324,259 @@ func (s *CardService) GetTodayStatus(userID uuid.UUID) (map[string]interface{},
 		"card": map[string]interface{}{
 			"id":   cardID,
 			"name": cardName,
-			"traditional_meaning": card.TraditionalMeaning,
+			"deck": deckName,
+			"orientation": orientation,
+			"traditional_meaning": card.Meaning(tarot.Orientation(orientation)),
 		},
 		"draws_today": drawsToday,
 		"limit":       1,
+		"date":        today,
+		"timezone":    location.String(),
+		"next_draw_at": nextDrawAt,
 	}, nil
 }
 
-func (s *CardService) GetCardMeaning(cardID int) (*tarot.Card, error) {
-	if card, exists := tarot.MajorArcana[cardID]; exists {
+func (s *CardService) GetCardMeaning(deckName string, cardID int) (*tarot.Card, error) {
+	deck, err := resolveDeck(deckName)
+	if err != nil {
+		return nil, err
+	}
+
+	if card, exists := deck.Card(cardID); exists {
 		return &card, nil
 	}
-	return nil, errors.New("card not found")
+	return nil, ErrCardNotFound
+}
+
+// lookupCard finds a card in a stored draw's deck, falling back to the
+// default deck if that deck is no longer loaded.
+func (s *CardService) lookupCard(deckName string, cardID int) (tarot.Card, bool) {
+	if deck, exists := tarot.GetDeck(deckName); exists {
+		return deck.Card(cardID)
+	}
+	return tarot.GetCard(cardID)
+}
+
+func resolveDeck(deckName string) (*tarot.Deck, error) {
+	deck, exists := tarot.GetDeck(deckName)
+	if !exists {
+		return nil, ErrDeckNotFound
+	}
+	return deck, nil
+}
+
+// DrawKindDaily marks a user's one card of the day in card_draws. Only one
+// draw of each kind may exist per user and date.
+const DrawKindDaily = "daily"
+
+// drawUser is the part of a user's row a draw depends on, read while the
+// row is locked.
+type drawUser struct {
+	tier              string
+	reversalsEnabled  bool
+	selectionStrategy string
+	timezone          string
+}
+
+func (u drawUser) isPremium() bool {
+	return u.tier == "premium"
+}
+
+// lockDrawUser locks the user's row for the rest of tx so that concurrent
+// draws for the same user run one after another.
+func lockDrawUser(tx *sql.Tx, userID uuid.UUID) (*drawUser, error) {
+	var user drawUser
+	err := tx.QueryRow(`
+		SELECT COALESCE(subscription_tier, 'free'), reversals_enabled, selection_strategy, timezone
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+	`, userID).Scan(&user.tier, &user.reversalsEnabled, &user.selectionStrategy, &user.timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findDailyDraw loads the user's daily draw for a date.
+func findDailyDraw(tx *sql.Tx, userID uuid.UUID, date string) (*models.CardDraw, error) {
+	return queryDailyDraw(tx, userID, "draw_date = $3", date)
+}
+
+// findUnfinishedDailyDraw returns the user's daily draw whose local day,
+// in the timezone it was drawn in, has not ended by now.
+func findUnfinishedDailyDraw(tx *sql.Tx, userID uuid.UUID, now time.Time) (*models.CardDraw, error) {
+	return queryDailyDraw(tx, userID, "day_ends_at > $3", now)
+}
+
+func queryDailyDraw(tx *sql.Tx, userID uuid.UUID, condition string, arg interface{}) (*models.CardDraw, error) {
+	var draw models.CardDraw
+	var seed sql.NullInt64
+	var commitment sql.NullString
+
+	err := tx.QueryRow(`
+		SELECT id, card_id, card_name, deck, orientation, selector, TO_CHAR(draw_date, 'YYYY-MM-DD'),
+		       seed, commitment, interpretation_basic, COALESCE(mood, ''), COALESCE(question, ''), created_at
+		FROM card_draws
+		WHERE user_id = $1 AND kind = $2 AND `+condition+`
+		ORDER BY draw_date DESC
+		LIMIT 1
+	`, userID, DrawKindDaily, arg).Scan(
+		&draw.ID, &draw.CardID, &draw.CardName, &draw.Deck, &draw.Orientation, &draw.Selector, &draw.DrawDate,
+		&seed, &commitment, &draw.InterpretationBasic, &draw.Mood, &draw.Question, &draw.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	draw.UserID = userID
+	draw.Seed = seed.Int64
+	draw.Commitment = commitment.String
+	return &draw, nil
 }
 
-func (s *CardService) SaveEnhancedInterpretation(userID uuid.UUID, drawDate string, interpretation string) error {
-	_, err := s.db.Exec(`
-		UPDATE card_draws 
-		SET interpretation_enhanced = $1
-		WHERE user_id = $2 AND draw_date = $3
-	`, interpretation, userID, drawDate)
+// checkDailyUsage returns ErrDailyLimit once a free user has used
+// their draw for the date.
+func checkDailyUsage(tx *sql.Tx, userID uuid.UUID, date string) error {
+	var drawsToday int
+	err := tx.QueryRow(`
+		SELECT COALESCE(draws_count, 0) FROM daily_usage
+		WHERE user_id = $1 AND usage_date = $2
+	`, userID, date).Scan(&drawsToday)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if drawsToday >= 1 {
+		return ErrDailyLimit
+	}
+	return nil
+}
 
+func incrementDailyUsage(tx *sql.Tx, userID uuid.UUID, date string) error {
+	_, err := tx.Exec(`
+		INSERT INTO daily_usage (user_id, usage_date, draws_count)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, usage_date)
+		DO UPDATE SET draws_count = daily_usage.draws_count + 1
+	`, userID, date)
 	return err
 }
 
-func (s *CardService) checkUserLimits(userID uuid.UUID) (bool, error) {
-	if s.db == nil {
-		return false, errors.New("database connection is nil")
+// selectorFor picks the selection strategy for a draw: the one named in
+// the request, then the user's preference, then the service default.
+func (s *CardService) selectorFor(requested, preferred string) (tarot.Selector, error) {
+	if requested != "" {
+		selector, exists := tarot.GetSelector(requested)
+		if !exists {
+			return nil, ErrUnknownSelector
+		}
+		return selector, nil
 	}
-	
-	var tier string
-	err := s.db.QueryRow("SELECT subscription_tier FROM users WHERE id = $1", userID).Scan(&tier)
 
+	if selector, exists := tarot.GetSelector(preferred); exists && preferred != "" {
+		return selector, nil
+	}
+
+	selector, _ := tarot.GetSelector(s.defaultSelector)
+	return selector, nil
+}
+
+// ExplainDraw returns the score breakdown stored when one of the user's
+// daily draws was selected.
+func (s *CardService) ExplainDraw(userID, drawID uuid.UUID) (*models.DrawExplanation, error) {
+	explanation := &models.DrawExplanation{DrawID: drawID}
+	var raw []byte
+
+	err := s.db.QueryRow(`
+		SELECT card_id, card_name, deck, selector, explanation
+		FROM card_draws
+		WHERE id = $1 AND user_id = $2
+	`, drawID, userID).Scan(&explanation.CardID, &explanation.CardName, &explanation.Deck, &explanation.Selector, &raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrDrawNotFound
+	}
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	if raw == nil {
+		return nil, ErrNoExplanation
 	}
+	explanation.Explanation = json.RawMessage(raw)
 
-	return tier == "premium", nil
-}
\ No newline at end of file
+	return explanation, nil
+}
+
+// VerifyDraw re-derives one of the user's past daily draws from its stored
+// seed and inputs, and reports whether the inputs still match their
+// commitment and the same card comes out in the same orientation.
+func (s *CardService) VerifyDraw(userID, drawID uuid.UUID) (*models.DrawVerification, error) {
+	var (
+		input       tarot.DrawInput
+		seed        sql.NullInt64
+		commitment  sql.NullString
+		scope       string
+		recentJSON  []byte
+		cardID      int
+		orientation string
+	)
+
+	err := s.db.QueryRow(`
+		SELECT seed, commitment, deck, scope, selector, COALESCE(mood, ''), COALESCE(question, ''),
+		       COALESCE(recent_cards, '[]'), reversal_probability, card_id, orientation
+		FROM card_draws
+		WHERE id = $1 AND user_id = $2
+	`, drawID, userID).Scan(
+		&seed, &commitment, &input.Deck, &scope, &input.Selector, &input.Mood, &input.Question,
+		&recentJSON, &input.ReversalProbability, &cardID, &orientation,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrDrawNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !seed.Valid || !commitment.Valid {
+		return nil, ErrDrawNotVerifiable
+	}
+
+	input.Seed = seed.Int64
+	input.Scope = tarot.DeckScope(scope)
+	if err := json.Unmarshal(recentJSON, &input.Recent); err != nil {
+		return nil, err
+	}
+
+	result, err := tarot.Draw(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDrawVerification(drawID, input, commitment.String, []models.VerifiedCard{{
+		Position:           0,
+		CardID:             cardID,
+		Orientation:        orientation,
+		DerivedCardID:      result.CardID,
+		DerivedOrientation: string(result.Orientation),
+	}}), nil
+}
+
+func newDrawVerification(drawID uuid.UUID, input tarot.DrawInput, commitment string, cards []models.VerifiedCard) *models.DrawVerification {
+	verification := &models.DrawVerification{
+		DrawID:            drawID,
+		Seed:              input.Seed,
+		Commitment:        commitment,
+		CommitmentMatches: input.Commitment() == commitment,
+		Cards:             cards,
+	}
+
+	verification.Verified = verification.CommitmentMatches
+	for i := range verification.Cards {
+		card := &verification.Cards[i]
+		card.Matches = card.CardID == card.DerivedCardID && card.Orientation == card.DerivedOrientation
+		verification.Verified = verification.Verified && card.Matches
+	}
+
+	return verification
+}
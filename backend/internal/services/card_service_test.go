@@ -1,6 +1,14 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"symbol-quest/internal/database"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/tarot"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,26 +22,11 @@ func TestCardService_Creation(t *testing.T) {
 	}
 }
 
-func TestCardService_CheckUserLimits(t *testing.T) {
-	// Skip database-dependent test in unit mode
-	if testing.Short() {
-		t.Skip("Skipping database-dependent test in short mode")
-	}
-	
-	service := &CardService{db: nil}
-	
-	// Test with nil database (should return error)
-	_, err := service.checkUserLimits(uuid.New())
-	if err == nil {
-		t.Error("Expected error with nil database, got none")
-	}
-}
-
 func TestCardService_GetCardMeaning(t *testing.T) {
 	service := &CardService{db: nil}
 
 	t.Run("ValidCardID", func(t *testing.T) {
-		card, err := service.GetCardMeaning(0) // The Fool
+		card, err := service.GetCardMeaning(tarot.DefaultDeckName, 0) // The Fool
 		if err != nil {
 			t.Fatalf("Expected valid card ID to return card: %v", err)
 		}
@@ -51,8 +44,37 @@ func TestCardService_GetCardMeaning(t *testing.T) {
 		}
 	})
 
+	t.Run("MinorArcanaCardID", func(t *testing.T) {
+		card, err := service.GetCardMeaning(tarot.DefaultDeckName, 77)
+		if err != nil {
+			t.Fatalf("Expected minor arcana card ID to return card: %v", err)
+		}
+
+		if card.Name != "King of Pentacles" {
+			t.Errorf("Expected card name 'King of Pentacles', got '%s'", card.Name)
+		}
+	})
+
+	t.Run("ThothDeck", func(t *testing.T) {
+		card, err := service.GetCardMeaning("thoth", 8)
+		if err != nil {
+			t.Fatalf("Expected Thoth deck card to be found: %v", err)
+		}
+
+		if card.Name != "Lust" || card.Number != "XI" {
+			t.Errorf("Expected Thoth card 8 to be Lust (XI), got %s (%s)", card.Name, card.Number)
+		}
+	})
+
+	t.Run("UnknownDeck", func(t *testing.T) {
+		_, err := service.GetCardMeaning("no-such-deck", 0)
+		if !errors.Is(err, ErrDeckNotFound) {
+			t.Errorf("Expected ErrDeckNotFound, got %v", err)
+		}
+	})
+
 	t.Run("InvalidCardID", func(t *testing.T) {
-		_, err := service.GetCardMeaning(99)
+		_, err := service.GetCardMeaning(tarot.DefaultDeckName, 99)
 		if err == nil {
 			t.Error("Expected error for invalid card ID, got none")
 		}
@@ -64,13 +86,229 @@ func TestCardService_GetCardMeaning(t *testing.T) {
 	})
 
 	t.Run("NegativeCardID", func(t *testing.T) {
-		_, err := service.GetCardMeaning(-1)
+		_, err := service.GetCardMeaning(tarot.DefaultDeckName, -1)
 		if err == nil {
 			t.Error("Expected error for negative card ID, got none")
 		}
 	})
 }
 
+func TestCardService_SelectionStrategy(t *testing.T) {
+	service := NewCardService(nil)
+
+	t.Run("DefaultSelector", func(t *testing.T) {
+		if service.DefaultSelector() != tarot.DefaultSelectorName {
+			t.Errorf("Expected default selector %q, got %q", tarot.DefaultSelectorName, service.DefaultSelector())
+		}
+
+		if err := service.SetDefaultSelector(tarot.SelectorRandom); err != nil {
+			t.Fatalf("SetDefaultSelector returned error: %v", err)
+		}
+		if service.DefaultSelector() != tarot.SelectorRandom {
+			t.Errorf("Expected default selector %q, got %q", tarot.SelectorRandom, service.DefaultSelector())
+		}
+
+		if err := service.SetDefaultSelector("psychic"); !errors.Is(err, ErrUnknownSelector) {
+			t.Errorf("Expected ErrUnknownSelector, got %v", err)
+		}
+	})
+
+	t.Run("RequestedSelector", func(t *testing.T) {
+		selector, err := service.selectorFor(tarot.SelectorWeighted, tarot.SelectorHistory)
+		if err != nil {
+			t.Fatalf("selectorFor returned error: %v", err)
+		}
+		if selector.Name() != tarot.SelectorWeighted {
+			t.Errorf("Expected the requested selector, got %q", selector.Name())
+		}
+
+		if _, err := service.selectorFor("psychic", ""); !errors.Is(err, ErrUnknownSelector) {
+			t.Errorf("Expected ErrUnknownSelector, got %v", err)
+		}
+	})
+
+	t.Run("PreferredSelector", func(t *testing.T) {
+		if selector, _ := service.selectorFor("", tarot.SelectorWeighted); selector.Name() != tarot.SelectorWeighted {
+			t.Errorf("Expected the user's preferred selector, got %q", selector.Name())
+		}
+		if selector, _ := service.selectorFor("", ""); selector.Name() != service.DefaultSelector() {
+			t.Errorf("Expected the default selector, got %q", selector.Name())
+		}
+	})
+}
+
+// testDatabase connects to a disposable Postgres database, e.g.
+// TEST_DATABASE_URL=postgres://localhost/symbol_quest_test?sslmode=disable,
+// and creates a user that is removed when the test ends.
+func testDatabase(t *testing.T) (*sql.DB, uuid.UUID) {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" || testing.Short() {
+		t.Skip("Set TEST_DATABASE_URL to run database tests")
+	}
+
+	db, err := database.Connect(databaseURL)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+
+	userID := uuid.New()
+	_, err = db.Exec(`
+		INSERT INTO users (id, email, password_hash) VALUES ($1, $2, 'x')
+	`, userID, userID.String()+"@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", userID) })
+
+	return db, userID
+}
+
+func TestPerformDailyDraw_Concurrent(t *testing.T) {
+	db, userID := testDatabase(t)
+	service := NewCardService(db)
+
+	const draws = 10
+	var wg sync.WaitGroup
+	results := make(chan *models.DailyDrawResult, draws)
+	errs := make(chan error, draws)
+	for i := 0; i < draws; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := service.PerformDailyDraw(context.Background(), userID, "curious", "", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- result
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("PerformDailyDraw returned error: %v", err)
+	}
+
+	var fresh int
+	var drawID uuid.UUID
+	for result := range results {
+		if !result.AlreadyDrawn {
+			fresh++
+			drawID = result.Draw.ID
+		}
+	}
+	if fresh != 1 {
+		t.Fatalf("Expected exactly one successful draw, got %d", fresh)
+	}
+
+	var stored int
+	if err := db.QueryRow("SELECT COUNT(*) FROM card_draws WHERE user_id = $1", userID).Scan(&stored); err != nil {
+		t.Fatalf("Failed to count draws: %v", err)
+	}
+	if stored != 1 {
+		t.Errorf("Expected one stored draw, got %d", stored)
+	}
+
+	result, err := service.PerformDailyDraw(context.Background(), userID, "", "", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw returned error: %v", err)
+	}
+	if !result.AlreadyDrawn || result.Draw.ID != drawID {
+		t.Errorf("Expected the existing draw %s, got %+v", drawID, result)
+	}
+}
+
+func TestPerformDailyDraw_DailyLimit(t *testing.T) {
+	db, userID := testDatabase(t)
+	service := NewCardService(db)
+	today := localDate(time.Now(), time.UTC)
+
+	// Usage without a stored draw, as left by a draw that was removed
+	if _, err := db.Exec("INSERT INTO daily_usage (user_id, usage_date, draws_count) VALUES ($1, $2, 1)", userID, today); err != nil {
+		t.Fatalf("Failed to record usage: %v", err)
+	}
+
+	_, err := service.PerformDailyDraw(context.Background(), userID, "", "", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+	if !errors.Is(err, ErrDailyLimit) {
+		t.Fatalf("Expected ErrDailyLimit for a free user, got %v", err)
+	}
+
+	if _, err := db.Exec("UPDATE users SET subscription_tier = 'premium' WHERE id = $1", userID); err != nil {
+		t.Fatalf("Failed to upgrade user: %v", err)
+	}
+	result, err := service.PerformDailyDraw(context.Background(), userID, "", "", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("Expected a premium user to bypass the limit, got %v", err)
+	}
+	if result.AlreadyDrawn {
+		t.Errorf("Expected a new draw, got %+v", result)
+	}
+
+	status, err := service.GetTodayStatus(userID, "UTC")
+	if err != nil {
+		t.Fatalf("GetTodayStatus returned error: %v", err)
+	}
+	if status["draws_today"] != 2 {
+		t.Errorf("Expected both draws to be counted, got %v", status["draws_today"])
+	}
+}
+
+func TestPerformDailyDraw_TimezoneChange(t *testing.T) {
+	db, userID := testDatabase(t)
+	service := NewCardService(db)
+	ctx := context.Background()
+
+	// Kiritimati (UTC+14) and Niue (UTC-11) are always on different dates
+	first, err := service.PerformDailyDraw(ctx, userID, "", "", tarot.DefaultDeckName, "", "Pacific/Kiritimati", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw returned error: %v", err)
+	}
+
+	second, err := service.PerformDailyDraw(ctx, userID, "", "", tarot.DefaultDeckName, "", "Pacific/Niue", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw returned error: %v", err)
+	}
+	if !second.AlreadyDrawn || second.Draw.ID != first.Draw.ID {
+		t.Errorf("Expected the Kiritimati draw after switching header, got %+v", second)
+	}
+
+	if _, err := db.Exec("UPDATE users SET timezone = 'Pacific/Niue' WHERE id = $1", userID); err != nil {
+		t.Fatalf("Failed to change timezone: %v", err)
+	}
+	third, err := service.PerformDailyDraw(ctx, userID, "", "", tarot.DefaultDeckName, "", "", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw returned error: %v", err)
+	}
+	if !third.AlreadyDrawn || third.Draw.ID != first.Draw.ID {
+		t.Errorf("Expected the Kiritimati draw after changing preference, got %+v", third)
+	}
+
+	var stored int
+	if err := db.QueryRow("SELECT COUNT(*) FROM card_draws WHERE user_id = $1", userID).Scan(&stored); err != nil {
+		t.Fatalf("Failed to count draws: %v", err)
+	}
+	if stored != 1 {
+		t.Errorf("Expected one stored draw, got %d", stored)
+	}
+
+	status, err := service.GetTodayStatus(userID, "")
+	if err != nil {
+		t.Fatalf("GetTodayStatus returned error: %v", err)
+	}
+	if status["has_drawn"] != true {
+		t.Errorf("Expected today's status to show the draw, got %v", status)
+	}
+}
+
 func TestDailyDrawValidation(t *testing.T) {
 	t.Run("ValidInputs", func(t *testing.T) {
 		mood := "excited"
@@ -164,26 +402,6 @@ func TestUsageLimitLogic(t *testing.T) {
 	})
 }
 
-func TestErrorHandling(t *testing.T) {
-	service := &CardService{db: nil}
-
-	t.Run("NilDatabase", func(t *testing.T) {
-		// Most database operations should handle nil gracefully or return appropriate errors
-		_, err := service.checkUserLimits(uuid.New())
-		if err == nil {
-			t.Error("Expected error when database is nil")
-		}
-	})
-
-	t.Run("InvalidUUID", func(t *testing.T) {
-		// Test with zero UUID - should return error due to nil database
-		_, err := service.checkUserLimits(uuid.UUID{})
-		if err == nil {
-			t.Error("Expected error with zero UUID and nil database")
-		}
-	})
-}
-
 func TestCardServiceConstants(t *testing.T) {
 	// Test various constants and limits used by card service
 	
@@ -211,7 +429,7 @@ func BenchmarkGetCardMeaning(b *testing.B) {
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		service.GetCardMeaning(i % 22) // Cycle through all cards
+		service.GetCardMeaning(tarot.DefaultDeckName, i % 22) // Cycle through all cards
 	}
 }
 
@@ -220,4 +438,44 @@ func BenchmarkTodayDateFormatting(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = time.Now().Format("2006-01-02")
 	}
-}
\ No newline at end of file
+}
+func TestNewDrawVerification(t *testing.T) {
+	input := tarot.DrawInput{Seed: 99, Deck: tarot.DefaultDeckName, Scope: tarot.ScopeFull, Selector: tarot.SelectorRandom}
+	result, err := tarot.Draw(input)
+	if err != nil {
+		t.Fatalf("Draw returned error: %v", err)
+	}
+
+	stored := models.VerifiedCard{
+		CardID:             result.CardID,
+		Orientation:        string(result.Orientation),
+		DerivedCardID:      result.CardID,
+		DerivedOrientation: string(result.Orientation),
+	}
+
+	t.Run("Matches", func(t *testing.T) {
+		verification := newDrawVerification(uuid.New(), input, input.Commitment(), []models.VerifiedCard{stored})
+		if !verification.Verified || !verification.CommitmentMatches || !verification.Cards[0].Matches {
+			t.Errorf("Expected draw to verify, got %+v", verification)
+		}
+	})
+
+	t.Run("TamperedCard", func(t *testing.T) {
+		tampered := stored
+		tampered.CardID = (result.CardID + 1) % 78
+		verification := newDrawVerification(uuid.New(), input, input.Commitment(), []models.VerifiedCard{tampered})
+		if verification.Verified || verification.Cards[0].Matches {
+			t.Error("Expected a changed card to fail verification")
+		}
+	})
+
+	t.Run("TamperedInputs", func(t *testing.T) {
+		commitment := input.Commitment()
+		changed := input
+		changed.Mood = "hopeful"
+		verification := newDrawVerification(uuid.New(), changed, commitment, []models.VerifiedCard{stored})
+		if verification.Verified || verification.CommitmentMatches {
+			t.Error("Expected changed inputs to fail the commitment check")
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/prompts"
+	"symbol-quest/internal/tarot"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatLimits bound a reading chat for one subscription tier.
+type ChatLimits struct {
+	// Turns is how many questions a user may ask about one draw.
+	Turns int `json:"turns"`
+	// Tokens caps the estimated size of the whole thread.
+	Tokens int `json:"tokens"`
+	// ReplyTokens caps each answer.
+	ReplyTokens int `json:"reply_tokens"`
+}
+
+// DefaultChatLimits are the reading chat limits per subscription tier.
+// Unknown tiers get the free limits.
+var DefaultChatLimits = map[string]ChatLimits{
+	"free":    {Turns: 3, Tokens: 1500, ReplyTokens: 150},
+	"premium": {Turns: 20, Tokens: 12000, ReplyTokens: 400},
+}
+
+// ChatService keeps the follow-up conversation attached to a daily draw
+// and asks the interpreter to continue it.
+type ChatService struct {
+	db          *sql.DB
+	interpreter Interpreter
+	limits      map[string]ChatLimits
+	usage       *UsageService
+	moderation  *ModerationService
+}
+
+func NewChatService(db *sql.DB, interpreter Interpreter) *ChatService {
+	return &ChatService{
+		db:          db,
+		interpreter: interpreter,
+		limits:      DefaultChatLimits,
+	}
+}
+
+// SetUsage meters answers and enforces the monthly quotas.
+func (s *ChatService) SetUsage(usage *UsageService) {
+	s.usage = usage
+}
+
+// SetModeration screens questions and answers.
+func (s *ChatService) SetModeration(moderation *ModerationService) {
+	s.moderation = moderation
+}
+
+// LimitsFor returns the chat limits of a subscription tier.
+func (s *ChatService) LimitsFor(tier string) ChatLimits {
+	if limits, exists := s.limits[tier]; exists {
+		return limits
+	}
+	return s.limits["free"]
+}
+
+// chatDraw is the draw a thread belongs to, with what the interpreter
+// needs to know about it.
+type chatDraw struct {
+	id          uuid.UUID
+	cardID      int
+	cardName    string
+	deck        string
+	orientation string
+	drawDate    string
+	mood        string
+	question    string
+	reading     string
+	persona     string
+}
+
+// GetThread returns the conversation about one of the user's draws. A draw
+// nobody has asked about yet has an empty thread.
+func (s *ChatService) GetThread(userID, drawID uuid.UUID) (*models.ChatThread, error) {
+	draw, err := s.findDraw(s.db, userID, drawID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messages(s.db, drawID)
+	if err != nil {
+		return nil, err
+	}
+
+	tier, err := s.tier(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.thread(draw, messages, s.LimitsFor(tier)), nil
+}
+
+// ListThreads returns a summary of every draw the user has asked about,
+// most recently active first.
+func (s *ChatService) ListThreads(userID uuid.UUID) ([]models.ChatThreadSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT d.id, d.card_name, d.draw_date,
+		       COUNT(*) FILTER (WHERE m.role = $2), MAX(m.created_at)
+		FROM reading_messages m
+		JOIN card_draws d ON d.id = m.draw_id
+		WHERE m.user_id = $1
+		GROUP BY d.id, d.card_name, d.draw_date
+		ORDER BY MAX(m.created_at) DESC
+	`, userID, ChatRoleUser)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []models.ChatThreadSummary{}
+	for rows.Next() {
+		var thread models.ChatThreadSummary
+		var drawDate time.Time
+		if err := rows.Scan(&thread.DrawID, &thread.CardName, &drawDate, &thread.Turns, &thread.LastMessageAt); err != nil {
+			return nil, err
+		}
+		thread.DrawDate = drawDate.Format(DateLayout)
+		threads = append(threads, thread)
+	}
+	return threads, rows.Err()
+}
+
+// Ask adds a question to the draw's thread and returns the answer. The
+// question is saved first so that concurrent questions count against the
+// limits; it is removed again if no interpreter could answer it or the
+// answer was withheld.
+func (s *ChatService) Ask(ctx context.Context, userID, drawID uuid.UUID, message string) (*models.ChatThread, error) {
+	if err := s.moderation.ScreenInput(ctx, userID, ModerationSourceChat, message); err != nil {
+		return nil, err
+	}
+	if err := s.usage.Check(userID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	draw, err := s.findDraw(tx, userID, drawID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	tier, err := s.tier(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	limits := s.LimitsFor(tier)
+
+	history, err := s.messages(tx, drawID)
+	if err != nil {
+		return nil, err
+	}
+
+	turns, tokens := threadUsage(history)
+	messageTokens := EstimateTokens(message)
+	if turns >= limits.Turns || tokens+messageTokens >= limits.Tokens {
+		return nil, ErrChatLimit.WithDetails(map[string]interface{}{
+			"limits":           limits,
+			"upgrade_required": tier != "premium",
+		})
+	}
+
+	var questionID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO reading_messages (draw_id, user_id, role, content, tokens)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, drawID, userID, ChatRoleUser, message, messageTokens).Scan(&questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	answer, err := s.followUp(ctx, draw, history, message, min(limits.ReplyTokens, limits.Tokens-tokens-messageTokens))
+	if err == nil {
+		s.usage.Record(userID, UsageFeatureChat, answer, time.Since(started))
+		err = s.moderation.ScreenOutput(ctx, userID, ModerationSourceChat, answer.Text)
+	}
+	if err != nil {
+		s.db.Exec("DELETE FROM reading_messages WHERE id = $1", questionID)
+		return nil, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO reading_messages (draw_id, user_id, role, content, provider, template, tokens)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+	`, drawID, userID, ChatRoleAssistant, answer.Text, answer.Provider, answer.Template, EstimateTokens(answer.Text))
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messages(s.db, drawID)
+	if err != nil {
+		return nil, err
+	}
+	return s.thread(draw, messages, limits), nil
+}
+
+func (s *ChatService) followUp(ctx context.Context, draw *chatDraw, history []models.ChatMessage, message string, maxTokens int) (*Interpretation, error) {
+	conversational, ok := s.interpreter.(ConversationalInterpreter)
+	if !ok {
+		return nil, ErrInterpretationUnavailable
+	}
+
+	deck, err := resolveDeck(draw.deck)
+	if err != nil {
+		return nil, err
+	}
+	card, exists := deck.Card(draw.cardID)
+	if !exists {
+		return nil, ErrCardNotFound
+	}
+
+	// Answer in the voice of the reading; a persona that has since been
+	// removed falls back to the default one
+	persona := draw.persona
+	if _, exists := prompts.GetPersona(persona); !exists {
+		persona = ""
+	}
+
+	turns := make([]ChatTurn, len(history))
+	for i, past := range history {
+		turns[i] = ChatTurn{Role: past.Role, Content: past.Content}
+	}
+
+	return conversational.FollowUp(ctx, FollowUpRequest{
+		InterpretationRequest: InterpretationRequest{
+			Card:        card,
+			Orientation: tarot.Orientation(draw.orientation),
+			Mood:        draw.mood,
+			Question:    draw.question,
+			Persona:     persona,
+		},
+		Reading:   draw.reading,
+		History:   turns,
+		Message:   message,
+		MaxTokens: maxTokens,
+	})
+}
+
+func (s *ChatService) thread(draw *chatDraw, messages []models.ChatMessage, limits ChatLimits) *models.ChatThread {
+	turns, tokens := threadUsage(messages)
+	return &models.ChatThread{
+		DrawID:     draw.id,
+		CardName:   draw.cardName,
+		DrawDate:   draw.drawDate,
+		Messages:   messages,
+		TurnsUsed:  turns,
+		TurnLimit:  limits.Turns,
+		TokensUsed: tokens,
+		TokenLimit: limits.Tokens,
+	}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// findDraw loads one of the user's draws. With lock set the row is locked
+// for the rest of the transaction, so questions about it run one at a time.
+func (s *ChatService) findDraw(q querier, userID, drawID uuid.UUID, lock bool) (*chatDraw, error) {
+	query := `
+		SELECT id, card_id, card_name, deck, orientation, draw_date,
+		       COALESCE(mood, ''), COALESCE(question, ''), COALESCE(interpretation_enhanced, ''),
+		       COALESCE(interpretation_persona, '')
+		FROM card_draws
+		WHERE id = $1 AND user_id = $2`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var draw chatDraw
+	var drawDate time.Time
+	err := q.QueryRow(query, drawID, userID).Scan(
+		&draw.id, &draw.cardID, &draw.cardName, &draw.deck, &draw.orientation, &drawDate,
+		&draw.mood, &draw.question, &draw.reading, &draw.persona,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrDrawNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	draw.drawDate = drawDate.Format(DateLayout)
+	return &draw, nil
+}
+
+func (s *ChatService) tier(q querier, userID uuid.UUID) (string, error) {
+	var tier string
+	err := q.QueryRow("SELECT COALESCE(subscription_tier, 'free') FROM users WHERE id = $1", userID).Scan(&tier)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return tier, err
+}
+
+func (s *ChatService) messages(q querier, drawID uuid.UUID) ([]models.ChatMessage, error) {
+	rows, err := q.Query(`
+		SELECT id, role, content, COALESCE(provider, ''), COALESCE(template, ''), tokens, created_at
+		FROM reading_messages
+		WHERE draw_id = $1
+		ORDER BY created_at, id
+	`, drawID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.ChatMessage{}
+	for rows.Next() {
+		var message models.ChatMessage
+		if err := rows.Scan(&message.ID, &message.Role, &message.Content, &message.Provider, &message.Template, &message.Tokens, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// threadUsage counts the questions asked and the tokens used in a thread.
+func threadUsage(messages []models.ChatMessage) (turns, tokens int) {
+	for _, message := range messages {
+		if message.Role == ChatRoleUser {
+			turns++
+		}
+		tokens += message.Tokens
+	}
+	return turns, tokens
+}
+
+// EstimateTokens approximates how many model tokens a text uses, at about
+// four characters per token.
+func EstimateTokens(text string) int {
+	return (len([]rune(text)) + 3) / 4
+}
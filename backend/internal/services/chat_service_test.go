@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/tarot"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{
+		"":                 0,
+		"Hi":               1,
+		"What about work?": 4,
+		"Ça va très bien":  4,
+	}
+	for text, want := range tests {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestThreadUsage(t *testing.T) {
+	turns, tokens := threadUsage([]models.ChatMessage{
+		{Role: ChatRoleUser, Tokens: 10},
+		{Role: ChatRoleAssistant, Tokens: 90},
+		{Role: ChatRoleUser, Tokens: 5},
+	})
+	if turns != 2 || tokens != 105 {
+		t.Errorf("Expected 2 turns and 105 tokens, got %d and %d", turns, tokens)
+	}
+}
+
+func TestChatService_LimitsFor(t *testing.T) {
+	service := NewChatService(nil, NewTemplateInterpreter())
+	if service.LimitsFor("premium").Turns <= service.LimitsFor("free").Turns {
+		t.Error("Expected premium users to get more turns than free users")
+	}
+	if service.LimitsFor("unknown") != service.LimitsFor("free") {
+		t.Error("Expected unknown tiers to get the free limits")
+	}
+}
+
+func TestChatService_Ask(t *testing.T) {
+	db, userID := testDatabase(t)
+
+	result, err := NewCardService(db).PerformDailyDraw(context.Background(), userID, "anxious", "What now?", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw returned error: %v", err)
+	}
+	drawID := result.Draw.ID
+
+	service := NewChatService(db, NewTemplateInterpreter())
+	limits := service.LimitsFor("free")
+
+	for i := 0; i < limits.Turns; i++ {
+		thread, err := service.Ask(context.Background(), userID, drawID, "What about my job interview?")
+		if err != nil {
+			t.Fatalf("Ask %d returned error: %v", i+1, err)
+		}
+		if len(thread.Messages) != 2*(i+1) || thread.TurnsUsed != i+1 {
+			t.Fatalf("Expected %d turns saved, got %+v", i+1, thread)
+		}
+	}
+
+	if _, err := service.Ask(context.Background(), userID, drawID, "One more?"); !errors.Is(err, ErrChatLimit) {
+		t.Errorf("Expected ErrChatLimit, got %v", err)
+	}
+
+	threads, err := service.ListThreads(userID)
+	if err != nil {
+		t.Fatalf("ListThreads returned error: %v", err)
+	}
+	if len(threads) != 1 || threads[0].DrawID != drawID || threads[0].Turns != limits.Turns {
+		t.Errorf("Unexpected threads: %+v", threads)
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Email tokens prove that the holder can read a user's mail. Like refresh
+// tokens they are random strings stored only as a SHA-256 hash. Each one
+// works once and only for its purpose, and issuing a new one replaces any
+// unused token of the same purpose.
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeVerifyEmail   = "verify_email"
+)
+
+// Lifetimes of the links sent by email.
+const (
+	DefaultPasswordResetTTL = time.Hour
+	DefaultVerificationTTL  = 48 * time.Hour
+)
+
+// emailTokenInterval is how long after sending a link another one is
+// withheld, so that the endpoints can't be used to flood an inbox.
+const emailTokenInterval = time.Minute
+
+// SetMailer sets how mail is delivered and the base URL of the web app,
+// which the links in the mail point to.
+func (s *AuthService) SetMailer(mailer Mailer, appURL string) {
+	s.mailer = mailer
+	s.appURL = appURL
+}
+
+// RequestPasswordReset mails a reset link if email belongs to a user.
+// Unknown addresses are ignored, so callers learn nothing about which
+// accounts exist.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	var userID uuid.UUID
+	err := s.db.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.issueEmailToken(userID, email, TokenPurposePasswordReset, DefaultPasswordResetTTL)
+	if err != nil || token == "" {
+		return err
+	}
+
+	s.deliver(MailMessage{
+		To:      email,
+		Subject: "Reset your Symbol Quest password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Symbol Quest account.\n\n"+
+			"To choose a new password, open this link within an hour:\n%s\n\n"+
+			"If it wasn't you, you can ignore this email; your password has not changed.\n",
+			s.link("/reset-password", token)),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token. It signs the user
+// out of every session, lifts a lockout and, since the link arrived by
+// email, counts as verifying the address.
+func (s *AuthService) ResetPassword(token, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, email, err := consumeEmailToken(tx, token, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE users SET password_hash = $1,
+		       email_verified_at = CASE WHEN email = $2 THEN COALESCE(email_verified_at, NOW()) ELSE email_verified_at END,
+		       updated_at = NOW()
+		WHERE id = $3
+	`, string(hashedPassword), email, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	if err := unlockAccount(tx, email); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SendVerification mails the user a link to verify their address. It does
+// nothing if the address is already verified.
+func (s *AuthService) SendVerification(userID uuid.UUID) error {
+	var email string
+	var verified bool
+	err := s.db.QueryRow("SELECT email, email_verified_at IS NOT NULL FROM users WHERE id = $1", userID).Scan(&email, &verified)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil || verified {
+		return err
+	}
+
+	token, err := s.issueEmailToken(userID, email, TokenPurposeVerifyEmail, DefaultVerificationTTL)
+	if err != nil || token == "" {
+		return err
+	}
+
+	s.deliver(MailMessage{
+		To:      email,
+		Subject: "Verify your Symbol Quest email",
+		Body: fmt.Sprintf("Welcome to Symbol Quest!\n\n"+
+			"Please confirm your email address by opening this link within 48 hours:\n%s\n\n"+
+			"If you didn't create an account, you can ignore this email.\n",
+			s.link("/verify-email", token)),
+	})
+	return nil
+}
+
+// VerifyEmail marks the user's address as verified with a verification
+// token. The token only counts for the address it was sent to.
+func (s *AuthService) VerifyEmail(token string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, email, err := consumeEmailToken(tx, token, TokenPurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1 AND email = $2
+	`, userID, email)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrInvalidLink
+	}
+	return tx.Commit()
+}
+
+// EmailVerified reports whether the user has verified their address.
+func (s *AuthService) EmailVerified(userID uuid.UUID) (bool, error) {
+	var verified bool
+	err := s.db.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1", userID).Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, ErrUserNotFound
+	}
+	return verified, err
+}
+
+// issueEmailToken stores a new token for purpose, replacing unused ones.
+// It returns an empty token if one was sent too recently.
+func (s *AuthService) issueEmailToken(userID uuid.UUID, email, purpose string, ttl time.Duration) (string, error) {
+	var recent bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM email_tokens
+			WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+		)
+	`, userID, purpose, time.Now().Add(-emailTokenInterval)).Scan(&recent)
+	if err != nil || recent {
+		return "", err
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL", userID, purpose); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO email_tokens (user_id, purpose, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, purpose, email, hashToken(token), time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// consumeEmailToken marks a token used and returns the user and address it
+// was issued for. Unknown, used and expired tokens give ErrInvalidLink.
+func consumeEmailToken(tx *sql.Tx, token, purpose string) (uuid.UUID, string, error) {
+	var tokenID, userID uuid.UUID
+	var email string
+	err := tx.QueryRow(`
+		SELECT id, user_id, email FROM email_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		FOR UPDATE
+	`, hashToken(token), purpose).Scan(&tokenID, &userID, &email)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, "", ErrInvalidLink
+	}
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	if _, err := tx.Exec("UPDATE email_tokens SET used_at = NOW() WHERE id = $1", tokenID); err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, email, nil
+}
+
+func (s *AuthService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// deliver sends msg in the background, so that slow mail servers don't
+// hold up requests and response times don't reveal whether an account
+// exists.
+func (s *AuthService) deliver(msg MailMessage) {
+	if s.mailer == nil {
+		log.Printf("no mailer configured: dropping %q to %s", msg.Subject, msg.To)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultMailTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
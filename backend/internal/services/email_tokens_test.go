@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"symbol-quest/internal/models"
+	"testing"
+	"time"
+)
+
+// fakeMailer hands sent messages to the test.
+type fakeMailer struct {
+	sent chan MailMessage
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg MailMessage) error {
+	m.sent <- msg
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// receiveToken waits for a message and returns the token in its link.
+func (m *fakeMailer) receiveToken(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		match := linkToken.FindStringSubmatch(msg.Body)
+		if match == nil {
+			t.Fatalf("Expected a link in the message, got %q", msg.Body)
+		}
+		token, _ := url.QueryUnescape(match[1])
+		return token
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a message to be sent")
+		return ""
+	}
+}
+
+func TestAuthService_EmailTokens(t *testing.T) {
+	db, userID := testDatabase(t)
+	email := userID.String() + "@example.com"
+	mailer := &fakeMailer{sent: make(chan MailMessage, 10)}
+	service := NewAuthService(db, "test-secret-key")
+	service.SetMailer(mailer, "https://app.example.com")
+
+	t.Run("Verify", func(t *testing.T) {
+		if verified, _ := service.EmailVerified(userID); verified {
+			t.Fatal("Expected a new user to be unverified")
+		}
+		if err := service.SendVerification(userID); err != nil {
+			t.Fatalf("SendVerification returned error: %v", err)
+		}
+		token := mailer.receiveToken(t)
+
+		if err := service.VerifyEmail("unknown"); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("Expected ErrInvalidLink, got %v", err)
+		}
+		if err := service.VerifyEmail(token); err != nil {
+			t.Fatalf("VerifyEmail returned error: %v", err)
+		}
+		if verified, _ := service.EmailVerified(userID); !verified {
+			t.Error("Expected the user to be verified")
+		}
+		if err := service.VerifyEmail(token); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("Expected a token to work once, got %v", err)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		session, err := service.StartSession(&models.User{ID: userID, Email: email, SubscriptionTier: "free"})
+		if err != nil {
+			t.Fatalf("StartSession returned error: %v", err)
+		}
+
+		if err := service.RequestPasswordReset(email); err != nil {
+			t.Fatalf("RequestPasswordReset returned error: %v", err)
+		}
+		token := mailer.receiveToken(t)
+
+		// A second request within the interval sends nothing
+		service.RequestPasswordReset(email)
+		if err := service.RequestPasswordReset("nobody-" + email); err != nil {
+			t.Errorf("Expected unknown addresses to be ignored, got %v", err)
+		}
+		select {
+		case msg := <-mailer.sent:
+			t.Errorf("Expected no more mail, got %q to %s", msg.Subject, msg.To)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if err := service.ResetPassword(token, "new-password-123"); err != nil {
+			t.Fatalf("ResetPassword returned error: %v", err)
+		}
+		if _, _, _, err := service.Login(email, "new-password-123", ""); err != nil {
+			t.Errorf("Expected the new password to work, got %v", err)
+		}
+		if _, err := service.Refresh(session.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected existing sessions to be revoked, got %v", err)
+		}
+		if err := service.ResetPassword(token, "another-password"); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("Expected a token to work once, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		db.Exec("DELETE FROM email_tokens WHERE user_id = $1", userID)
+		service.RequestPasswordReset(email)
+		token := mailer.receiveToken(t)
+		db.Exec("UPDATE email_tokens SET expires_at = NOW() - INTERVAL '1 minute' WHERE token_hash = $1", hashToken(token))
+		if err := service.ResetPassword(token, "new-password-456"); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("Expected an expired token to be rejected, got %v", err)
+		}
+	})
+}
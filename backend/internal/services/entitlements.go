@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultEntitlementCacheTTL is how long a user's tier is cached. It bounds
+// how stale the tier can be on instances that did not receive the webhook.
+const DefaultEntitlementCacheTTL = 30 * time.Second
+
+// maxCachedEntitlements is the cache size above which expired entries are
+// swept out.
+const maxCachedEntitlements = 10000
+
+// EntitlementService resolves what a user may access from their
+// subscription tier in the database, not from the tier in their token.
+// Tiers are cached in process for a short time; the Stripe webhooks call
+// Invalidate so that changes apply on the next request.
+type EntitlementService struct {
+	db    *sql.DB
+	ttl   time.Duration
+	mu    sync.Mutex
+	cache map[uuid.UUID]cachedTier
+	// invalidations counts calls to Invalidate, so that a tier loaded
+	// before one is not cached after it
+	invalidations uint64
+	load          func(uuid.UUID) (string, error)
+	now           func() time.Time
+}
+
+type cachedTier struct {
+	tier    string
+	expires time.Time
+}
+
+// NewEntitlementService caches tiers for ttl. A ttl of zero turns the
+// cache off.
+func NewEntitlementService(db *sql.DB, ttl time.Duration) *EntitlementService {
+	s := &EntitlementService{
+		db:    db,
+		ttl:   max(0, ttl),
+		cache: make(map[uuid.UUID]cachedTier),
+		now:   time.Now,
+	}
+	s.load = s.loadTier
+	return s
+}
+
+// SetLoader replaces the database lookup of a user's tier, e.g. in tests.
+func (s *EntitlementService) SetLoader(load func(userID uuid.UUID) (string, error)) {
+	s.load = load
+}
+
+// Tier returns the user's current subscription tier.
+func (s *EntitlementService) Tier(userID uuid.UUID) (string, error) {
+	now := s.now()
+	s.mu.Lock()
+	cached, exists := s.cache[userID]
+	invalidations := s.invalidations
+	s.mu.Unlock()
+	if exists && now.Before(cached.expires) {
+		return cached.tier, nil
+	}
+
+	tier, err := s.load(userID)
+	if err != nil {
+		return "", err
+	}
+	if s.ttl > 0 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.invalidations != invalidations {
+			return tier, nil
+		}
+		if len(s.cache) >= maxCachedEntitlements {
+			s.sweep(now)
+		}
+		s.cache[userID] = cachedTier{tier: tier, expires: now.Add(s.ttl)}
+	}
+	return tier, nil
+}
+
+// IsPremium reports whether the user currently has premium access.
+func (s *EntitlementService) IsPremium(userID uuid.UUID) (bool, error) {
+	tier, err := s.Tier(userID)
+	return tier == "premium", err
+}
+
+// Invalidate drops the user's cached tier after it changed.
+func (s *EntitlementService) Invalidate(userID uuid.UUID) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.cache, userID)
+	s.invalidations++
+	s.mu.Unlock()
+}
+
+// sweep removes expired entries. The caller holds s.mu.
+func (s *EntitlementService) sweep(now time.Time) {
+	for userID, cached := range s.cache {
+		if !now.Before(cached.expires) {
+			delete(s.cache, userID)
+		}
+	}
+}
+
+func (s *EntitlementService) loadTier(userID uuid.UUID) (string, error) {
+	var tier string
+	err := s.db.QueryRow("SELECT COALESCE(subscription_tier, 'free') FROM users WHERE id = $1", userID).Scan(&tier)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return tier, err
+}
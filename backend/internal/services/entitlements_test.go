@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	stripeapi "github.com/stripe/stripe-go/v76"
+)
+
+func TestEntitlementService_Cache(t *testing.T) {
+	userID := uuid.New()
+	tier, loads := "free", 0
+	now := time.Now()
+
+	entitlements := NewEntitlementService(nil, time.Minute)
+	entitlements.now = func() time.Time { return now }
+	entitlements.SetLoader(func(uuid.UUID) (string, error) {
+		loads++
+		return tier, nil
+	})
+
+	check := func(wantTier string, wantLoads int) {
+		t.Helper()
+		got, err := entitlements.Tier(userID)
+		if err != nil {
+			t.Fatalf("Tier returned error: %v", err)
+		}
+		if got != wantTier || loads != wantLoads {
+			t.Errorf("Expected %s after %d loads, got %s after %d", wantTier, wantLoads, got, loads)
+		}
+	}
+
+	check("free", 1)
+	tier = "premium"
+	check("free", 1)
+
+	entitlements.Invalidate(userID)
+	check("premium", 2)
+
+	now = now.Add(time.Minute)
+	check("premium", 3)
+
+	if premium, _ := entitlements.IsPremium(userID); !premium {
+		t.Error("Expected the user to be premium")
+	}
+}
+
+func TestEntitlementService_InvalidatedDuringLoad(t *testing.T) {
+	userID := uuid.New()
+	entitlements := NewEntitlementService(nil, time.Minute)
+
+	// A webhook lands while the old tier is being read
+	tier := "premium"
+	entitlements.SetLoader(func(uuid.UUID) (string, error) {
+		loaded := tier
+		tier = "free"
+		entitlements.Invalidate(userID)
+		return loaded, nil
+	})
+	entitlements.Tier(userID)
+
+	if got, _ := entitlements.Tier(userID); got != "free" {
+		t.Errorf("Expected the stale tier not to be cached, got %s", got)
+	}
+}
+
+func TestEntitlementService_NoCache(t *testing.T) {
+	loads := 0
+	entitlements := NewEntitlementService(nil, 0)
+	entitlements.SetLoader(func(uuid.UUID) (string, error) {
+		loads++
+		return "premium", nil
+	})
+
+	userID := uuid.New()
+	entitlements.Tier(userID)
+	entitlements.Tier(userID)
+	if loads != 2 {
+		t.Errorf("Expected every lookup to load the tier, got %d loads", loads)
+	}
+
+	var disabled *EntitlementService
+	disabled.Invalidate(userID)
+}
+
+func TestEntitlementService_Database(t *testing.T) {
+	db, userID := testDatabase(t)
+	entitlements := NewEntitlementService(db, time.Minute)
+
+	if premium, err := entitlements.IsPremium(userID); err != nil || premium {
+		t.Fatalf("Expected a free user, got %v, %v", premium, err)
+	}
+
+	stripe := NewStripeService("")
+	stripe.SetDatabase(db)
+	stripe.SetEntitlements(entitlements)
+	if err := stripe.setTier(userID, "premium"); err != nil {
+		t.Fatalf("setTier returned error: %v", err)
+	}
+	if premium, _ := entitlements.IsPremium(userID); !premium {
+		t.Error("Expected the upgrade to apply immediately")
+	}
+
+	if err := stripe.setTier(userID, "free"); err != nil {
+		t.Fatalf("setTier returned error: %v", err)
+	}
+	if premium, _ := entitlements.IsPremium(userID); premium {
+		t.Error("Expected the cancellation to apply immediately")
+	}
+
+	if _, err := entitlements.Tier(uuid.New()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestStripeService_SecondSubscription(t *testing.T) {
+	db, userID := testDatabase(t)
+	entitlements := NewEntitlementService(db, time.Minute)
+	stripe := NewStripeService("")
+	stripe.SetDatabase(db)
+	stripe.SetEntitlements(entitlements)
+
+	subscription := func(status stripeapi.SubscriptionStatus) *stripeapi.Subscription {
+		return &stripeapi.Subscription{
+			ID:       "sub_" + uuid.NewString(),
+			Customer: &stripeapi.Customer{ID: "cus_test"},
+			Status:   status,
+			Metadata: map[string]string{"user_id": userID.String()},
+		}
+	}
+
+	active := subscription(stripeapi.SubscriptionStatusActive)
+	if err := stripe.handleSubscriptionCreated(active); err != nil {
+		t.Fatalf("handleSubscriptionCreated returned error: %v", err)
+	}
+	if premium, _ := entitlements.IsPremium(userID); !premium {
+		t.Fatal("Expected an active subscription to make the user premium")
+	}
+
+	incomplete := subscription(stripeapi.SubscriptionStatusIncomplete)
+	if err := stripe.handleSubscriptionCreated(incomplete); err != nil {
+		t.Fatalf("handleSubscriptionCreated returned error: %v", err)
+	}
+	if premium, _ := entitlements.IsPremium(userID); !premium {
+		t.Error("Expected an incomplete second subscription not to downgrade the user")
+	}
+
+	if err := stripe.handleSubscriptionDeleted(incomplete); err != nil {
+		t.Fatalf("handleSubscriptionDeleted returned error: %v", err)
+	}
+	if premium, _ := entitlements.IsPremium(userID); !premium {
+		t.Error("Expected the active subscription to keep the user premium")
+	}
+
+	if err := stripe.handleSubscriptionDeleted(active); err != nil {
+		t.Fatalf("handleSubscriptionDeleted returned error: %v", err)
+	}
+	if premium, _ := entitlements.IsPremium(userID); premium {
+		t.Error("Expected the user to be free once no subscription is active")
+	}
+}
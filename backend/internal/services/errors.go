@@ -0,0 +1,129 @@
+package services
+
+// Error codes are stable, machine-readable identifiers that clients can
+// switch on. They are sent as the "code" field of every error response, so
+// existing codes must never be renamed.
+const (
+	CodeNotFound                  = "not_found"
+	CodeAlreadyDrawn              = "already_drawn"
+	CodeDailyLimit                = "daily_limit_reached"
+	CodeUserExists                = "user_exists"
+	CodeInvalidCredentials        = "invalid_credentials"
+	CodeInvalidToken              = "invalid_token"
+	CodePremiumRequired           = "premium_required"
+	CodeUnknownDeck               = "unknown_deck"
+	CodeUnknownSelector           = "unknown_selector"
+	CodeUnknownPersona            = "unknown_persona"
+	CodeInvalidTimezone           = "invalid_timezone"
+	CodeInvalidSpread             = "invalid_spread"
+	CodeNotVerifiable             = "not_verifiable"
+	CodeNoExplanation             = "no_explanation"
+	CodeInterpretationUnavailable = "interpretation_unavailable"
+	CodeRegenerationLimit         = "regeneration_limit_reached"
+	CodeChatLimit                 = "chat_limit_reached"
+	CodeUsageQuota                = "usage_quota_exceeded"
+	CodeAdminRequired             = "admin_required"
+	CodeQuestionTooLong           = "question_too_long"
+	CodeContentBlocked            = "content_blocked"
+	CodeCrisisSupport             = "crisis_support"
+	CodeContentWithheld           = "content_withheld"
+	CodeRefreshTokenReused        = "refresh_token_reused"
+	CodeInvalidLink               = "invalid_link"
+	CodeEmailNotVerified          = "email_not_verified"
+	CodeTooManyAttempts           = "too_many_attempts"
+	CodeAccountLocked             = "account_locked"
+	CodeInvalidMFACode            = "invalid_mfa_code"
+	CodeMFAAlreadyEnabled         = "mfa_already_enabled"
+	CodeMFANotEnabled             = "mfa_not_enabled"
+)
+
+// Error is a domain error with a stable code. Handlers return it unchanged
+// and middleware.ErrorHandler turns it into an HTTP status and JSON body.
+type Error struct {
+	Code    string
+	Message string
+	// Details are extra fields added to the error response, such as the
+	// card a user has already drawn.
+	Details map[string]interface{}
+
+	parent *Error
+}
+
+func newError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap makes a narrower error match its parent with errors.Is, so that
+// ErrDrawNotFound is also ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+// WithDetails returns a copy of the error carrying extra response fields.
+// The copy still matches e with errors.Is.
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+	return &Error{Code: e.Code, Message: e.Message, Details: details, parent: e}
+}
+
+// withMessage derives a narrower error with the same code.
+func (e *Error) withMessage(message string) *Error {
+	return &Error{Code: e.Code, Message: message, Details: e.Details, parent: e}
+}
+
+var (
+	ErrNotFound        = newError(CodeNotFound, "not found")
+	ErrUserNotFound    = ErrNotFound.withMessage("user not found")
+	ErrCardNotFound    = ErrNotFound.withMessage("card not found")
+	ErrDrawNotFound    = ErrNotFound.withMessage("draw not found")
+	ErrSpreadNotFound  = ErrNotFound.withMessage("spread not found")
+	ErrReadingNotFound = ErrNotFound.withMessage("reading not found")
+	ErrNoSubscription  = ErrNotFound.withMessage("no active subscription found")
+
+	ErrAlreadyDrawn = newError(CodeAlreadyDrawn, "daily draw already completed")
+	ErrDailyLimit   = &Error{
+		Code:    CodeDailyLimit,
+		Message: "daily limit reached - upgrade to premium for unlimited draws",
+		Details: map[string]interface{}{"upgrade_required": true},
+	}
+
+	ErrUserExists         = newError(CodeUserExists, "user already exists")
+	ErrInvalidCredentials = newError(CodeInvalidCredentials, "invalid credentials")
+	ErrInvalidToken       = newError(CodeInvalidToken, "invalid token")
+	ErrRefreshTokenReused = newError(CodeRefreshTokenReused, "refresh token was already used - please log in again")
+	ErrPremiumRequired    = newError(CodePremiumRequired, "premium subscription required")
+	ErrInvalidLink        = newError(CodeInvalidLink, "this link is invalid or has expired")
+	ErrEmailNotVerified   = newError(CodeEmailNotVerified, "please verify your email address first")
+	ErrTooManyAttempts    = newError(CodeTooManyAttempts, "too many failed login attempts - please wait before trying again")
+	ErrAccountLocked      = newError(CodeAccountLocked, "account locked after too many failed login attempts - check your email to unlock it")
+	ErrInvalidMFACode     = newError(CodeInvalidMFACode, "invalid authentication code")
+	ErrMFAAlreadyEnabled  = newError(CodeMFAAlreadyEnabled, "two-factor authentication is already enabled")
+	ErrMFANotEnabled      = newError(CodeMFANotEnabled, "two-factor authentication is not enabled")
+	ErrMFANotSetUp        = ErrMFANotEnabled.withMessage("two-factor setup has not been started")
+
+	ErrDeckNotFound      = newError(CodeUnknownDeck, "deck not found")
+	ErrUnknownSelector   = newError(CodeUnknownSelector, "unknown selection strategy")
+	ErrUnknownPersona    = newError(CodeUnknownPersona, "unknown reader persona")
+	ErrInvalidTimezone   = newError(CodeInvalidTimezone, "invalid timezone")
+	ErrInvalidSpread     = newError(CodeInvalidSpread, "invalid spread")
+	ErrDrawNotVerifiable = newError(CodeNotVerifiable, "draw was made before seeds were recorded")
+	ErrNoExplanation     = newError(CodeNoExplanation, "draw was made before explanations were recorded")
+
+	ErrInterpretationUnavailable = newError(CodeInterpretationUnavailable, "no interpreter could produce a reading")
+	ErrRegenerationLimit         = newError(CodeRegenerationLimit, "daily regeneration limit reached")
+	ErrChatLimit                 = newError(CodeChatLimit, "this reading's chat has reached its limit")
+	ErrUsageQuota                = newError(CodeUsageQuota, "monthly AI usage quota reached")
+	ErrAdminRequired             = newError(CodeAdminRequired, "admin access required")
+
+	ErrQuestionTooLong = newError(CodeQuestionTooLong, "question is too long")
+	ErrContentBlocked  = newError(CodeContentBlocked, "this question can't be used for a reading")
+	ErrCrisisSupport   = newError(CodeCrisisSupport, "please reach out for support")
+	ErrContentWithheld = newError(CodeContentWithheld, "the reading was withheld by content checks")
+	ErrFlagNotFound    = ErrNotFound.withMessage("moderation flag not found")
+)
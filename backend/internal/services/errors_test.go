@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorMatching(t *testing.T) {
+	if !errors.Is(ErrDrawNotFound, ErrNotFound) {
+		t.Error("Expected ErrDrawNotFound to match ErrNotFound")
+	}
+	if errors.Is(ErrDrawNotFound, ErrSpreadNotFound) {
+		t.Error("Expected distinct not-found errors not to match each other")
+	}
+
+	detailed := ErrAlreadyDrawn.WithDetails(map[string]interface{}{"card": 0})
+	if !errors.Is(detailed, ErrAlreadyDrawn) {
+		t.Error("Expected an error with details to match its original")
+	}
+	if ErrAlreadyDrawn.Details != nil {
+		t.Error("Expected WithDetails to leave the original unchanged")
+	}
+
+	var domainErr *Error
+	wrapped := fmt.Errorf("%w: bad positions", ErrInvalidSpread)
+	if !errors.As(wrapped, &domainErr) || domainErr.Code != CodeInvalidSpread {
+		t.Errorf("Expected a wrapped domain error to keep its code, got %v", domainErr)
+	}
+}
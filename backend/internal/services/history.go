@@ -0,0 +1,205 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"symbol-quest/internal/prompts"
+	"symbol-quest/internal/tarot"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bounds on how much of a user's history goes into a reading.
+const (
+	DefaultHistoryLimit = 10
+	MaxHistoryLimit     = 50
+	// listedReadings is how many past readings are listed one by one; the
+	// rest only count towards the patterns.
+	listedReadings = 5
+)
+
+// moodValence places the moods a user can pick on a scale from -1 to 1 so
+// that a change in mood can be described.
+var moodValence = map[string]float64{
+	"anxious":       -1,
+	"frustrated":    -1,
+	"uncertain":     -0.5,
+	"curious":       0.5,
+	"contemplative": 0,
+	"hopeful":       1,
+	"excited":       1,
+	"peaceful":      1,
+}
+
+// pastDraw is one earlier daily draw with its card resolved.
+type pastDraw struct {
+	date        string
+	card        tarot.Card
+	orientation tarot.Orientation
+	mood        string
+}
+
+// loadHistory returns the user's most recent daily draws before drawDate,
+// newest first, or nil if they turned history off. An empty drawDate
+// includes every draw.
+func loadHistory(q querier, userID uuid.UUID, drawDate string, limit int) ([]pastDraw, error) {
+	var enabled bool
+	err := q.QueryRow("SELECT history_in_readings FROM users WHERE id = $1", userID).Scan(&enabled)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	query := `
+		SELECT card_id, deck, orientation, draw_date, COALESCE(mood, '')
+		FROM card_draws
+		WHERE user_id = $1 AND kind = $2`
+	args := []interface{}{userID, DrawKindDaily}
+	if drawDate != "" {
+		query += " AND draw_date < $3"
+		args = append(args, drawDate)
+	}
+	args = append(args, limit)
+	query += " ORDER BY draw_date DESC, created_at DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []pastDraw
+	for rows.Next() {
+		var cardID int
+		var deckName, orientation, mood string
+		var date time.Time
+		if err := rows.Scan(&cardID, &deckName, &orientation, &date, &mood); err != nil {
+			return nil, err
+		}
+
+		// Draws from a deck that is no longer loaded are left out
+		deck, err := resolveDeck(deckName)
+		if err != nil {
+			continue
+		}
+		card, exists := deck.Card(cardID)
+		if !exists {
+			continue
+		}
+		history = append(history, pastDraw{
+			date:        date.Format(DateLayout),
+			card:        card,
+			orientation: tarot.Orientation(orientation),
+			mood:        mood,
+		})
+	}
+	return history, rows.Err()
+}
+
+// summarizeHistory lists the newest past draws and describes the patterns
+// across all of them. history is ordered newest first.
+func summarizeHistory(current tarot.Card, history []pastDraw) ([]prompts.PastReading, *prompts.HistoryPatterns) {
+	if len(history) == 0 {
+		return nil, nil
+	}
+
+	listed := make([]prompts.PastReading, 0, min(len(history), listedReadings))
+	for _, draw := range history[:min(len(history), listedReadings)] {
+		listed = append(listed, prompts.PastReading{
+			Date:        draw.date,
+			Card:        draw.card.Name,
+			Orientation: string(draw.orientation),
+			Mood:        draw.mood,
+		})
+	}
+
+	patterns := &prompts.HistoryPatterns{Readings: len(history)}
+	cards := make(map[string]int)
+	elements := make(map[string]int)
+	moods := make(map[string]int)
+	for _, draw := range history {
+		cards[draw.card.Name]++
+		if draw.card.Name == current.Name {
+			patterns.CurrentCard++
+		}
+		if draw.card.Arcana == tarot.ArcanaMajor {
+			patterns.MajorArcana++
+		}
+		if len(draw.card.Elements) > 0 {
+			elements[strings.ToLower(draw.card.Elements[0])]++
+		}
+		if draw.mood != "" {
+			moods[strings.ToLower(draw.mood)]++
+		}
+	}
+
+	for name, count := range cards {
+		if count > 1 {
+			patterns.RepeatedCards = append(patterns.RepeatedCards, prompts.CardCount{Card: name, Count: count})
+		}
+	}
+	sort.Slice(patterns.RepeatedCards, func(i, j int) bool {
+		a, b := patterns.RepeatedCards[i], patterns.RepeatedCards[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Card < b.Card
+	})
+
+	patterns.DominantElement = mostFrequent(elements)
+	patterns.CommonMood = mostFrequent(moods)
+	patterns.MoodTrend = moodTrend(history)
+	return listed, patterns
+}
+
+// mostFrequent returns the key counted most often, or "" when it was seen
+// only once or shares first place.
+func mostFrequent(counts map[string]int) string {
+	best, bestCount, tied := "", 0, false
+	for key, count := range counts {
+		switch {
+		case count > bestCount:
+			best, bestCount, tied = key, count, false
+		case count == bestCount:
+			tied = true
+		}
+	}
+	if bestCount < 2 || tied {
+		return ""
+	}
+	return best
+}
+
+// moodTrend compares the moods of the newer half of the history with the
+// older half. It needs at least four readings with a known mood.
+func moodTrend(history []pastDraw) string {
+	var values []float64
+	for _, draw := range history {
+		if value, exists := moodValence[strings.ToLower(draw.mood)]; exists {
+			values = append(values, value)
+		}
+	}
+	if len(values) < 4 {
+		return ""
+	}
+
+	half := len(values) / 2
+	change := average(values[:half]) - average(values[len(values)-half:])
+	switch {
+	case change >= 0.5:
+		return "brightening"
+	case change <= -0.5:
+		return "darkening"
+	default:
+		return "steady"
+	}
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
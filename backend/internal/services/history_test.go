@@ -0,0 +1,98 @@
+package services
+
+import (
+	"symbol-quest/internal/tarot"
+	"testing"
+)
+
+func TestSummarizeHistory(t *testing.T) {
+	card := func(id int) tarot.Card {
+		c, _ := tarot.GetCard(id)
+		return c
+	}
+	tower := card(16)
+
+	if listed, patterns := summarizeHistory(tower, nil); listed != nil || patterns != nil {
+		t.Errorf("Expected no summary without history, got %v, %+v", listed, patterns)
+	}
+
+	// Newest first: moods lift from anxious to hopeful
+	history := []pastDraw{
+		{date: "2024-03-08", card: tower, orientation: tarot.OrientationUpright, mood: "hopeful"},
+		{date: "2024-03-07", card: card(17), orientation: tarot.OrientationUpright, mood: "excited"},
+		{date: "2024-03-06", card: tower, orientation: tarot.OrientationReversed, mood: "peaceful"},
+		{date: "2024-03-05", card: card(17), orientation: tarot.OrientationUpright},
+		{date: "2024-03-04", card: card(0), orientation: tarot.OrientationUpright, mood: "anxious"},
+		{date: "2024-03-03", card: tower, orientation: tarot.OrientationUpright, mood: "anxious"},
+		{date: "2024-03-02", card: card(22), orientation: tarot.OrientationUpright, mood: "frustrated"},
+	}
+
+	listed, patterns := summarizeHistory(tower, history)
+	if len(listed) != listedReadings || listed[0].Date != "2024-03-08" || listed[0].Card != "The Tower" || listed[0].Mood != "hopeful" {
+		t.Errorf("Expected the newest %d readings listed, got %+v", listedReadings, listed)
+	}
+
+	if patterns.Readings != 7 || patterns.CurrentCard != 3 || patterns.MajorArcana != 6 {
+		t.Errorf("Unexpected counts: %+v", patterns)
+	}
+	if len(patterns.RepeatedCards) != 2 || patterns.RepeatedCards[0].Card != "The Tower" || patterns.RepeatedCards[0].Count != 3 ||
+		patterns.RepeatedCards[1].Card != "The Star" {
+		t.Errorf("Expected The Tower then The Star as repeated cards, got %+v", patterns.RepeatedCards)
+	}
+	if patterns.DominantElement != "fire" {
+		t.Errorf("Expected fire as the dominant element, got %q", patterns.DominantElement)
+	}
+	if patterns.CommonMood != "anxious" || patterns.MoodTrend != "brightening" {
+		t.Errorf("Expected anxious moods brightening, got %q and %q", patterns.CommonMood, patterns.MoodTrend)
+	}
+}
+
+func TestMostFrequent(t *testing.T) {
+	tests := []struct {
+		counts map[string]int
+		want   string
+	}{
+		{map[string]int{"fire": 3, "water": 1}, "fire"},
+		{map[string]int{"fire": 2, "water": 2}, ""},
+		{map[string]int{"fire": 1}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := mostFrequent(test.counts); got != test.want {
+			t.Errorf("mostFrequent(%v) = %q, want %q", test.counts, got, test.want)
+		}
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	db, userID := testDatabase(t)
+
+	for i, date := range []string{"2024-03-01", "2024-03-02", "2024-03-03"} {
+		_, err := db.Exec(`
+			INSERT INTO card_draws (user_id, card_id, card_name, deck, orientation, draw_date, kind, mood)
+			VALUES ($1, $2, 'x', $3, 'upright', $4, $5, 'hopeful')
+		`, userID, i, tarot.DefaultDeckName, date, DrawKindDaily)
+		if err != nil {
+			t.Fatalf("Failed to insert draw: %v", err)
+		}
+	}
+
+	history, err := loadHistory(db, userID, "2024-03-03", 10)
+	if err != nil {
+		t.Fatalf("loadHistory returned error: %v", err)
+	}
+	if len(history) != 2 || history[0].date != "2024-03-02" || history[0].card.ID != 1 || history[0].mood != "hopeful" {
+		t.Errorf("Expected the two earlier draws, newest first, got %+v", history)
+	}
+
+	if history, _ := loadHistory(db, userID, "", 1); len(history) != 1 || history[0].date != "2024-03-03" {
+		t.Errorf("Expected the limit to keep the newest draw, got %+v", history)
+	}
+
+	if _, err := db.Exec("UPDATE users SET history_in_readings = FALSE WHERE id = $1", userID); err != nil {
+		t.Fatal(err)
+	}
+	if history, err := loadHistory(db, userID, "", 10); err != nil || history != nil {
+		t.Errorf("Expected no history after opting out, got %+v, %v", history, err)
+	}
+}
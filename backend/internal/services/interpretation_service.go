@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"symbol-quest/internal/prompts"
+	"symbol-quest/internal/tarot"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRegenerationsPerDay is how many stored readings a user may replace
+// each day unless SetRegenerationLimit says otherwise.
+const DefaultRegenerationsPerDay = 3
+
+// InterpretationOptions ties an interpretation to the user's daily draw.
+type InterpretationOptions struct {
+	// DrawDate names the daily draw the reading belongs to. Without it the
+	// reading is neither looked up nor saved, so the API always sets it;
+	// see DrawRequest.
+	DrawDate string
+	Deck     string
+	// Regenerate replaces a stored reading and uses up one of the day's
+	// regenerations.
+	Regenerate bool
+	// Timezone is the client's timezone, used for the regeneration day when
+	// the user has not stored one.
+	Timezone string
+}
+
+// InterpretationService serves enhanced interpretations. Readings saved on
+// a draw are returned as they are, and identical requests that arrive
+// while a reading is being written share the one upstream call.
+type InterpretationService struct {
+	db                  *sql.DB
+	interpreter         Interpreter
+	regenerationsPerDay int
+	historyLimit        int
+	usage               *UsageService
+	moderation          *ModerationService
+	flights             flightGroup
+}
+
+func NewInterpretationService(db *sql.DB, interpreter Interpreter) *InterpretationService {
+	return &InterpretationService{
+		db:                  db,
+		interpreter:         interpreter,
+		regenerationsPerDay: DefaultRegenerationsPerDay,
+	}
+}
+
+// SetRegenerationLimit sets how many readings a user may regenerate per
+// day. Zero disables regeneration.
+func (s *InterpretationService) SetRegenerationLimit(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	s.regenerationsPerDay = limit
+}
+
+// SetHistoryLimit sets how many of the user's past draws new readings take
+// into account, up to MaxHistoryLimit. Zero leaves history out.
+func (s *InterpretationService) SetHistoryLimit(limit int) {
+	s.historyLimit = max(0, min(limit, MaxHistoryLimit))
+}
+
+// SetUsage meters new readings and enforces the monthly quotas.
+func (s *InterpretationService) SetUsage(usage *UsageService) {
+	s.usage = usage
+}
+
+// SetModeration screens questions and new readings.
+func (s *InterpretationService) SetModeration(moderation *ModerationService) {
+	s.moderation = moderation
+}
+
+func (s *InterpretationService) Interpret(ctx context.Context, userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions) (*Interpretation, error) {
+	req, err := withPersona(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.interpret(ctx, userID, req, opts, func(req InterpretationRequest) (*Interpretation, error) {
+		return s.interpreter.Interpret(ctx, req)
+	})
+}
+
+// InterpretStream streams a new reading to onToken. A stored reading, or
+// one written for an identical request, is sent as a single token.
+func (s *InterpretationService) InterpretStream(ctx context.Context, userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions, onToken func(string) error) (*Interpretation, error) {
+	req, err := withPersona(req)
+	if err != nil {
+		return nil, err
+	}
+
+	streamed := false
+	stream := s.moderation.newOutputStream(ctx, userID, ModerationSourceInterpretation, onToken)
+	interpretation, err := s.interpret(ctx, userID, req, opts, func(req InterpretationRequest) (*Interpretation, error) {
+		streamed = true
+		return Stream(ctx, s.interpreter, req, stream.write)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if streamed {
+		if err := stream.finish(); err != nil {
+			return nil, err
+		}
+		return interpretation, nil
+	}
+	if err := onToken(interpretation.Text); err != nil {
+		return nil, err
+	}
+	return interpretation, nil
+}
+
+func (s *InterpretationService) interpret(ctx context.Context, userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions, generate func(InterpretationRequest) (*Interpretation, error)) (*Interpretation, error) {
+	if err := s.moderation.ScreenQuestion(ctx, userID, ModerationSourceInterpretation, req.Question); err != nil {
+		return nil, err
+	}
+
+	if opts.DrawDate != "" && !opts.Regenerate {
+		stored, err := s.stored(userID, req, opts)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			return stored, nil
+		}
+	}
+
+	return s.flights.do(ctx, flightKey(userID, req, opts), func() (*Interpretation, error) {
+		if err := s.usage.Check(userID); err != nil {
+			return nil, err
+		}
+		if opts.DrawDate != "" && opts.Regenerate {
+			if err := s.useRegeneration(userID, req, opts); err != nil {
+				return nil, err
+			}
+		}
+
+		started := time.Now()
+		interpretation, err := generate(s.withHistory(userID, req, opts))
+		if err != nil {
+			return nil, err
+		}
+		s.usage.Record(userID, UsageFeatureReading, interpretation, time.Since(started))
+
+		// A withheld reading is not saved. A streamed one has been screened
+		// in batches of sentences as it was sent, and its last part is
+		// only sent once this passes
+		if err := s.moderation.ScreenOutput(ctx, userID, ModerationSourceInterpretation, interpretation.Text); err != nil {
+			return nil, err
+		}
+
+		if opts.DrawDate != "" {
+			// The user still gets their interpretation if it cannot be saved
+			if err := s.save(userID, req, opts, interpretation); err != nil {
+				log.Printf("save interpretation: %v", err)
+			}
+		}
+		return interpretation, nil
+	})
+}
+
+// DrawRequest returns the reading request for the user's daily draw on
+// drawDate, with the card, orientation, mood and question of that draw,
+// and the draw's deck. Readings are always about a stored draw, so that
+// stored readings and the regeneration limit cannot be sidestepped by
+// naming another card or leaving the draw out.
+func (s *InterpretationService) DrawRequest(userID uuid.UUID, drawDate string) (InterpretationRequest, string, error) {
+	if _, err := time.Parse(DateLayout, drawDate); err != nil {
+		return InterpretationRequest{}, "", ErrDrawNotFound
+	}
+
+	var cardID int
+	var deckName, orientation, mood, question string
+	err := s.db.QueryRow(`
+		SELECT card_id, deck, orientation, COALESCE(mood, ''), COALESCE(question, '')
+		FROM card_draws
+		WHERE user_id = $1 AND draw_date = $2 AND kind = $3
+	`, userID, drawDate, DrawKindDaily).Scan(&cardID, &deckName, &orientation, &mood, &question)
+	if err == sql.ErrNoRows {
+		return InterpretationRequest{}, "", ErrDrawNotFound
+	}
+	if err != nil {
+		return InterpretationRequest{}, "", err
+	}
+
+	// A draw from a deck that is no longer loaded is read from the
+	// default deck, as for the draw history
+	deck, exists := tarot.GetDeck(deckName)
+	if !exists {
+		deck, _ = tarot.GetDeck(tarot.DefaultDeckName)
+	}
+	card, exists := deck.Card(cardID)
+	if !exists {
+		return InterpretationRequest{}, "", ErrCardNotFound
+	}
+
+	return InterpretationRequest{
+		Card:        card,
+		Orientation: tarot.Orientation(orientation),
+		Mood:        mood,
+		Question:    question,
+	}, deckName, nil
+}
+
+// withPersona resolves the request's persona, so that the default persona
+// and its name share stored readings.
+func withPersona(req InterpretationRequest) (InterpretationRequest, error) {
+	persona, exists := prompts.GetPersona(req.Persona)
+	if !exists {
+		return req, ErrUnknownPersona
+	}
+	req.Persona = persona.Name
+	return req, nil
+}
+
+// withHistory adds the user's recent draws to the request. A reading is
+// still written without them if they cannot be loaded.
+func (s *InterpretationService) withHistory(userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions) InterpretationRequest {
+	if s.historyLimit == 0 {
+		return req
+	}
+
+	history, err := loadHistory(s.db, userID, opts.DrawDate, s.historyLimit)
+	if err != nil {
+		log.Printf("load reading history: %v", err)
+		return req
+	}
+	req.History, req.Patterns = summarizeHistory(req.Card, history)
+	return req
+}
+
+// stored returns the reading saved on the matching daily draw, or nil. A
+// reading saved before personas were recorded matches any persona.
+func (s *InterpretationService) stored(userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions) (*Interpretation, error) {
+	var text, provider, persona, template sql.NullString
+	err := s.db.QueryRow(`
+		SELECT interpretation_enhanced, interpretation_provider, interpretation_persona, interpretation_template
+		FROM card_draws
+		WHERE user_id = $1 AND draw_date = $2 AND kind = $3
+		  AND card_id = $4 AND orientation = $5 AND deck = $6
+	`, userID, opts.DrawDate, DrawKindDaily, req.Card.ID, req.Orientation, drawDeck(opts.Deck)).Scan(&text, &provider, &persona, &template)
+	if err == sql.ErrNoRows || (err == nil && !text.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if persona.String != "" && persona.String != req.Persona {
+		return nil, nil
+	}
+
+	return &Interpretation{
+		Text:     text.String,
+		Provider: provider.String,
+		Persona:  persona.String,
+		Template: template.String,
+		Cached:   true,
+	}, nil
+}
+
+func (s *InterpretationService) save(userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions, interpretation *Interpretation) error {
+	_, err := s.db.Exec(`
+		UPDATE card_draws
+		SET interpretation_enhanced = $1, interpretation_provider = $2,
+		    interpretation_persona = NULLIF($3, ''), interpretation_template = NULLIF($4, '')
+		WHERE user_id = $5 AND draw_date = $6 AND kind = $7
+		  AND card_id = $8 AND orientation = $9 AND deck = $10
+	`, interpretation.Text, interpretation.Provider, interpretation.Persona, interpretation.Template,
+		userID, opts.DrawDate, DrawKindDaily, req.Card.ID, req.Orientation, drawDeck(opts.Deck))
+	return err
+}
+
+// useRegeneration counts a regeneration against the user's local day. It
+// is free when there is no stored reading to replace.
+func (s *InterpretationService) useRegeneration(userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions) error {
+	stored, err := s.stored(userID, req, opts)
+	if err != nil || stored == nil {
+		return err
+	}
+
+	limitErr := ErrRegenerationLimit.WithDetails(map[string]interface{}{
+		"limit": s.regenerationsPerDay,
+	})
+	if s.regenerationsPerDay == 0 {
+		return limitErr
+	}
+
+	location, err := userLocation(s.db, userID, opts.Timezone)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO daily_usage (user_id, usage_date, regenerations_count)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, usage_date)
+		DO UPDATE SET regenerations_count = daily_usage.regenerations_count + 1
+		WHERE daily_usage.regenerations_count < $3
+	`, userID, localDate(time.Now(), location), s.regenerationsPerDay)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return limitErr
+	}
+	return nil
+}
+
+func drawDeck(name string) string {
+	if name == "" {
+		return tarot.DefaultDeckName
+	}
+	return name
+}
+
+// flightKey identifies requests that would produce the same reading.
+func flightKey(userID uuid.UUID, req InterpretationRequest, opts InterpretationOptions) string {
+	return strings.Join([]string{
+		userID.String(),
+		opts.DrawDate,
+		drawDeck(opts.Deck),
+		strconv.Itoa(req.Card.ID),
+		string(req.Orientation),
+		req.Mood,
+		req.Question,
+		req.Persona,
+		strconv.FormatBool(opts.Regenerate),
+	}, "\x00")
+}
+
+// flightGroup runs one call per key at a time; callers that arrive while
+// it runs wait for its result instead of starting their own.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done           chan struct{}
+	interpretation *Interpretation
+	err            error
+	// abandoned is set when the caller running the flight went away, so
+	// its error says nothing about the reading itself.
+	abandoned bool
+}
+
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (*Interpretation, error)) (*Interpretation, error) {
+	for {
+		g.mu.Lock()
+		if g.calls == nil {
+			g.calls = make(map[string]*flight)
+		}
+		if call, exists := g.calls[key]; exists {
+			g.mu.Unlock()
+
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.abandoned {
+				continue
+			}
+			return call.interpretation, call.err
+		}
+
+		call := &flight{done: make(chan struct{})}
+		g.calls[key] = call
+		g.mu.Unlock()
+
+		call.interpretation, call.err = fn()
+		call.abandoned = call.err != nil && ctx.Err() != nil
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+
+		return call.interpretation, call.err
+	}
+}
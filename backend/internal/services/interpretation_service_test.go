@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"symbol-quest/internal/tarot"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// countingInterpreter waits for release before answering and counts how
+// often it was asked.
+type countingInterpreter struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (c *countingInterpreter) Name() string { return "counting" }
+
+func (c *countingInterpreter) Interpret(ctx context.Context, req InterpretationRequest) (*Interpretation, error) {
+	n := c.calls.Add(1)
+	<-c.release
+	return &Interpretation{Text: req.Card.Name + " reading " + strconv.Itoa(int(n)), Provider: "counting"}, nil
+}
+
+func TestInterpretationService_CollapsesIdenticalRequests(t *testing.T) {
+	interpreter := &countingInterpreter{release: make(chan struct{})}
+	service := NewInterpretationService(nil, interpreter)
+	userID := uuid.New()
+	card, _ := tarot.GetCard(0)
+
+	const requests = 5
+	var wg sync.WaitGroup
+	texts := make(chan string, requests+1)
+	ask := func(question string) {
+		defer wg.Done()
+		interpretation, err := service.Interpret(context.Background(), userID, InterpretationRequest{Card: card, Question: question}, InterpretationOptions{})
+		if err != nil {
+			t.Errorf("Interpret returned error: %v", err)
+			return
+		}
+		texts <- interpretation.Text
+	}
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go ask("Where next?")
+	}
+	wg.Add(1)
+	go ask("Something else")
+
+	time.Sleep(50 * time.Millisecond)
+	close(interpreter.release)
+	wg.Wait()
+	close(texts)
+
+	if calls := interpreter.calls.Load(); calls != 2 {
+		t.Errorf("Expected one upstream call per distinct request, got %d", calls)
+	}
+	seen := make(map[string]int)
+	for text := range texts {
+		seen[text]++
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected identical requests to share a reading, got %v", seen)
+	}
+}
+
+func TestInterpretationService_InterpretStream(t *testing.T) {
+	service := NewInterpretationService(nil, NewTemplateInterpreter())
+	card, _ := tarot.GetCard(0)
+
+	var tokens []string
+	interpretation, err := service.InterpretStream(context.Background(), uuid.New(), InterpretationRequest{Card: card}, InterpretationOptions{}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InterpretStream returned error: %v", err)
+	}
+	if len(tokens) < 2 || strings.Join(tokens, "") != interpretation.Text {
+		t.Errorf("Expected the reading to be streamed word by word, got %q", tokens)
+	}
+}
+
+// scriptedInterpreter streams a fixed list of tokens.
+type scriptedInterpreter struct {
+	tokens []string
+}
+
+func (s *scriptedInterpreter) Name() string { return "scripted" }
+
+func (s *scriptedInterpreter) Interpret(ctx context.Context, req InterpretationRequest) (*Interpretation, error) {
+	return s.InterpretStream(ctx, req, func(string) error { return nil })
+}
+
+func (s *scriptedInterpreter) InterpretStream(_ context.Context, _ InterpretationRequest, onToken func(string) error) (*Interpretation, error) {
+	for _, token := range s.tokens {
+		if err := onToken(token); err != nil {
+			return nil, err
+		}
+	}
+	return &Interpretation{Text: strings.Join(s.tokens, ""), Provider: "scripted"}, nil
+}
+
+// wordModerator flags any text containing word in a blocking category,
+// and records the texts it was asked about.
+type wordModerator struct {
+	word  string
+	texts []string
+}
+
+func (w *wordModerator) Name() string { return "word" }
+
+func (w *wordModerator) Moderate(_ context.Context, text string) (*ModerationVerdict, error) {
+	w.texts = append(w.texts, text)
+	if strings.Contains(text, w.word) {
+		return &ModerationVerdict{Flagged: true, Categories: []string{"violence/graphic"}}, nil
+	}
+	return &ModerationVerdict{}, nil
+}
+
+func TestInterpretationService_InterpretStreamWithholdsFlaggedText(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	calm := strings.Repeat("The path ahead is open. ", 10)
+	tests := []struct {
+		name   string
+		tokens []string
+		sent   string
+	}{
+		{"Middle sentence", []string{calm, "Something ", "gruesome ", "follows. ", calm}, calm},
+		{"Last sentence", []string{calm, "Then something ", "gruesome"}, calm},
+		{"Short answer", []string{"A new path opens. ", "Something gruesome follows."}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := NewInterpretationService(nil, &scriptedInterpreter{tokens: test.tokens})
+			service.SetModeration(NewModerationService(nil, &wordModerator{word: "gruesome"}))
+
+			var sent strings.Builder
+			_, err := service.InterpretStream(context.Background(), uuid.New(), InterpretationRequest{Card: card}, InterpretationOptions{}, func(token string) error {
+				sent.WriteString(token)
+				return nil
+			})
+			if !errors.Is(err, ErrContentWithheld) {
+				t.Fatalf("Expected ErrContentWithheld, got %v", err)
+			}
+			if sent.String() != test.sent {
+				t.Errorf("Expected only %q to be sent, got %q", test.sent, sent.String())
+			}
+		})
+	}
+}
+
+func TestInterpretationService_InterpretStreamScreensEachBatchOnce(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	sentence := strings.Repeat("a", 95) + ". "
+	tokens := []string{sentence, sentence, sentence, sentence, sentence, sentence}
+	moderator := &wordModerator{word: "gruesome"}
+	service := NewInterpretationService(nil, &scriptedInterpreter{tokens: tokens})
+	service.SetModeration(NewModerationService(nil, moderator))
+
+	var sent strings.Builder
+	_, err := service.InterpretStream(context.Background(), uuid.New(), InterpretationRequest{Card: card}, InterpretationOptions{}, func(token string) error {
+		sent.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InterpretStream returned error: %v", err)
+	}
+
+	full := strings.Join(tokens, "")
+	if sent.String() != full {
+		t.Errorf("Expected the whole reading to be sent, got %q", sent.String())
+	}
+	// Two batches of three sentences while streaming, then the full text
+	if len(moderator.texts) != 3 {
+		t.Fatalf("Expected 3 provider calls, got %d", len(moderator.texts))
+	}
+	if streamed := moderator.texts[0] + moderator.texts[1]; streamed != full {
+		t.Errorf("Expected each sentence to be screened once while streaming, got %q", moderator.texts[:2])
+	}
+	if moderator.texts[2] != full {
+		t.Errorf("Expected the full text to be screened last, got %q", moderator.texts[2])
+	}
+}
+
+func TestFlightGroup_AbandonedCall(t *testing.T) {
+	var group flightGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+
+	go group.do(ctx, "key", func() (*Interpretation, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, errors.New("client went away")
+	})
+	<-started
+
+	result := make(chan *Interpretation)
+	go func() {
+		interpretation, _ := group.do(context.Background(), "key", func() (*Interpretation, error) {
+			return &Interpretation{Text: "fresh"}, nil
+		})
+		result <- interpretation
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if interpretation := <-result; interpretation == nil || interpretation.Text != "fresh" {
+		t.Errorf("Expected the waiting caller to run its own call, got %+v", interpretation)
+	}
+}
+
+func TestInterpretationService_StoredAndRegenerated(t *testing.T) {
+	db, userID := testDatabase(t)
+
+	result, err := NewCardService(db).PerformDailyDraw(context.Background(), userID, "", "", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw returned error: %v", err)
+	}
+	draw := result.Draw
+	card, _ := tarot.GetCard(draw.CardID)
+	req := InterpretationRequest{Card: card, Orientation: tarot.Orientation(draw.Orientation)}
+	opts := InterpretationOptions{DrawDate: draw.DrawDate, Timezone: "UTC"}
+
+	interpreter := &countingInterpreter{release: make(chan struct{})}
+	close(interpreter.release)
+	service := NewInterpretationService(db, interpreter)
+	service.SetRegenerationLimit(1)
+
+	first, err := service.Interpret(context.Background(), userID, req, opts)
+	if err != nil || first.Cached {
+		t.Fatalf("Expected a fresh reading, got %+v, %v", first, err)
+	}
+
+	second, err := service.Interpret(context.Background(), userID, req, opts)
+	if err != nil || !second.Cached || second.Text != first.Text || second.Provider != "counting" {
+		t.Errorf("Expected the stored reading, got %+v, %v", second, err)
+	}
+
+	opts.Regenerate = true
+	regenerated, err := service.Interpret(context.Background(), userID, req, opts)
+	if err != nil || regenerated.Text == first.Text {
+		t.Errorf("Expected a new reading, got %+v, %v", regenerated, err)
+	}
+
+	if _, err := service.Interpret(context.Background(), userID, req, opts); !errors.Is(err, ErrRegenerationLimit) {
+		t.Errorf("Expected ErrRegenerationLimit, got %v", err)
+	}
+	if calls := interpreter.calls.Load(); calls != 2 {
+		t.Errorf("Expected two upstream calls, got %d", calls)
+	}
+}
+
+func TestInterpretationService_UnknownPersona(t *testing.T) {
+	service := NewInterpretationService(nil, NewTemplateInterpreter())
+	card, _ := tarot.GetCard(0)
+
+	_, err := service.Interpret(context.Background(), uuid.New(), InterpretationRequest{Card: card, Persona: "oracle"}, InterpretationOptions{})
+	if !errors.Is(err, ErrUnknownPersona) {
+		t.Errorf("Expected ErrUnknownPersona, got %v", err)
+	}
+}
+
+func TestInterpretationService_DrawRequest(t *testing.T) {
+	db, userID := testDatabase(t)
+
+	result, err := NewCardService(db).PerformDailyDraw(context.Background(), userID, "curious", "What now?", tarot.DefaultDeckName, "", "UTC", tarot.ScopeFull)
+	if err != nil {
+		t.Fatalf("PerformDailyDraw failed: %v", err)
+	}
+	draw := result.Draw
+
+	service := NewInterpretationService(db, NewTemplateInterpreter())
+	req, deck, err := service.DrawRequest(userID, draw.DrawDate)
+	if err != nil {
+		t.Fatalf("DrawRequest failed: %v", err)
+	}
+	if req.Card.ID != draw.CardID || string(req.Orientation) != draw.Orientation || deck != draw.Deck {
+		t.Errorf("Expected card %d %s from %s, got %d %s from %s", draw.CardID, draw.Orientation, draw.Deck, req.Card.ID, req.Orientation, deck)
+	}
+	if req.Mood != "curious" || req.Question != "What now?" {
+		t.Errorf("Expected the draw's mood and question, got %q and %q", req.Mood, req.Question)
+	}
+
+	// Another user's draw, or a day without one, is not found
+	if _, _, err := service.DrawRequest(uuid.New(), draw.DrawDate); !errors.Is(err, ErrDrawNotFound) {
+		t.Errorf("Expected ErrDrawNotFound for another user, got %v", err)
+	}
+	if _, _, err := service.DrawRequest(userID, "2001-01-01"); !errors.Is(err, ErrDrawNotFound) {
+		t.Errorf("Expected ErrDrawNotFound for a day without a draw, got %v", err)
+	}
+}
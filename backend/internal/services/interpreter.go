@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"symbol-quest/internal/prompts"
+	"symbol-quest/internal/tarot"
+	"time"
+)
+
+// Interpreter names accepted in INTERPRETERS.
+const (
+	InterpreterOpenAI     = "openai"
+	InterpreterCompatible = "compatible"
+	InterpreterTemplate   = "template"
+)
+
+// InterpretationRequest is everything an interpreter knows about a reading.
+type InterpretationRequest struct {
+	Card        tarot.Card
+	Orientation tarot.Orientation
+	Mood        string
+	Question    string
+	// Persona names the reader persona; empty means the default one.
+	Persona string
+	History []prompts.PastReading
+	// Patterns summarises the user's recent readings; nil when their
+	// history is not used.
+	Patterns *prompts.HistoryPatterns
+}
+
+// Interpretation is a generated reading and the interpreter that wrote it.
+type Interpretation struct {
+	Text     string `json:"interpretation"`
+	Provider string `json:"provider"`
+	// Persona and Template record the persona and prompt template version
+	// that produced the reading. Interpreters that do not use prompt
+	// templates leave them empty.
+	Persona  string `json:"persona,omitempty"`
+	Template string `json:"template,omitempty"`
+	// Cached is set when the reading was saved earlier rather than written
+	// for this request.
+	Cached bool `json:"cached"`
+	// Usage is what the model call consumed; nil for interpreters that do
+	// not call a model.
+	Usage *Usage `json:"-"`
+}
+
+// Usage is what one model call consumed.
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	// Estimated is set when the API did not report token counts and they
+	// were estimated from the text.
+	Estimated bool
+}
+
+// Interpreter turns a drawn card into a personalised reading. Cancelling
+// ctx abandons the reading.
+type Interpreter interface {
+	Name() string
+	Interpret(ctx context.Context, req InterpretationRequest) (*Interpretation, error)
+}
+
+// StreamingInterpreter is an Interpreter that can deliver a reading while
+// it is being written. onToken receives each piece of text in order; an
+// error from onToken aborts the reading.
+type StreamingInterpreter interface {
+	Interpreter
+	InterpretStream(ctx context.Context, req InterpretationRequest, onToken func(string) error) (*Interpretation, error)
+}
+
+// Stream interprets req, streaming when the interpreter supports it and
+// otherwise sending the finished reading as a single token.
+func Stream(ctx context.Context, interpreter Interpreter, req InterpretationRequest, onToken func(string) error) (*Interpretation, error) {
+	if streaming, ok := interpreter.(StreamingInterpreter); ok {
+		return streaming.InterpretStream(ctx, req, onToken)
+	}
+
+	interpretation, err := interpreter.Interpret(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := onToken(interpretation.Text); err != nil {
+		return nil, err
+	}
+	return interpretation, nil
+}
+
+// Roles of the messages in a reading chat.
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatTurn is one earlier message in a reading chat.
+type ChatTurn struct {
+	Role    string
+	Content string
+}
+
+// FollowUpRequest is a question about a reading, with the reading itself
+// and the conversation so far.
+type FollowUpRequest struct {
+	InterpretationRequest
+	// Reading is the enhanced interpretation the user is asking about, if
+	// one was saved.
+	Reading string
+	History []ChatTurn
+	Message string
+	// MaxTokens caps the length of the answer.
+	MaxTokens int
+}
+
+// ConversationalInterpreter is an Interpreter that can answer follow-up
+// questions about a reading.
+type ConversationalInterpreter interface {
+	Interpreter
+	FollowUp(ctx context.Context, req FollowUpRequest) (*Interpretation, error)
+}
+
+// ErrInterpreterUnavailable is returned by an interpreter that is not
+// configured, such as OpenAI without an API key.
+var ErrInterpreterUnavailable = errors.New("interpreter not configured")
+
+// FailoverInterpreter tries each interpreter in order and returns the
+// first reading that succeeds.
+type FailoverInterpreter struct {
+	interpreters []Interpreter
+}
+
+func NewFailoverInterpreter(interpreters ...Interpreter) *FailoverInterpreter {
+	return &FailoverInterpreter{interpreters: interpreters}
+}
+
+func (f *FailoverInterpreter) Name() string {
+	names := make([]string, len(f.interpreters))
+	for i, interpreter := range f.interpreters {
+		names[i] = interpreter.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Interpret returns ErrInterpretationUnavailable, wrapping every
+// interpreter's error, when none of them succeeds. It stops trying once
+// ctx is done.
+func (f *FailoverInterpreter) Interpret(ctx context.Context, req InterpretationRequest) (*Interpretation, error) {
+	var errs []error
+	for _, interpreter := range f.interpreters {
+		interpretation, err := interpreter.Interpret(ctx, req)
+		if err == nil {
+			return interpretation, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, ErrInterpreterUnavailable) {
+			log.Printf("interpreter %s failed: %v", interpreter.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", interpreter.Name(), err))
+	}
+	return nil, fmt.Errorf("%w: %w", ErrInterpretationUnavailable, errors.Join(errs...))
+}
+
+// InterpretStream streams from the first interpreter that works. Once an
+// interpreter has sent text the reading cannot move to another one, so a
+// failure after that point is returned as is.
+func (f *FailoverInterpreter) InterpretStream(ctx context.Context, req InterpretationRequest, onToken func(string) error) (*Interpretation, error) {
+	var errs []error
+	for _, interpreter := range f.interpreters {
+		sent := false
+		interpretation, err := Stream(ctx, interpreter, req, func(token string) error {
+			sent = true
+			return onToken(token)
+		})
+		if err == nil {
+			return interpretation, nil
+		}
+		if sent || ctx.Err() != nil {
+			return nil, err
+		}
+		if !errors.Is(err, ErrInterpreterUnavailable) {
+			log.Printf("interpreter %s failed: %v", interpreter.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", interpreter.Name(), err))
+	}
+	return nil, fmt.Errorf("%w: %w", ErrInterpretationUnavailable, errors.Join(errs...))
+}
+
+// FollowUp asks each conversational interpreter in turn. Interpreters that
+// cannot hold a conversation are skipped.
+func (f *FailoverInterpreter) FollowUp(ctx context.Context, req FollowUpRequest) (*Interpretation, error) {
+	var errs []error
+	for _, interpreter := range f.interpreters {
+		conversational, ok := interpreter.(ConversationalInterpreter)
+		if !ok {
+			continue
+		}
+
+		answer, err := conversational.FollowUp(ctx, req)
+		if err == nil {
+			return answer, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, ErrInterpreterUnavailable) {
+			log.Printf("interpreter %s failed: %v", interpreter.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", interpreter.Name(), err))
+	}
+	return nil, fmt.Errorf("%w: %w", ErrInterpretationUnavailable, errors.Join(errs...))
+}
+
+// InterpreterConfig selects and configures the interpreters behind the
+// enhanced interpretation endpoint.
+type InterpreterConfig struct {
+	// Order lists interpreter names, tried first to last.
+	Order        []string
+	OpenAIAPIKey string
+	// BaseURL, APIKey and Model configure the OpenAI-compatible endpoint,
+	// such as a local model server.
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Timeout, Retry and the breaker settings apply to both model APIs.
+	// A zero Timeout keeps DefaultInterpreterTimeout.
+	Timeout          time.Duration
+	Retry            RetryPolicy
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// NewInterpreter builds a failover chain from cfg. Interpreters that are
+// not configured are left out; an unknown name is an error.
+func NewInterpreter(cfg InterpreterConfig) (*FailoverInterpreter, error) {
+	// Each API gets its own breaker, so one failing does not stop the other
+	configure := func(service *OpenAIService) *OpenAIService {
+		service.SetTimeout(cfg.Timeout)
+		service.SetRetryPolicy(cfg.Retry)
+		service.SetCircuitBreaker(NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown))
+		return service
+	}
+
+	var interpreters []Interpreter
+	for _, name := range cfg.Order {
+		switch strings.TrimSpace(name) {
+		case InterpreterOpenAI:
+			if cfg.OpenAIAPIKey != "" {
+				interpreters = append(interpreters, configure(NewOpenAIService(cfg.OpenAIAPIKey)))
+			}
+		case InterpreterCompatible:
+			if cfg.BaseURL != "" {
+				interpreters = append(interpreters, configure(NewOpenAICompatibleService(cfg.BaseURL, cfg.APIKey, cfg.Model)))
+			}
+		case InterpreterTemplate:
+			interpreters = append(interpreters, NewTemplateInterpreter())
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown interpreter %q", name)
+		}
+	}
+	return NewFailoverInterpreter(interpreters...), nil
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"symbol-quest/internal/prompts"
+	"symbol-quest/internal/tarot"
+	"testing"
+	"time"
+)
+
+type failingInterpreter struct{ err error }
+
+func (f failingInterpreter) Name() string { return "failing" }
+
+func (f failingInterpreter) Interpret(context.Context, InterpretationRequest) (*Interpretation, error) {
+	return nil, f.err
+}
+
+func TestTemplateInterpreter(t *testing.T) {
+	tower, _ := tarot.GetCard(16)
+
+	t.Run("Upright", func(t *testing.T) {
+		interpretation, err := NewTemplateInterpreter().Interpret(context.Background(), InterpretationRequest{
+			Card:        tower,
+			Orientation: tarot.OrientationUpright,
+			Mood:        "anxious",
+			Question:    "Should I change jobs?",
+		})
+		if err != nil {
+			t.Fatalf("Interpret returned error: %v", err)
+		}
+
+		text := interpretation.Text
+		for _, want := range []string{"The Tower appears upright", "sudden change", "Should I change jobs?", "feeling anxious", moodGuidance["anxious"]} {
+			if !strings.Contains(text, want) {
+				t.Errorf("Expected %q in reading, got: %s", want, text)
+			}
+		}
+		if interpretation.Provider != InterpreterTemplate {
+			t.Errorf("Expected provider %q, got %q", InterpreterTemplate, interpretation.Provider)
+		}
+	})
+
+	t.Run("Reversed", func(t *testing.T) {
+		interpretation, _ := NewTemplateInterpreter().Interpret(context.Background(), InterpretationRequest{
+			Card:        tower,
+			Orientation: tarot.OrientationReversed,
+		})
+		if !strings.Contains(interpretation.Text, "blocked or turned inward") || !strings.Contains(interpretation.Text, tower.ReversedMeaning) {
+			t.Errorf("Expected a shadow-focused reversed reading, got: %s", interpretation.Text)
+		}
+	})
+
+	t.Run("RecurringCard", func(t *testing.T) {
+		interpretation, _ := NewTemplateInterpreter().Interpret(context.Background(), InterpretationRequest{
+			Card:        tower,
+			Orientation: tarot.OrientationUpright,
+			Patterns:    &prompts.HistoryPatterns{Readings: 10, CurrentCard: 2},
+		})
+		if !strings.Contains(interpretation.Text, "The Tower has come to you twice in your last 10 readings") {
+			t.Errorf("Expected the reading to mention the recurring card, got: %s", interpretation.Text)
+		}
+	})
+}
+
+func TestFailoverInterpreter(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	t.Run("FallsThrough", func(t *testing.T) {
+		chain := NewFailoverInterpreter(
+			failingInterpreter{err: ErrInterpreterUnavailable},
+			failingInterpreter{err: errors.New("connection refused")},
+			NewTemplateInterpreter(),
+		)
+
+		interpretation, err := chain.Interpret(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Interpret returned error: %v", err)
+		}
+		if interpretation.Provider != InterpreterTemplate {
+			t.Errorf("Expected the template interpreter to answer, got %q", interpretation.Provider)
+		}
+	})
+
+	t.Run("AllFail", func(t *testing.T) {
+		chain := NewFailoverInterpreter(failingInterpreter{err: errors.New("connection refused")})
+
+		_, err := chain.Interpret(context.Background(), req)
+		if !errors.Is(err, ErrInterpretationUnavailable) {
+			t.Errorf("Expected ErrInterpretationUnavailable, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("Expected the underlying error to be kept, got %v", err)
+		}
+	})
+}
+
+func TestNewInterpreter(t *testing.T) {
+	chain, err := NewInterpreter(InterpreterConfig{
+		Order: []string{InterpreterOpenAI, InterpreterCompatible, InterpreterTemplate},
+	})
+	if err != nil {
+		t.Fatalf("NewInterpreter returned error: %v", err)
+	}
+	if chain.Name() != InterpreterTemplate {
+		t.Errorf("Expected unconfigured interpreters to be skipped, got %q", chain.Name())
+	}
+
+	chain, _ = NewInterpreter(InterpreterConfig{
+		Order:        []string{InterpreterCompatible, " openai"},
+		OpenAIAPIKey: "sk-test",
+		BaseURL:      "http://localhost:11434/v1",
+	})
+	if chain.Name() != "compatible,openai" {
+		t.Errorf("Expected configured order to be kept, got %q", chain.Name())
+	}
+
+	if _, err := NewInterpreter(InterpreterConfig{Order: []string{"oracle"}}); err == nil {
+		t.Error("Expected unknown interpreter to be rejected")
+	}
+}
+
+func TestOpenAICompatibleService(t *testing.T) {
+	var got OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Expected no Authorization header without a key, got %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "A local reading"}}]}`))
+	}))
+	defer server.Close()
+
+	service := NewOpenAICompatibleService(server.URL+"/v1/", "", "llama3")
+	card, _ := tarot.GetCard(0)
+
+	interpretation, err := service.Interpret(context.Background(), InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright})
+	if err != nil {
+		t.Fatalf("Interpret returned error: %v", err)
+	}
+	if interpretation.Text != "A local reading" || interpretation.Provider != InterpreterCompatible {
+		t.Errorf("Unexpected interpretation: %+v", interpretation)
+	}
+	if got.Model != "llama3" {
+		t.Errorf("Expected model llama3, got %q", got.Model)
+	}
+}
+
+func TestOpenAIService_InterpretStream(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	t.Run("RelaysTokens", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body OpenAIRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			if !body.Stream {
+				t.Error("Expected a streaming request")
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range []string{
+				`{"choices": [{"delta": {"role": "assistant"}}]}`,
+				`{"choices": [{"delta": {"content": "The Fool "}}]}`,
+				`{"choices": [{"delta": {"content": "steps forward."}}]}`,
+				`[DONE]`,
+			} {
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer server.Close()
+
+		var tokens []string
+		service := NewOpenAICompatibleService(server.URL, "", "llama3")
+		interpretation, err := service.InterpretStream(context.Background(), req, func(token string) error {
+			tokens = append(tokens, token)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("InterpretStream returned error: %v", err)
+		}
+		if len(tokens) != 2 || interpretation.Text != "The Fool steps forward." {
+			t.Errorf("Unexpected stream: tokens %q, text %q", tokens, interpretation.Text)
+		}
+	})
+
+	t.Run("StopsWhenClientLeaves", func(t *testing.T) {
+		cancelled := make(chan bool, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"The\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-r.Context().Done():
+				cancelled <- true
+			case <-time.After(2 * time.Second):
+				cancelled <- false
+			}
+		}))
+		defer server.Close()
+
+		service := NewOpenAICompatibleService(server.URL, "", "llama3")
+		gone := errors.New("client disconnected")
+		_, err := service.InterpretStream(context.Background(), req, func(string) error {
+			return gone
+		})
+		if !errors.Is(err, gone) {
+			t.Errorf("Expected the disconnect error, got %v", err)
+		}
+		if !<-cancelled {
+			t.Error("Expected the upstream request to be cancelled")
+		}
+	})
+
+	t.Run("UpstreamError", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Incorrect API key provided"}}`))
+		}))
+		defer server.Close()
+
+		service := NewOpenAICompatibleService(server.URL, "bad-key", "llama3")
+		_, err := service.InterpretStream(context.Background(), req, func(string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "Incorrect API key") {
+			t.Errorf("Expected the upstream error message, got %v", err)
+		}
+	})
+}
+
+func TestFailoverInterpreter_InterpretStream(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	chain := NewFailoverInterpreter(failingInterpreter{err: errors.New("timeout")}, NewTemplateInterpreter())
+
+	var streamed strings.Builder
+	interpretation, err := chain.InterpretStream(context.Background(), InterpretationRequest{Card: card}, func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InterpretStream returned error: %v", err)
+	}
+	if streamed.String() != interpretation.Text || interpretation.Provider != InterpreterTemplate {
+		t.Errorf("Expected the template reading to be streamed in full, got %q", streamed.String())
+	}
+}
+
+func TestFollowUp(t *testing.T) {
+	card, _ := tarot.GetCard(16)
+	req := FollowUpRequest{
+		InterpretationRequest: InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright, Question: "What now?"},
+		Reading:               "The Tower clears the ground.",
+		History: []ChatTurn{
+			{Role: ChatRoleUser, Content: "Is it bad?"},
+			{Role: ChatRoleAssistant, Content: "Not in the long run."},
+		},
+		Message:   "What about my job interview?",
+		MaxTokens: 150,
+	}
+
+	t.Run("OpenAI", func(t *testing.T) {
+		var got OpenAIRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Go in prepared."}}]}`))
+		}))
+		defer server.Close()
+
+		answer, err := NewOpenAICompatibleService(server.URL, "", "llama3").FollowUp(context.Background(), req)
+		if err != nil {
+			t.Fatalf("FollowUp returned error: %v", err)
+		}
+		if answer.Text != "Go in prepared." {
+			t.Errorf("Unexpected answer %q", answer.Text)
+		}
+
+		var roles []string
+		for _, message := range got.Messages {
+			roles = append(roles, message.Role)
+		}
+		if strings.Join(roles, ",") != "system,user,assistant,user,assistant,user" {
+			t.Errorf("Expected prompt, reading, history and question in order, got %v", roles)
+		}
+		if last := got.Messages[len(got.Messages)-1].Content; last != req.Message {
+			t.Errorf("Expected the question last, got %q", last)
+		}
+		if got.MaxTokens != 150 {
+			t.Errorf("Expected max_tokens 150, got %d", got.MaxTokens)
+		}
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		chain := NewFailoverInterpreter(failingInterpreter{err: errors.New("timeout")}, NewTemplateInterpreter())
+
+		answer, err := chain.FollowUp(context.Background(), req)
+		if err != nil {
+			t.Fatalf("FollowUp returned error: %v", err)
+		}
+		if answer.Provider != InterpreterTemplate || !strings.Contains(answer.Text, req.Message) {
+			t.Errorf("Expected the template to answer the question, got %+v", answer)
+		}
+	})
+}
+
+func TestOpenAIService_Personas(t *testing.T) {
+	var got OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Act this week."}}]}`))
+	}))
+	defer server.Close()
+
+	coach, _ := prompts.GetPersona("coach")
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright, Persona: "coach"}
+
+	openai := NewOpenAIService("sk-test")
+	openai.baseURL = server.URL
+	interpretation, err := openai.Interpret(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Interpret returned error: %v", err)
+	}
+	if got.Model != coach.Model || got.Temperature != coach.Temperature || got.MaxTokens != coach.MaxTokens {
+		t.Errorf("Expected the coach's settings, got model %q, temperature %g, max_tokens %d", got.Model, got.Temperature, got.MaxTokens)
+	}
+	if got.Messages[0].Content != coach.Voice {
+		t.Errorf("Expected the coach's voice as system prompt, got %q", got.Messages[0].Content)
+	}
+	if interpretation.Persona != "coach" || interpretation.Template != coach.Template {
+		t.Errorf("Expected persona and template to be recorded, got %+v", interpretation)
+	}
+
+	if _, err := NewOpenAICompatibleService(server.URL, "", "llama3").Interpret(context.Background(), req); err != nil {
+		t.Fatalf("Interpret returned error: %v", err)
+	}
+	if got.Model != "llama3" {
+		t.Errorf("Expected compatible servers to keep their model, got %q", got.Model)
+	}
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"symbol-quest/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Failed logins are counted per account and per IP address in
+// login_attempts, so the limits hold across app instances. After a few
+// failures each further attempt has to wait, twice as long every time.
+// Enough failures lock the account or address for a while; a locked
+// account is emailed a link that lifts the lock.
+
+// Scopes of login_attempts rows.
+const (
+	attemptScopeAccount = "account"
+	attemptScopeIP      = "ip"
+)
+
+// Events written to auth_audit_log.
+const (
+	AuditAccountLocked   = "account_locked"
+	AuditIPLocked        = "ip_locked"
+	AuditAccountUnlocked = "account_unlocked"
+)
+
+// TokenPurposeUnlockAccount is the purpose of the email tokens that lift
+// an account lockout.
+const TokenPurposeUnlockAccount = "unlock_account"
+
+// DefaultUnlockTTL is how long an unlock link is valid.
+const DefaultUnlockTTL = 24 * time.Hour
+
+// LoginPolicy sets how failed logins are throttled.
+type LoginPolicy struct {
+	// DelayAfter is the number of failures after which each attempt has to
+	// wait BaseDelay, doubling with every further failure up to MaxDelay.
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// AccountThreshold and IPThreshold are the failures that lock an
+	// account or an IP address for LockoutDuration. Addresses get more
+	// leeway since many users can share one.
+	AccountThreshold int
+	IPThreshold      int
+	LockoutDuration  time.Duration
+	// Window is how long a failure is remembered.
+	Window time.Duration
+}
+
+// DefaultLoginPolicy is used unless SetLoginPolicy is called.
+var DefaultLoginPolicy = LoginPolicy{
+	DelayAfter:       3,
+	BaseDelay:        time.Second,
+	MaxDelay:         30 * time.Second,
+	AccountThreshold: 10,
+	IPThreshold:      50,
+	LockoutDuration:  15 * time.Minute,
+	Window:           15 * time.Minute,
+}
+
+// SetLoginPolicy sets how failed logins are throttled.
+func (s *AuthService) SetLoginPolicy(policy LoginPolicy) {
+	s.loginPolicy = policy
+}
+
+// attemptState is the row of one account or address.
+type attemptState struct {
+	scope         string
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+// delay returns the wait imposed after failures failed attempts.
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if p.DelayAfter <= 0 || failures < p.DelayAfter {
+		return 0
+	}
+	return min(p.MaxDelay, p.BaseDelay<<min(failures-p.DelayAfter, 16))
+}
+
+// wait returns how long until state allows another attempt and whether
+// that is because of a lockout.
+func (p LoginPolicy) wait(state attemptState, now time.Time) (time.Duration, bool) {
+	if now.Before(state.lockedUntil) {
+		return state.lockedUntil.Sub(now), true
+	}
+	if now.Sub(state.lastFailureAt) >= p.Window {
+		return 0, false
+	}
+	next := state.lastFailureAt.Add(p.delay(state.failures))
+	return max(0, next.Sub(now)), false
+}
+
+// checkLogin returns ErrAccountLocked or ErrTooManyAttempts if the account
+// or address has to wait before trying again.
+func (s *AuthService) checkLogin(account, ip string) error {
+	rows, err := s.db.Query(`
+		SELECT scope, failures, last_failure_at, COALESCE(locked_until, 'epoch')
+		FROM login_attempts
+		WHERE (scope = $1 AND subject = $2) OR (scope = $3 AND subject = $4)
+	`, attemptScopeAccount, account, attemptScopeIP, ip)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var longest time.Duration
+	var accountLocked bool
+	for rows.Next() {
+		var state attemptState
+		if err := rows.Scan(&state.scope, &state.failures, &state.lastFailureAt, &state.lockedUntil); err != nil {
+			return err
+		}
+		wait, locked := s.loginPolicy.wait(state, now)
+		longest = max(longest, wait)
+		accountLocked = accountLocked || (locked && state.scope == attemptScopeAccount)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if longest <= 0 {
+		return nil
+	}
+	details := map[string]interface{}{"retry_after": int(math.Ceil(longest.Seconds()))}
+	if accountLocked {
+		return ErrAccountLocked.WithDetails(details)
+	}
+	return ErrTooManyAttempts.WithDetails(details)
+}
+
+// recordLoginFailure counts a failed login against the account and the
+// address and locks whichever reached its threshold. user is nil if the
+// email has no account.
+func (s *AuthService) recordLoginFailure(account, ip string, user *models.User) error {
+	now := time.Now()
+	scopes := []struct {
+		scope, subject string
+		threshold      int
+	}{
+		{attemptScopeAccount, account, s.loginPolicy.AccountThreshold},
+		{attemptScopeIP, ip, s.loginPolicy.IPThreshold},
+	}
+
+	for _, scope := range scopes {
+		if scope.subject == "" {
+			continue
+		}
+
+		var failures int
+		err := s.db.QueryRow(`
+			INSERT INTO login_attempts (scope, subject, failures, last_failure_at)
+			VALUES ($1, $2, 1, $3)
+			ON CONFLICT (scope, subject) DO UPDATE SET
+				failures = CASE WHEN login_attempts.last_failure_at < $4 THEN 1 ELSE login_attempts.failures + 1 END,
+				last_failure_at = $3
+			RETURNING failures
+		`, scope.scope, scope.subject, now, now.Add(-s.loginPolicy.Window)).Scan(&failures)
+		if err != nil {
+			return fmt.Errorf("record failed login: %w", err)
+		}
+		if scope.threshold <= 0 || failures < scope.threshold {
+			continue
+		}
+
+		// The count starts again once the lockout is over
+		if _, err := s.db.Exec(`
+			UPDATE login_attempts SET failures = 0, locked_until = $3
+			WHERE scope = $1 AND subject = $2
+		`, scope.scope, scope.subject, now.Add(s.loginPolicy.LockoutDuration)); err != nil {
+			return fmt.Errorf("lock %s: %w", scope.scope, err)
+		}
+
+		if scope.scope == attemptScopeIP {
+			s.audit(AuditIPLocked, uuid.Nil, account, ip)
+			continue
+		}
+		var userID uuid.UUID
+		if user != nil {
+			userID = user.ID
+		}
+		s.audit(AuditAccountLocked, userID, account, ip)
+		if user != nil {
+			s.sendUnlockLink(user)
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the account's failures after a successful
+// login, along with rows nobody needs any more.
+func (s *AuthService) clearLoginFailures(account string) {
+	_, err := s.db.Exec(`
+		DELETE FROM login_attempts
+		WHERE (scope = $1 AND subject = $2)
+		   OR (last_failure_at < $3 AND (locked_until IS NULL OR locked_until < NOW()))
+	`, attemptScopeAccount, account, time.Now().Add(-s.loginPolicy.Window))
+	if err != nil {
+		log.Printf("clear failed logins: %v", err)
+	}
+}
+
+func (s *AuthService) sendUnlockLink(user *models.User) {
+	token, err := s.issueEmailToken(user.ID, user.Email, TokenPurposeUnlockAccount, DefaultUnlockTTL)
+	if err != nil {
+		log.Printf("issue unlock token for %s: %v", user.ID, err)
+		return
+	}
+	if token == "" {
+		return
+	}
+
+	s.deliver(MailMessage{
+		To:      user.Email,
+		Subject: "Your Symbol Quest account was locked",
+		Body: fmt.Sprintf("There were too many failed attempts to sign in to your Symbol Quest account, "+
+			"so it is locked for %s.\n\n"+
+			"If that was you, open this link to unlock it now:\n%s\n\n"+
+			"If it wasn't, someone may be guessing your password. Consider resetting it.\n",
+			s.loginPolicy.LockoutDuration, s.link("/unlock-account", token)),
+	})
+}
+
+// UnlockAccount lifts an account lockout with the token from an unlock
+// link.
+func (s *AuthService) UnlockAccount(token string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, email, err := consumeEmailToken(tx, token, TokenPurposeUnlockAccount)
+	if err != nil {
+		return err
+	}
+	if err := unlockAccount(tx, email); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.audit(AuditAccountUnlocked, userID, normalizeEmail(email), "")
+	return nil
+}
+
+func unlockAccount(db execer, email string) error {
+	_, err := db.Exec("DELETE FROM login_attempts WHERE scope = $1 AND subject = $2", attemptScopeAccount, normalizeEmail(email))
+	return err
+}
+
+// audit records a security event. Failures are logged, not returned, so
+// that they don't change the outcome of a login. Events that follow a
+// transaction are recorded after it commits, since a failed insert inside
+// it would abort the change being audited.
+func (s *AuthService) audit(event string, userID uuid.UUID, email, ip string) {
+	_, err := s.db.Exec(`
+		INSERT INTO auth_audit_log (user_id, event, email, ip)
+		VALUES ($1, $2, $3, $4)
+	`, uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil}, event, email, ip)
+	if err != nil {
+		log.Printf("audit %s for %s: %v", event, email, err)
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoginPolicy_Delay(t *testing.T) {
+	policy := DefaultLoginPolicy
+	tests := map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Second,
+		4:  2 * time.Second,
+		6:  8 * time.Second,
+		9:  30 * time.Second,
+		40: 30 * time.Second,
+	}
+	for failures, want := range tests {
+		if got := policy.delay(failures); got != want {
+			t.Errorf("delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+
+	policy.DelayAfter = 0
+	if got := policy.delay(9); got != 0 {
+		t.Errorf("Expected no delay when turned off, got %v", got)
+	}
+}
+
+func TestLoginPolicy_Wait(t *testing.T) {
+	policy := DefaultLoginPolicy
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		state  attemptState
+		wait   time.Duration
+		locked bool
+	}{
+		{"FewFailures", attemptState{failures: 2, lastFailureAt: now}, 0, false},
+		{"Delayed", attemptState{failures: 5, lastFailureAt: now.Add(-time.Second)}, 3 * time.Second, false},
+		{"DelayOver", attemptState{failures: 5, lastFailureAt: now.Add(-5 * time.Second)}, 0, false},
+		{"Locked", attemptState{lastFailureAt: now, lockedUntil: now.Add(10 * time.Minute)}, 10 * time.Minute, true},
+		{"LockOver", attemptState{lastFailureAt: now.Add(-20 * time.Minute), lockedUntil: now.Add(-time.Minute)}, 0, false},
+		{"Forgotten", attemptState{failures: 9, lastFailureAt: now.Add(-policy.Window)}, 0, false},
+	}
+
+	for _, test := range tests {
+		wait, locked := policy.wait(test.state, now)
+		if wait != test.wait || locked != test.locked {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.name, wait, locked, test.wait, test.locked)
+		}
+	}
+}
+
+func TestAuthService_LoginLockout(t *testing.T) {
+	db, userID := testDatabase(t)
+	email := userID.String() + "@example.com"
+	db.Exec("DELETE FROM login_attempts WHERE subject IN ($1, '192.0.2.1')", email)
+
+	mailer := &fakeMailer{sent: make(chan MailMessage, 10)}
+	service := NewAuthService(db, "test-secret-key")
+	service.SetMailer(mailer, "https://app.example.com")
+	service.SetLoginPolicy(LoginPolicy{AccountThreshold: 3, IPThreshold: 100, LockoutDuration: time.Hour, Window: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		if _, _, _, err := service.Login(email, "wrong-password", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+
+	_, _, _, err := service.Login(email, "wrong-password", "198.51.100.7")
+	var locked *Error
+	if !errors.As(err, &locked) || locked.Code != CodeAccountLocked {
+		t.Fatalf("Expected the account to be locked from any address, got %v", err)
+	}
+	if retryAfter, _ := locked.Details["retry_after"].(int); retryAfter <= 0 || retryAfter > 3600 {
+		t.Errorf("Expected retry_after within the lockout, got %v", locked.Details)
+	}
+
+	var events int
+	db.QueryRow("SELECT COUNT(*) FROM auth_audit_log WHERE user_id = $1 AND event = $2", userID, AuditAccountLocked).Scan(&events)
+	if events != 1 {
+		t.Errorf("Expected one lockout in the audit log, got %d", events)
+	}
+
+	token := mailer.receiveToken(t)
+	if err := service.UnlockAccount(token); err != nil {
+		t.Fatalf("UnlockAccount returned error: %v", err)
+	}
+	if _, _, _, err := service.Login(email, "wrong-password", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected the account to be unlocked, got %v", err)
+	}
+	if err := service.UnlockAccount(token); !errors.Is(err, ErrInvalidLink) {
+		t.Errorf("Expected an unlock token to work once, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer names accepted by NewMailer.
+const (
+	MailerSMTP = "smtp"
+	MailerFile = "file"
+	MailerLog  = "log"
+)
+
+// DefaultMailTimeout bounds the delivery of one message.
+const DefaultMailTimeout = 30 * time.Second
+
+// MailMessage is a plain-text email to one recipient.
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(ctx context.Context, msg MailMessage) error
+}
+
+// SMTPConfig holds the settings of an SMTP relay.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewMailer returns the mailer called name. "smtp" needs a host and a
+// sender; "file" writes messages to outboxPath and "log" logs only their
+// recipient and subject. As "log" delivers nothing, it is refused unless
+// development is set: without mail, users can neither verify their
+// address nor reset their password. A sender, when given, must be a valid
+// address such as "Symbol Quest <no-reply@example.com>".
+func NewMailer(name, outboxPath string, config SMTPConfig, development bool) (Mailer, error) {
+	if config.From != "" {
+		if _, err := mail.ParseAddress(config.From); err != nil {
+			return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", config.From, err)
+		}
+	}
+
+	switch strings.TrimSpace(name) {
+	case MailerSMTP:
+		if config.Host == "" {
+			return nil, fmt.Errorf("mailer %q needs SMTP_HOST", name)
+		}
+		return NewSMTPMailer(config)
+	case MailerFile:
+		if outboxPath == "" {
+			return nil, fmt.Errorf("mailer %q needs MAIL_OUTBOX_PATH", name)
+		}
+		return NewFileMailer(outboxPath, config.From), nil
+	case MailerLog, "":
+		if !development {
+			return nil, fmt.Errorf("mailer %q delivers no mail; set MAILER to smtp or file", MailerLog)
+		}
+		return NewFileMailer("", config.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", name)
+	}
+}
+
+// SMTPMailer sends mail through an SMTP relay, using STARTTLS when the
+// server offers it.
+type SMTPMailer struct {
+	config SMTPConfig
+	// sender is the bare address of config.From, used as the envelope
+	// sender; the From header keeps the display name
+	sender string
+}
+
+// NewSMTPMailer returns a mailer for the relay in config. From must parse
+// as an address.
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", config.From, err)
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &SMTPMailer{config: config, sender: from.Address}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg MailMessage) error {
+	data, err := formatMessage(m.config.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+
+	// net/smtp takes no context, so the send is abandoned rather than
+	// cancelled when ctx is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.sender, []string{msg.To}, data)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileMailer appends messages to an outbox file for local development.
+// With no path, only the recipient and subject are logged, as bodies
+// carry single-use links that must not end up in logs.
+type FileMailer struct {
+	path string
+	from string
+	mu   sync.Mutex
+}
+
+func NewFileMailer(path, from string) *FileMailer {
+	return &FileMailer{path: path, from: from}
+}
+
+func (m *FileMailer) Send(_ context.Context, msg MailMessage) error {
+	data, err := formatMessage(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if m.path == "" {
+		log.Printf("mail to %s: %q (body not logged)", msg.To, msg.Subject)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, "\r\n\r\n"...)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// formatMessage renders msg with its headers. Header values must not
+// contain line breaks, which would let them add headers of their own.
+func formatMessage(from string, msg MailMessage, date time.Time) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("mail header contains a line break: %q", value)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
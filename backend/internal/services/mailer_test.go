@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMessage(t *testing.T) {
+	date := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	data, err := formatMessage("Symbol Quest <no-reply@example.com>", MailMessage{
+		To:      "user@example.com",
+		Subject: "Hello",
+		Body:    "Line one\nLine two\n",
+	}, date)
+	if err != nil {
+		t.Fatalf("formatMessage returned error: %v", err)
+	}
+
+	message := string(data)
+	for _, want := range []string{
+		"From: Symbol Quest <no-reply@example.com>\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: Fri, 01 Mar 2024 09:30:00 +0000\r\n",
+		"\r\n\r\nLine one\r\nLine two\r\n",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected %q in message:\n%s", want, message)
+		}
+	}
+
+	if _, err := formatMessage("", MailMessage{To: "user@example.com\r\nBcc: other@example.com"}, date); err == nil {
+		t.Error("Expected a line break in a header to be rejected")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.eml")
+	mailer := NewFileMailer(path, "no-reply@example.com")
+
+	for _, subject := range []string{"First", "Second"} {
+		if err := mailer.Send(context.Background(), MailMessage{To: "user@example.com", Subject: subject, Body: "Hi"}); err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the outbox to be written: %v", err)
+	}
+	if !strings.Contains(string(data), "Subject: First") || !strings.Contains(string(data), "Subject: Second") {
+		t.Errorf("Expected both messages in the outbox, got:\n%s", data)
+	}
+}
+
+func TestFileMailer_LogOmitsBody(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	mailer := NewFileMailer("", "no-reply@example.com")
+	err := mailer.Send(context.Background(), MailMessage{To: "user@example.com", Subject: "Reset your password", Body: "https://app.example.com/reset-password?token=secret-token"})
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	if !strings.Contains(output.String(), "user@example.com") || !strings.Contains(output.String(), "Reset your password") {
+		t.Errorf("Expected the recipient and subject to be logged, got %q", output.String())
+	}
+	if strings.Contains(output.String(), "secret-token") {
+		t.Errorf("Expected the body not to be logged, got %q", output.String())
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	if mailer, err := NewMailer("log", "", SMTPConfig{}, true); err != nil || mailer == nil {
+		t.Errorf("Expected the log mailer, got %v, %v", mailer, err)
+	}
+	if _, err := NewMailer("smtp", "", SMTPConfig{}, true); err == nil {
+		t.Error("Expected smtp to need a host")
+	}
+	if _, err := NewMailer("smtp", "", SMTPConfig{Host: "smtp.example.com"}, false); err == nil {
+		t.Error("Expected smtp to need a sender")
+	}
+	mailer, err := NewMailer("smtp", "", SMTPConfig{Host: "smtp.example.com", From: "Symbol Quest <no-reply@example.com>"}, false)
+	if err != nil {
+		t.Fatalf("NewMailer returned error: %v", err)
+	}
+	if smtpMailer := mailer.(*SMTPMailer); smtpMailer.config.Port != 587 || smtpMailer.sender != "no-reply@example.com" {
+		t.Errorf("Expected the submission port and a bare sender, got %+v", smtpMailer)
+	}
+	if _, err := NewMailer("log", "", SMTPConfig{From: "Symbol Quest <no-reply"}, true); err == nil {
+		t.Error("Expected an invalid sender to be rejected")
+	}
+	if _, err := NewMailer("log", "", SMTPConfig{}, false); err == nil {
+		t.Error("Expected the log mailer to be refused outside development")
+	}
+	if _, err := NewMailer("file", "", SMTPConfig{}, true); err == nil {
+		t.Error("Expected file to need an outbox path")
+	}
+	if _, err := NewMailer("pigeon", "", SMTPConfig{}, true); err == nil {
+		t.Error("Expected an unknown mailer to be rejected")
+	}
+}
+
+func TestSMTPMailerEnvelopeSender(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	commands := make(chan string, 64)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		defer close(commands)
+		serveFakeSMTP(conn, commands)
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailer, err := NewSMTPMailer(SMTPConfig{
+		Host: "127.0.0.1",
+		Port: addr.Port,
+		From: "Symbol Quest <no-reply@example.com>",
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer returned error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mailer.Send(ctx, MailMessage{To: "user@example.com", Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	var mailFrom, fromHeader string
+	for command := range commands {
+		switch {
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mailFrom = command
+		case strings.HasPrefix(command, "From: "):
+			fromHeader = command
+		}
+	}
+	if mailFrom != "MAIL FROM:<no-reply@example.com>" {
+		t.Errorf("Expected the bare address as envelope sender, got %q", mailFrom)
+	}
+	if fromHeader != "From: Symbol Quest <no-reply@example.com>" {
+		t.Errorf("Expected the display name in the From header, got %q", fromHeader)
+	}
+}
+
+// serveFakeSMTP answers one SMTP session without extensions, sending each
+// command and message line it reads to lines.
+func serveFakeSMTP(conn net.Conn, lines chan<- string) {
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines <- line
+
+		if inData {
+			if line == "." {
+				inData = false
+				reply("250 OK")
+			}
+			continue
+		}
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); {
+		case verb == "EHLO" || verb == "HELO":
+			reply("250 localhost")
+		case verb == "DATA":
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
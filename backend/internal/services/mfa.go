@@ -0,0 +1,308 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"symbol-quest/internal/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Two-factor authentication is optional. Users enrol by scanning a TOTP
+// secret into an authenticator app and confirming a code, which also
+// gives them one-time recovery codes. Logging in then takes two steps:
+// the password gets an "mfa pending" token, which is exchanged together
+// with a code for a session.
+
+// DefaultMFATokenTTL is how long the second login step may take.
+const DefaultMFATokenTTL = 5 * time.Minute
+
+// recoveryCodeCount is how many recovery codes enrolment issues.
+const recoveryCodeCount = 10
+
+// mfaPendingType is the "typ" claim of mfa pending tokens, which are not
+// access tokens.
+const mfaPendingType = "mfa_pending"
+
+// More events written to auth_audit_log.
+const (
+	AuditMFAEnabled       = "mfa_enabled"
+	AuditMFADisabled      = "mfa_disabled"
+	AuditRecoveryCodeUsed = "mfa_recovery_code_used"
+)
+
+// MFAEnabled reports whether the user has two-factor authentication on.
+func (s *AuthService) MFAEnabled(userID uuid.UUID) (bool, error) {
+	var enabled bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL)", userID).Scan(&enabled)
+	return enabled, err
+}
+
+// SetupMFA starts enrolment with a new secret, replacing one from an
+// earlier unfinished setup. It is not used until EnableMFA confirms it.
+func (s *AuthService) SetupMFA(userID uuid.UUID) (*models.MFASetupResponse, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`
+		INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = $2, last_counter = 0, created_at = NOW()
+		WHERE user_mfa.enabled_at IS NULL
+	`, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.MFASetupResponse{
+		Secret:          secret,
+		ProvisioningURI: provisioningURI(secret, user.Email),
+	}, nil
+}
+
+// EnableMFA turns two-factor authentication on once code shows that the
+// user's app has the secret from SetupMFA. It returns the recovery codes,
+// which are only ever shown here.
+func (s *AuthService) EnableMFA(userID uuid.UUID, code string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret string
+	var enabled bool
+	err = tx.QueryRow("SELECT secret, enabled_at IS NOT NULL FROM user_mfa WHERE user_id = $1 FOR UPDATE", userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrMFANotSetUp
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	counter, ok := matchTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if _, err := tx.Exec("UPDATE user_mfa SET enabled_at = NOW(), last_counter = $2 WHERE user_id = $1", userID, counter); err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.audit(AuditMFAEnabled, userID, "", "")
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off. The user has to sign in
+// again for it: with their password and a code or recovery code. Wrong
+// answers count as failed logins.
+func (s *AuthService) DisableMFA(userID uuid.UUID, password, code, ip string) error {
+	var email, passwordHash string
+	err := s.db.QueryRow("SELECT email, password_hash FROM users WHERE id = $1", userID).Scan(&email, &passwordHash)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	account := normalizeEmail(email)
+	if err := s.checkLogin(account, ip); err != nil {
+		return err
+	}
+	if enabled, err := s.MFAEnabled(userID); err != nil || !enabled {
+		if err == nil {
+			err = ErrMFANotEnabled
+		}
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		if err := s.recordLoginFailure(account, ip, &models.User{ID: userID, Email: email}); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+	if err := s.checkSecondFactor(userID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.recordLoginFailure(account, ip, &models.User{ID: userID, Email: email}); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM user_mfa WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.audit(AuditMFADisabled, userID, account, ip)
+	return nil
+}
+
+// CompleteMFALogin is the second login step: it exchanges an mfa pending
+// token and a code or recovery code for a session. It is throttled like
+// the password step.
+func (s *AuthService) CompleteMFALogin(mfaToken, code, ip string) (*models.User, *models.TokenResponse, error) {
+	userID, err := s.validateMFAToken(mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.GetUserByID(userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account := normalizeEmail(user.Email)
+	if err := s.checkLogin(account, ip); err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkSecondFactor(userID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.recordLoginFailure(account, ip, user); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, err
+	}
+	s.clearLoginFailures(account)
+
+	tokens, err := s.StartSession(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// checkSecondFactor accepts a TOTP code that has not been used before or
+// an unused recovery code, which it uses up.
+func (s *AuthService) checkSecondFactor(userID uuid.UUID, code string) error {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) == totpDigits {
+		var secret string
+		err := s.db.QueryRow("SELECT secret FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL", userID).Scan(&secret)
+		if err == sql.ErrNoRows {
+			return ErrMFANotEnabled
+		}
+		if err != nil {
+			return err
+		}
+
+		counter, ok := matchTOTP(secret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		// Each code works once, even within its time step
+		result, err := s.db.Exec("UPDATE user_mfa SET last_counter = $2 WHERE user_id = $1 AND last_counter < $2", userID, counter)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrInvalidMFACode
+	}
+	s.audit(AuditRecoveryCodeUsed, userID, "", "")
+	return nil
+}
+
+// mfaChallenge issues the token for the second login step.
+func (s *AuthService) mfaChallenge(user *models.User) (*models.MFAChallenge, error) {
+	expiresAt := time.Now().Add(DefaultMFATokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID.String(),
+		"typ":     mfaPendingType,
+		"exp":     expiresAt.Unix(),
+	})
+	signed, err := token.SignedString(s.jwtSecret)
+	if err != nil {
+		return nil, fmt.Errorf("sign mfa token: %w", err)
+	}
+	return &models.MFAChallenge{MFARequired: true, MFAToken: signed, ExpiresAt: expiresAt}, nil
+}
+
+func (s *AuthService) validateMFAToken(tokenString string) (uuid.UUID, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil || claims["typ"] != mfaPendingType {
+		return uuid.Nil, ErrInvalidToken
+	}
+	userIDStr, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// replaceRecoveryCodes issues a new set of recovery codes, dropping the
+// old ones. Codes are 50 random bits, shown as two groups of five letters
+// and digits; only their hash is stored.
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hashToken(code)); err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"symbol-quest/internal/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_MFATokens(t *testing.T) {
+	service := NewAuthService(nil, "test-secret-key")
+	userID := uuid.New()
+
+	challenge, err := service.mfaChallenge(&models.User{ID: userID})
+	if err != nil {
+		t.Fatalf("mfaChallenge returned error: %v", err)
+	}
+	if !challenge.MFARequired || time.Until(challenge.ExpiresAt) > DefaultMFATokenTTL {
+		t.Errorf("Unexpected challenge %+v", challenge)
+	}
+	if _, err := service.ValidateToken(challenge.MFAToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an mfa pending token not to work as an access token, got %v", err)
+	}
+	if got, err := service.validateMFAToken(challenge.MFAToken); err != nil || got != userID {
+		t.Errorf("Expected the token to name the user, got %v, %v", got, err)
+	}
+
+	accessToken, _ := service.GenerateToken(userID, "user@example.com", "free")
+	if _, err := service.validateMFAToken(accessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an access token not to work as an mfa pending token, got %v", err)
+	}
+}
+
+func TestAuthService_MFA(t *testing.T) {
+	db, userID := testDatabase(t)
+	email := userID.String() + "@example.com"
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password-123"), bcrypt.MinCost)
+	db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", string(hash), userID)
+	service := NewAuthService(db, "test-secret-key")
+
+	setup, err := service.SetupMFA(userID)
+	if err != nil {
+		t.Fatalf("SetupMFA returned error: %v", err)
+	}
+	key, _ := totpEncoding.DecodeString(setup.Secret)
+	step := uint64(time.Now().Unix()) / 30
+
+	if _, err := service.EnableMFA(userID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected a wrong code to be rejected, got %v", err)
+	}
+	codes, err := service.EnableMFA(userID, totpCode(key, step))
+	if err != nil {
+		t.Fatalf("EnableMFA returned error: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %v", recoveryCodeCount, codes)
+	}
+	if _, err := service.SetupMFA(userID); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("Expected ErrMFAAlreadyEnabled, got %v", err)
+	}
+
+	login := func() string {
+		t.Helper()
+		user, tokens, challenge, err := service.Login(email, "password-123", "")
+		if err != nil || user != nil || tokens != nil || challenge == nil {
+			t.Fatalf("Expected a challenge, got %v, %v, %v, %v", user, tokens, challenge, err)
+		}
+		return challenge.MFAToken
+	}
+
+	// The code used to enable is spent
+	if _, _, err := service.CompleteMFALogin(login(), totpCode(key, step), ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected a used code to be rejected, got %v", err)
+	}
+	user, tokens, err := service.CompleteMFALogin(login(), totpCode(key, step+1), "")
+	if err != nil {
+		t.Fatalf("CompleteMFALogin returned error: %v", err)
+	}
+	if !user.MFAEnabled || tokens.RefreshToken == "" {
+		t.Errorf("Expected a session for an enrolled user, got %+v, %+v", user, tokens)
+	}
+
+	// Recovery codes work once, with or without the dash
+	recovery := codes[0]
+	if _, _, err := service.CompleteMFALogin(login(), recovery[:5]+recovery[6:], ""); err != nil {
+		t.Errorf("Expected the recovery code to work, got %v", err)
+	}
+	if _, _, err := service.CompleteMFALogin(login(), recovery, ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected a used recovery code to be rejected, got %v", err)
+	}
+
+	if err := service.DisableMFA(userID, "wrong-password", codes[1], ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected the password to be checked, got %v", err)
+	}
+	if err := service.DisableMFA(userID, "password-123", codes[1], ""); err != nil {
+		t.Fatalf("DisableMFA returned error: %v", err)
+	}
+	if _, tokens, challenge, err := service.Login(email, "password-123", ""); err != nil || tokens == nil || challenge != nil {
+		t.Errorf("Expected a one-step login after disabling, got %v, %v, %v", tokens, challenge, err)
+	}
+}
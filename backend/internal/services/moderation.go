@@ -0,0 +1,469 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"symbol-quest/internal/models"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxQuestionLength is the longest question accepted for a draw, spread or
+// reading, in characters.
+const MaxQuestionLength = 500
+
+// Where screened text came from, as recorded in the review queue.
+const (
+	ModerationSourceDraw           = "draw"
+	ModerationSourceSpread         = "spread"
+	ModerationSourceInterpretation = "interpretation"
+	ModerationSourceChat           = "chat"
+)
+
+// What screening decided about a piece of text.
+const (
+	// ModerationAllow lets the text through.
+	ModerationAllow = "allow"
+	// ModerationFlag lets the text through but queues it for review.
+	ModerationFlag = "flag"
+	// ModerationBlock rejects the text and queues it for review.
+	ModerationBlock = "block"
+	// ModerationCrisis answers with support resources instead of a
+	// reading and queues the text for review.
+	ModerationCrisis = "crisis"
+)
+
+// Categories of screened text.
+const (
+	ModerationCategoryInjection        = "prompt_injection"
+	ModerationCategoryBlocklist        = "blocklist"
+	ModerationCategorySelfHarm         = "self_harm"
+	ModerationCategoryMedicalEmergency = "medical_emergency"
+)
+
+// Review queue statuses.
+const (
+	ReviewPending   = "pending"
+	ReviewDismissed = "dismissed"
+	ReviewConfirmed = "confirmed"
+)
+
+// ModerationResult is the decision about one piece of text.
+type ModerationResult struct {
+	Action   string
+	Category string
+	// Reason says what matched, for reviewers.
+	Reason string
+}
+
+// ModerationVerdict is a moderation provider's classification of a text.
+type ModerationVerdict struct {
+	Flagged bool
+	// Categories are the provider's names for what it found, e.g.
+	// "self-harm/intent".
+	Categories []string
+}
+
+// ModerationProvider classifies text with an external service.
+type ModerationProvider interface {
+	Name() string
+	Moderate(ctx context.Context, text string) (*ModerationVerdict, error)
+}
+
+// NewModerationProvider returns the named provider: "openai", which is
+// skipped without an API key, or "none". It returns nil when no provider
+// is used.
+func NewModerationProvider(name, openAIAPIKey string) (ModerationProvider, error) {
+	switch strings.TrimSpace(name) {
+	case ModerationProviderOpenAI:
+		if openAIAPIKey == "" {
+			return nil, nil
+		}
+		return NewOpenAIModerator(openAIAPIKey), nil
+	case "none", "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown moderation provider %q", name)
+}
+
+// crisisPatterns are questions that need real help rather than a reading.
+var crisisPatterns = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{ModerationCategorySelfHarm, regexp.MustCompile(`\b(kill(ing)?|hurt(ing)?|harm(ing)?|cut(ting)?) myself\b`)},
+	{ModerationCategorySelfHarm, regexp.MustCompile(`\bsuicid(e|al)\b`)},
+	{ModerationCategorySelfHarm, regexp.MustCompile(`\bself[- ]?harm`)},
+	{ModerationCategorySelfHarm, regexp.MustCompile(`\b(end|take) (it all|my (own )?life)\b`)},
+	{ModerationCategorySelfHarm, regexp.MustCompile(`\b(want|wanting|going) to die\b`)},
+	{ModerationCategorySelfHarm, regexp.MustCompile(`\boverdos(e|ed|ing)\b`)},
+	{ModerationCategoryMedicalEmergency, regexp.MustCompile(`\bheart attack\b`)},
+	{ModerationCategoryMedicalEmergency, regexp.MustCompile(`\b(can'?t|cannot|can not) breathe\b`)},
+	{ModerationCategoryMedicalEmergency, regexp.MustCompile(`\bchest pains?\b`)},
+	{ModerationCategoryMedicalEmergency, regexp.MustCompile(`\b(having|had) a (stroke|seizure)\b`)},
+	{ModerationCategoryMedicalEmergency, regexp.MustCompile(`\b(won'?t stop bleeding|bleeding (heavily|badly|a lot))\b`)},
+	{ModerationCategoryMedicalEmergency, regexp.MustCompile(`\b(unconscious|not breathing|been poisoned)\b`)},
+}
+
+// injectionPatterns are attempts to override the reader's instructions.
+var injectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(ignore|disregard|forget|override) (all |any )?(of )?(the |your |my )?(previous|prior|above|earlier|system) (instructions|prompts?|rules|messages)\b`),
+	regexp.MustCompile(`\b(reveal|show|print|repeat|output) (me )?(your|the) (system )?(prompt|instructions)\b`),
+	regexp.MustCompile(`\bsystem prompt\b`),
+	regexp.MustCompile(`\byou are (now|no longer)\b`),
+	regexp.MustCompile(`\b(jailbreak|dan mode|developer mode)\b`),
+	regexp.MustCompile(`</?(system|assistant|user)>|\[/?inst\]|<\|im_(start|end)\|>`),
+	regexp.MustCompile(`(?m)^\s*(system|assistant)\s*:`),
+}
+
+// blockingCategories are the provider categories that reject text outright.
+// Other flagged categories only queue it for review, since readings
+// naturally talk about death, conflict and loss.
+var blockingCategories = map[string]bool{
+	"sexual/minors":          true,
+	"hate/threatening":       true,
+	"harassment/threatening": true,
+	"violence/graphic":       true,
+	"illicit/violent":        true,
+}
+
+// CrisisResource is somewhere a user in crisis can get help.
+type CrisisResource struct {
+	Name    string `json:"name"`
+	Contact string `json:"contact"`
+	URL     string `json:"url,omitempty"`
+}
+
+// CrisisResources are sent with the crisis response for each category.
+var CrisisResources = map[string][]CrisisResource{
+	ModerationCategorySelfHarm: {
+		{Name: "988 Suicide & Crisis Lifeline (US)", Contact: "Call or text 988", URL: "https://988lifeline.org"},
+		{Name: "Samaritans (UK and Ireland)", Contact: "Call 116 123", URL: "https://www.samaritans.org"},
+		{Name: "Find a helpline in your country", Contact: "Free and confidential", URL: "https://findahelpline.com"},
+	},
+	ModerationCategoryMedicalEmergency: {
+		{Name: "Emergency services", Contact: "Call 911 (US), 999 (UK), 112 (EU) or your local emergency number"},
+	},
+}
+
+// crisisMessages replace the reading for each crisis category.
+var crisisMessages = map[string]string{
+	ModerationCategorySelfHarm:         "it sounds like you are going through something really painful. A tarot reading can't help with this, but you don't have to face it alone - please reach out to one of these services, they are free and confidential",
+	ModerationCategoryMedicalEmergency: "this sounds like a medical emergency. Please contact emergency services now rather than waiting for a reading",
+}
+
+// ModerationService screens user questions before they reach a prompt or
+// the database, and model answers before they reach the user. Questions go
+// through a length limit, crisis topics, prompt-injection patterns, the
+// local blocklist and then the provider, if one is set. Flagged and
+// rejected text is queued for review. A nil *ModerationService allows
+// everything.
+type ModerationService struct {
+	db        *sql.DB
+	provider  ModerationProvider
+	blocklist map[string]bool
+}
+
+// NewModerationService returns a service using provider, which may be nil
+// to rely on the local checks only.
+func NewModerationService(db *sql.DB, provider ModerationProvider) *ModerationService {
+	return &ModerationService{db: db, provider: provider, blocklist: map[string]bool{}}
+}
+
+// SetBlocklist replaces the blocked words and phrases. They match whole
+// words regardless of case and punctuation.
+func (s *ModerationService) SetBlocklist(terms []string) {
+	s.blocklist = make(map[string]bool, len(terms))
+	for _, term := range terms {
+		if term = normalizeText(term); term != "" {
+			s.blocklist[term] = true
+		}
+	}
+}
+
+// LoadBlocklist reads one blocked word or phrase per line. Blank lines and
+// lines starting with # are ignored.
+func LoadBlocklist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var terms []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			terms = append(terms, line)
+		}
+	}
+	return terms, scanner.Err()
+}
+
+// ScreenQuestion checks the length of a question and then screens it like
+// ScreenInput. Empty questions are always allowed.
+func (s *ModerationService) ScreenQuestion(ctx context.Context, userID uuid.UUID, source, question string) error {
+	if s == nil || strings.TrimSpace(question) == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(question) > MaxQuestionLength {
+		return ErrQuestionTooLong.WithDetails(map[string]interface{}{"max_length": MaxQuestionLength})
+	}
+	return s.ScreenInput(ctx, userID, source, question)
+}
+
+// ScreenInput returns ErrContentBlocked for text that must not be used,
+// and a crisis error carrying support resources for text that needs help
+// rather than a reading. Flagged text is allowed.
+func (s *ModerationService) ScreenInput(ctx context.Context, userID uuid.UUID, source, text string) error {
+	if s == nil {
+		return nil
+	}
+
+	result := s.screenInput(ctx, text)
+	if result.Action == ModerationAllow {
+		return nil
+	}
+	s.queue(userID, source, "input", result, text)
+
+	switch result.Action {
+	case ModerationCrisis:
+		return ErrCrisisSupport.withMessage(crisisMessages[result.Category]).WithDetails(map[string]interface{}{
+			"category":  result.Category,
+			"resources": CrisisResources[result.Category],
+		})
+	case ModerationBlock:
+		return ErrContentBlocked
+	}
+	return nil
+}
+
+// ScreenOutput returns ErrContentWithheld when a model's answer must not
+// be shown. Answers are checked against the blocklist and the provider.
+func (s *ModerationService) ScreenOutput(ctx context.Context, userID uuid.UUID, source, text string) error {
+	if s == nil {
+		return nil
+	}
+
+	result := s.screenOutput(ctx, text)
+	if result.Action == ModerationAllow {
+		return nil
+	}
+	s.queue(userID, source, "output", result, text)
+
+	if result.Action == ModerationBlock {
+		return ErrContentWithheld
+	}
+	return nil
+}
+
+// streamScreenBatch is the least text, in bytes, an outputStream screens
+// at once, so that short sentences share a provider call.
+const streamScreenBatch = 200
+
+// outputStream screens a streamed answer in batches of whole sentences.
+// Each batch is held back until it has passed screening on its own, so a
+// withheld answer stops before its offending sentence reaches the user.
+// The text after the last batch is sent by finish, once the whole answer
+// has been screened with ScreenOutput.
+type outputStream struct {
+	ctx        context.Context
+	moderation *ModerationService
+	userID     uuid.UUID
+	source     string
+	onToken    func(string) error
+	pending    strings.Builder
+}
+
+func (s *ModerationService) newOutputStream(ctx context.Context, userID uuid.UUID, source string, onToken func(string) error) *outputStream {
+	return &outputStream{ctx: ctx, moderation: s, userID: userID, source: source, onToken: onToken}
+}
+
+// write takes the next token of the answer.
+func (o *outputStream) write(token string) error {
+	if o.moderation == nil {
+		return o.onToken(token)
+	}
+	o.pending.WriteString(token)
+	if o.pending.Len() < streamScreenBatch || !strings.ContainsAny(token, ".!?\n") {
+		return nil
+	}
+
+	batch := o.pending.String()
+	result := o.moderation.screenOutput(o.ctx, batch)
+	if result.Action == ModerationBlock {
+		o.moderation.queue(o.userID, o.source, "output", result, batch)
+		return ErrContentWithheld
+	}
+	return o.flush()
+}
+
+// finish sends the text held back since the last batch.
+func (o *outputStream) finish() error {
+	if o.pending.Len() == 0 {
+		return nil
+	}
+	return o.flush()
+}
+
+func (o *outputStream) flush() error {
+	chunk := o.pending.String()
+	o.pending.Reset()
+	return o.onToken(chunk)
+}
+
+func (s *ModerationService) screenInput(ctx context.Context, text string) ModerationResult {
+	lower := strings.ToLower(text)
+	for _, crisis := range crisisPatterns {
+		if match := crisis.pattern.FindString(lower); match != "" {
+			return ModerationResult{Action: ModerationCrisis, Category: crisis.category, Reason: fmt.Sprintf("matched %q", match)}
+		}
+	}
+	for _, pattern := range injectionPatterns {
+		if match := pattern.FindString(lower); match != "" {
+			return ModerationResult{Action: ModerationBlock, Category: ModerationCategoryInjection, Reason: fmt.Sprintf("matched %q", match)}
+		}
+	}
+	return s.screenOutput(ctx, text)
+}
+
+// screenOutput runs the checks shared by questions and answers.
+func (s *ModerationService) screenOutput(ctx context.Context, text string) ModerationResult {
+	if term := s.blocked(text); term != "" {
+		return ModerationResult{Action: ModerationBlock, Category: ModerationCategoryBlocklist, Reason: fmt.Sprintf("matched %q", term)}
+	}
+	if s.provider == nil {
+		return ModerationResult{Action: ModerationAllow}
+	}
+
+	// A provider outage leaves the local checks in place
+	verdict, err := s.provider.Moderate(ctx, text)
+	if err != nil {
+		log.Printf("moderation provider %s failed: %v", s.provider.Name(), err)
+		return ModerationResult{Action: ModerationAllow}
+	}
+	return verdictResult(s.provider.Name(), verdict)
+}
+
+// verdictResult turns a provider's verdict into a decision.
+func verdictResult(provider string, verdict *ModerationVerdict) ModerationResult {
+	if !verdict.Flagged {
+		return ModerationResult{Action: ModerationAllow}
+	}
+
+	result := ModerationResult{Action: ModerationFlag, Category: provider, Reason: strings.Join(verdict.Categories, ", ")}
+	for _, category := range verdict.Categories {
+		switch {
+		case strings.HasPrefix(category, "self-harm"):
+			return ModerationResult{Action: ModerationCrisis, Category: ModerationCategorySelfHarm, Reason: result.Reason}
+		case blockingCategories[category]:
+			result.Action = ModerationBlock
+		}
+	}
+	return result
+}
+
+// blocked returns the first blocklisted word or phrase in text, or "".
+func (s *ModerationService) blocked(text string) string {
+	if len(s.blocklist) == 0 {
+		return ""
+	}
+
+	words := strings.Fields(normalizeText(text))
+	for i := range words {
+		// Phrases are matched up to four words long
+		for n := 1; n <= 4 && i+n <= len(words); n++ {
+			if phrase := strings.Join(words[i:i+n], " "); s.blocklist[phrase] {
+				return phrase
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeText lowercases text and turns everything but letters and
+// digits into single spaces.
+func normalizeText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// queue adds screened text to the review queue. Failing to queue is
+// logged, as the decision has already been made.
+func (s *ModerationService) queue(userID uuid.UUID, source, direction string, result ModerationResult, text string) {
+	if s.db == nil {
+		return
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO moderation_flags (user_id, source, direction, action, category, reason, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, userID, source, direction, result.Action, result.Category, result.Reason, text)
+	if err != nil {
+		log.Printf("queue moderation flag: %v", err)
+	}
+}
+
+// ListFlags returns queued items with the given status, newest first. An
+// empty status lists every item.
+func (s *ModerationService) ListFlags(status string, limit int) ([]models.ModerationFlag, error) {
+	query := `
+		SELECT f.id, f.user_id, COALESCE(u.email, ''), f.source, f.direction, f.action, f.category,
+		       f.reason, f.content, f.status, COALESCE(f.reviewed_by, ''), f.reviewed_at, f.created_at
+		FROM moderation_flags f
+		LEFT JOIN users u ON u.id = f.user_id`
+	args := []interface{}{limit}
+	if status != "" {
+		query += " WHERE f.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY f.created_at DESC LIMIT $1"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []models.ModerationFlag{}
+	for rows.Next() {
+		var flag models.ModerationFlag
+		var reviewedAt sql.NullTime
+		err := rows.Scan(&flag.ID, &flag.UserID, &flag.Email, &flag.Source, &flag.Direction, &flag.Action, &flag.Category,
+			&flag.Reason, &flag.Content, &flag.Status, &flag.ReviewedBy, &reviewedAt, &flag.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if reviewedAt.Valid {
+			flag.ReviewedAt = &reviewedAt.Time
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// ReviewFlag records a reviewer's decision, ReviewDismissed or
+// ReviewConfirmed, on a queued item.
+func (s *ModerationService) ReviewFlag(flagID uuid.UUID, status, reviewer string) error {
+	result, err := s.db.Exec(`
+		UPDATE moderation_flags SET status = $1, reviewed_by = $2, reviewed_at = $3
+		WHERE id = $4
+	`, status, reviewer, time.Now(), flagID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrFlagNotFound
+	}
+	return nil
+}
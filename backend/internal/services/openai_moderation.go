@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ModerationProviderOpenAI is the name of the OpenAI moderation provider.
+const ModerationProviderOpenAI = "openai"
+
+// DefaultModerationTimeout bounds a call to the moderation API, which sits
+// in front of every question.
+const DefaultModerationTimeout = 5 * time.Second
+
+// OpenAIModerator classifies text with the OpenAI moderation endpoint.
+type OpenAIModerator struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewOpenAIModerator(apiKey string) *OpenAIModerator {
+	return &OpenAIModerator{
+		baseURL: DefaultOpenAIBaseURL,
+		apiKey:  apiKey,
+		model:   "omni-moderation-latest",
+		client:  &http.Client{Timeout: DefaultModerationTimeout},
+	}
+}
+
+type openAIModerationRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type openAIModerationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+	Error *openAIError `json:"error,omitempty"`
+}
+
+func (m *OpenAIModerator) Name() string {
+	return ModerationProviderOpenAI
+}
+
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) (*ModerationVerdict, error) {
+	body, err := json.Marshal(openAIModerationRequest{Model: m.model, Input: text})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.baseURL+"/moderations", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return nil, &UpstreamError{Provider: m.Name(), Kind: ErrUpstreamTransient, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var moderation openAIModerationResponse
+	decodeErr := json.Unmarshal(data, &moderation)
+	if resp.StatusCode != http.StatusOK {
+		var code, message string
+		if decodeErr == nil && moderation.Error != nil {
+			code, message = moderation.Error.Code, moderation.Error.Message
+		}
+		return nil, classifyStatus(m.Name(), resp, code, message)
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	if len(moderation.Results) == 0 {
+		return &ModerationVerdict{}, nil
+	}
+
+	result := moderation.Results[0]
+	verdict := &ModerationVerdict{Flagged: result.Flagged}
+	for category, flagged := range result.Categories {
+		if flagged {
+			verdict.Categories = append(verdict.Categories, category)
+		}
+	}
+	sort.Strings(verdict.Categories)
+	return verdict, nil
+}
@@ -1,18 +1,46 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strings"
+	"symbol-quest/internal/prompts"
 	"symbol-quest/internal/tarot"
+	"sync/atomic"
+	"time"
 )
 
+// DefaultOpenAIBaseURL is the OpenAI API root that chat completions are
+// posted under.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+
+// DefaultInterpreterTimeout bounds each attempt at a model API call. A
+// streamed reading may take longer, as long as text keeps arriving.
+const DefaultInterpreterTimeout = 30 * time.Second
+
+// OpenAIService interprets cards with the OpenAI chat completions API or
+// any server that implements it, such as a local model server. Calls are
+// retried with backoff on rate limits and transient failures, and a
+// circuit breaker stops calling an API that keeps failing.
 type OpenAIService struct {
-	apiKey string
-	client *http.Client
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	// requireKey is false for self-hosted endpoints that need no key.
+	requireKey bool
+	client     *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+	breaker    *CircuitBreaker
 }
 
 type OpenAIRequest struct {
@@ -20,6 +48,13 @@ type OpenAIRequest struct {
 	Messages []Message `json:"messages"`
 	MaxTokens int      `json:"max_tokens"`
 	Temperature float64 `json:"temperature"`
+	Stream      bool    `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions asks the OpenAI API to end a stream with the token usage.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type Message struct {
@@ -28,122 +63,488 @@ type Message struct {
 }
 
 type OpenAIResponse struct {
-	Choices []Choice `json:"choices"`
-	Error   *struct {
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
+	Choices []Choice     `json:"choices"`
+	Usage   *openAIUsage `json:"usage,omitempty"`
+	Error   *openAIError `json:"error,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
 }
 
 type Choice struct {
-	Message Message `json:"message"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason,omitempty"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
 }
 
+// finishContentFilter is the finish reason of an answer that was cut off
+// by the provider's content filter.
+const finishContentFilter = "content_filter"
+
 func NewOpenAIService(apiKey string) *OpenAIService {
 	return &OpenAIService{
-		apiKey: apiKey,
-		client: &http.Client{},
+		name:       InterpreterOpenAI,
+		baseURL:    DefaultOpenAIBaseURL,
+		apiKey:     apiKey,
+		model:      "gpt-3.5-turbo",
+		requireKey: true,
+		client:     &http.Client{},
+		timeout:    DefaultInterpreterTimeout,
+		retry:      DefaultRetryPolicy,
+		breaker:    NewCircuitBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
 	}
 }
 
-func (s *OpenAIService) GenerateEnhancedInterpretation(cardID int, mood, question string) (string, error) {
-	if s.apiKey == "" {
-		return "", errors.New("OpenAI API key not configured")
+// NewOpenAICompatibleService talks to an OpenAI-compatible server at
+// baseURL, e.g. "http://localhost:11434/v1". apiKey may be empty.
+func NewOpenAICompatibleService(baseURL, apiKey, model string) *OpenAIService {
+	return &OpenAIService{
+		name:    InterpreterCompatible,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+		timeout: DefaultInterpreterTimeout,
+		retry:   DefaultRetryPolicy,
+		breaker: NewCircuitBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
 	}
+}
 
-	card, exists := tarot.MajorArcana[cardID]
-	if !exists {
-		return "", errors.New("invalid card ID")
+// SetTimeout sets how long one attempt may wait for the API, or for the
+// next part of a streamed answer.
+func (s *OpenAIService) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.timeout = timeout
 	}
+}
 
-	prompt := s.buildPrompt(card, mood, question)
+func (s *OpenAIService) SetRetryPolicy(policy RetryPolicy) {
+	s.retry = policy
+}
 
-	req := OpenAIRequest{
-		Model: "gpt-3.5-turbo",
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are a wise and compassionate tarot reader who provides personalized, insightful interpretations that blend traditional tarot wisdom with modern psychological insights. Your readings are supportive, empowering, and help people gain clarity and perspective.",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		MaxTokens:   400,
-		Temperature: 0.7,
+func (s *OpenAIService) SetCircuitBreaker(breaker *CircuitBreaker) {
+	s.breaker = breaker
+}
+
+func (s *OpenAIService) Name() string {
+	return s.name
+}
+
+func (s *OpenAIService) Interpret(ctx context.Context, req InterpretationRequest) (*Interpretation, error) {
+	if s.requireKey && s.apiKey == "" {
+		return nil, ErrInterpreterUnavailable
 	}
 
-	jsonData, err := json.Marshal(req)
+	chatReq, prompt, err := s.newChatRequest(req, false)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	text, usage, err := s.complete(ctx, chatReq)
+	if err != nil {
+		return nil, err
 	}
+	return s.interpretation(text, prompt, usage), nil
+}
 
-	httpReq, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+func (s *OpenAIService) GenerateEnhancedInterpretation(card tarot.Card, orientation tarot.Orientation, mood, question string) (string, error) {
+	interpretation, err := s.Interpret(context.Background(), InterpretationRequest{
+		Card:        card,
+		Orientation: orientation,
+		Mood:        mood,
+		Question:    question,
+	})
 	if err != nil {
 		return "", err
 	}
+	return interpretation.Text, nil
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
+// FollowUp answers a question about a reading. The model sees the original
+// prompt, the reading it gave and the thread so far.
+func (s *OpenAIService) FollowUp(ctx context.Context, req FollowUpRequest) (*Interpretation, error) {
+	if s.requireKey && s.apiKey == "" {
+		return nil, ErrInterpreterUnavailable
+	}
 
-	resp, err := s.client.Do(httpReq)
+	prompt, err := renderPrompt(req.InterpretationRequest)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	messages := []Message{
+		{Role: "system", Content: prompt.System + "\n\n" + prompt.FollowUp},
+		{Role: ChatRoleUser, Content: prompt.User},
+	}
+	if req.Reading != "" {
+		messages = append(messages, Message{Role: ChatRoleAssistant, Content: req.Reading})
+	}
+	for _, turn := range req.History {
+		messages = append(messages, Message{Role: turn.Role, Content: turn.Content})
+	}
+	messages = append(messages, Message{Role: ChatRoleUser, Content: req.Message})
+
+	maxTokens := prompt.MaxTokens
+	if req.MaxTokens > 0 && req.MaxTokens < maxTokens {
+		maxTokens = req.MaxTokens
+	}
+
+	text, usage, err := s.complete(ctx, OpenAIRequest{
+		Model:       s.modelFor(prompt),
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: prompt.Temperature,
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return s.interpretation(text, prompt, usage), nil
+}
+
+// complete sends a non-streaming chat request and returns the reply text
+// and what it used.
+func (s *OpenAIService) complete(ctx context.Context, req OpenAIRequest) (string, *Usage, error) {
+	call, err := s.send(ctx, req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer call.close()
 
 	var openaiResp OpenAIResponse
-	err = json.Unmarshal(body, &openaiResp)
+	err = json.Unmarshal(call.body, &openaiResp)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if openaiResp.Error != nil {
-		return "", errors.New("OpenAI API error: " + openaiResp.Error.Message)
+		return "", nil, s.bodyError(openaiResp.Error)
 	}
 
 	if len(openaiResp.Choices) == 0 {
-		return "", errors.New("no response from OpenAI")
+		return "", nil, errors.New("no response from OpenAI")
+	}
+
+	choice := openaiResp.Choices[0]
+	if choice.Message.Content == "" && choice.FinishReason == finishContentFilter {
+		return "", nil, &UpstreamError{Provider: s.name, Kind: ErrUpstreamContentPolicy, Message: "the answer was filtered"}
+	}
+	return choice.Message.Content, usageOf(req, openaiResp.Usage, choice.Message.Content), nil
+}
+
+// usageOf returns the token counts the API reported, or estimates them
+// from the text when it reported none.
+func usageOf(req OpenAIRequest, reported *openAIUsage, text string) *Usage {
+	if reported != nil && reported.PromptTokens+reported.CompletionTokens > 0 {
+		return &Usage{Model: req.Model, PromptTokens: reported.PromptTokens, CompletionTokens: reported.CompletionTokens}
+	}
+
+	usage := &Usage{Model: req.Model, CompletionTokens: EstimateTokens(text), Estimated: true}
+	for _, message := range req.Messages {
+		usage.PromptTokens += EstimateTokens(message.Content)
+	}
+	return usage
+}
+
+// InterpretStream asks for a streamed completion and passes each content
+// delta to onToken as it arrives. Cancelling ctx, or an error from
+// onToken, aborts the upstream request. Failures are only retried before
+// any text has been sent.
+func (s *OpenAIService) InterpretStream(ctx context.Context, req InterpretationRequest, onToken func(string) error) (*Interpretation, error) {
+	if s.requireKey && s.apiKey == "" {
+		return nil, ErrInterpreterUnavailable
+	}
+
+	chatReq, prompt, err := s.newChatRequest(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := s.send(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer call.close()
+
+	var text strings.Builder
+	var reported *openAIUsage
+	filtered := false
+	scanner := bufio.NewScanner(call.resp.Body)
+	for scanner.Scan() {
+		call.keepAlive()
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, err
+		}
+		if chunk.Error != nil {
+			return nil, s.bodyError(chunk.Error)
+		}
+		if chunk.Usage != nil {
+			reported = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			filtered = filtered || choice.FinishReason == finishContentFilter
+			if choice.Delta.Content == "" {
+				continue
+			}
+			text.WriteString(choice.Delta.Content)
+			if err := onToken(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, call.err(err)
+	}
+
+	if text.Len() == 0 {
+		if filtered {
+			return nil, &UpstreamError{Provider: s.name, Kind: ErrUpstreamContentPolicy, Message: "the answer was filtered"}
+		}
+		return nil, errors.New("no response from OpenAI")
+	}
+	return s.interpretation(text.String(), prompt, usageOf(chatReq, reported, text.String())), nil
+}
+
+// openAIStreamChunk is one server-sent event of a streamed completion.
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		FinishReason string  `json:"finish_reason,omitempty"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *openAIError `json:"error,omitempty"`
+}
+
+// send posts a chat request and returns the successful response, retrying
+// rate limited and transient failures as the retry policy allows. The
+// caller must close the call.
+func (s *OpenAIService) send(ctx context.Context, req OpenAIRequest) (*openAICall, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for retry := 0; ; retry++ {
+		if err := s.breaker.allow(); err != nil {
+			return nil, fmt.Errorf("%s API: %w", s.name, err)
+		}
+
+		call, err := s.attempt(ctx, body, req.Stream)
+		s.breaker.record(err)
+		if err == nil {
+			return call, nil
+		}
+
+		wait, ok := s.retry.delay(retry, err)
+		if !ok {
+			return nil, err
+		}
+		// Don't start a wait the caller's deadline will cut short
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Until(deadline) < wait {
+			return nil, err
+		}
+		log.Printf("%s: retrying in %s: %v", s.name, wait.Round(time.Millisecond), err)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt makes one request. A non-streaming response is read in full, so
+// that the timeout covers the whole answer.
+func (s *OpenAIService) attempt(ctx context.Context, body []byte, stream bool) (*openAICall, error) {
+	call := newOpenAICall(ctx, s.name, s.timeout)
+
+	httpReq, err := s.newRequest(call.ctx, body, stream)
+	if err != nil {
+		call.close()
+		return nil, err
+	}
+
+	call.resp, err = s.client.Do(httpReq)
+	if err != nil {
+		call.close()
+		return nil, call.err(err)
+	}
+
+	if call.resp.StatusCode != http.StatusOK {
+		defer call.close()
+		data, _ := io.ReadAll(io.LimitReader(call.resp.Body, 1<<20))
+		var openaiResp OpenAIResponse
+		var code, message string
+		if json.Unmarshal(data, &openaiResp) == nil && openaiResp.Error != nil {
+			code, message = openaiResp.Error.Code, openaiResp.Error.Message
+		}
+		return nil, classifyStatus(s.name, call.resp, code, message)
+	}
+
+	if !stream {
+		call.body, err = io.ReadAll(call.resp.Body)
+		if err != nil {
+			call.close()
+			return nil, call.err(err)
+		}
+	}
+	return call, nil
+}
+
+// bodyError is the error for an error object sent in a 200 response.
+func (s *OpenAIService) bodyError(apiErr *openAIError) *UpstreamError {
+	err := &UpstreamError{Provider: s.name, Code: apiErr.Code, Message: apiErr.Message}
+	if contentPolicyCodes[apiErr.Code] {
+		err.Kind = ErrUpstreamContentPolicy
+	}
+	return err
+}
+
+// openAICall is one request in flight. Its timer cancels the request when
+// the API has sent nothing for the timeout.
+type openAICall struct {
+	ctx      context.Context
+	parent   context.Context
+	cancel   context.CancelFunc
+	provider string
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+	resp     *http.Response
+	body     []byte
+}
+
+func newOpenAICall(parent context.Context, provider string, timeout time.Duration) *openAICall {
+	ctx, cancel := context.WithCancel(parent)
+	call := &openAICall{ctx: ctx, parent: parent, cancel: cancel, provider: provider, timeout: timeout}
+	call.timer = time.AfterFunc(timeout, func() {
+		call.timedOut.Store(true)
+		cancel()
+	})
+	return call
+}
 
-	return openaiResp.Choices[0].Message.Content, nil
+// keepAlive restarts the timeout after part of a stream has arrived.
+func (c *openAICall) keepAlive() {
+	c.timer.Reset(c.timeout)
 }
 
-func (s *OpenAIService) buildPrompt(card tarot.Card, mood, question string) string {
-	prompt := fmt.Sprintf(`Please provide a personalized tarot interpretation for:
+func (c *openAICall) close() {
+	c.timer.Stop()
+	if c.resp != nil {
+		c.resp.Body.Close()
+	}
+	c.cancel()
+}
 
-Card: %s (%s)
-Traditional Meaning: %s
-Keywords: %v
-Light Aspects: %v
-Shadow Aspects: %v`, 
-		card.Name, card.Number, card.TraditionalMeaning, 
-		card.Keywords, card.LightAspects, card.ShadowAspects)
+// err classifies a transport error. The caller's own cancellation or
+// deadline is returned as is.
+func (c *openAICall) err(err error) error {
+	if parentErr := c.parent.Err(); parentErr != nil {
+		return parentErr
+	}
+	if c.timedOut.Load() {
+		return &UpstreamError{Provider: c.provider, Kind: ErrUpstreamTransient, Message: fmt.Sprintf("no response within %s", c.timeout)}
+	}
+	return &UpstreamError{Provider: c.provider, Kind: ErrUpstreamTransient, Message: err.Error()}
+}
 
-	if mood != "" {
-		prompt += fmt.Sprintf("\nCurrent Mood: %s", mood)
+// newChatRequest renders the persona's prompt for req and builds the chat
+// completion request with the persona's settings.
+func (s *OpenAIService) newChatRequest(req InterpretationRequest, stream bool) (OpenAIRequest, *prompts.Prompt, error) {
+	prompt, err := renderPrompt(req)
+	if err != nil {
+		return OpenAIRequest{}, nil, err
 	}
 
-	if question != "" {
-		prompt += fmt.Sprintf("\nQuestion Asked: %s", question)
+	// Compatible servers may reject stream_options, so they get estimates
+	var streamOptions *StreamOptions
+	if stream && s.name == InterpreterOpenAI {
+		streamOptions = &StreamOptions{IncludeUsage: true}
 	}
 
-	prompt += `
+	return OpenAIRequest{
+		Model: s.modelFor(prompt),
+		Messages: []Message{
+			{
+				Role:    "system",
+				Content: prompt.System,
+			},
+			{
+				Role:    "user",
+				Content: prompt.User,
+			},
+		},
+		MaxTokens:   prompt.MaxTokens,
+		Temperature: prompt.Temperature,
+		Stream:        stream,
+		StreamOptions: streamOptions,
+	}, prompt, nil
+}
 
-Please provide:
-1. A personalized interpretation that connects the card's meaning to their mood and question
-2. Practical guidance and actionable insights
-3. How this card's energy can help them right now
-4. A supportive message that empowers them
+func (s *OpenAIService) newRequest(ctx context.Context, body []byte, stream bool) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
 
-Keep the tone warm, wise, and encouraging. Focus on personal growth and positive transformation while being honest about any challenges the card might indicate.
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if s.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	return httpReq, nil
+}
+
+// modelFor picks the persona's model on the OpenAI API. Compatible servers
+// keep their configured model, as persona models may not exist there.
+func (s *OpenAIService) modelFor(prompt *prompts.Prompt) string {
+	if s.name == InterpreterOpenAI && prompt.Model != "" {
+		return prompt.Model
+	}
+	return s.model
+}
+
+func (s *OpenAIService) interpretation(text string, prompt *prompts.Prompt, usage *Usage) *Interpretation {
+	return &Interpretation{
+		Text:     text,
+		Provider: s.name,
+		Persona:  prompt.Persona,
+		Template: prompt.Version,
+		Usage:    usage,
+	}
+}
 
-Response should be 2-3 paragraphs, around 250-300 words total.`
+// buildPrompt renders the default persona's reading prompt.
+func (s *OpenAIService) buildPrompt(card tarot.Card, orientation tarot.Orientation, mood, question string) string {
+	prompt, err := renderPrompt(InterpretationRequest{Card: card, Orientation: orientation, Mood: mood, Question: question})
+	if err != nil {
+		return ""
+	}
+	return prompt.User
+}
 
-	return prompt
-}
\ No newline at end of file
+func renderPrompt(req InterpretationRequest) (*prompts.Prompt, error) {
+	data := prompts.NewData(req.Card, req.Orientation, req.Mood, req.Question, req.History)
+	data.Patterns = req.Patterns
+	prompt, err := prompts.Render(req.Persona, data)
+	if err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
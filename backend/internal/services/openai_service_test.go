@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"symbol-quest/internal/tarot"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenAIService_BuildPrompt(t *testing.T) {
+	service := NewOpenAIService("")
+	card, _ := tarot.GetCard(16) // The Tower
+
+	t.Run("Upright", func(t *testing.T) {
+		prompt := service.buildPrompt(card, tarot.OrientationUpright, "anxious", "What about my job?")
+
+		if !strings.Contains(prompt, "Orientation: Upright") {
+			t.Error("Expected upright orientation in prompt")
+		}
+		if strings.Contains(prompt, "shadow aspects") {
+			t.Error("Upright prompt should not ask for a shadow-focused reading")
+		}
+		if !strings.Contains(prompt, "Current Mood: anxious") || !strings.Contains(prompt, "Question Asked: What about my job?") {
+			t.Error("Expected mood and question in prompt")
+		}
+	})
+
+	t.Run("Reversed", func(t *testing.T) {
+		prompt := service.buildPrompt(card, tarot.OrientationReversed, "", "")
+
+		if !strings.Contains(prompt, "Orientation: Reversed") {
+			t.Error("Expected reversed orientation in prompt")
+		}
+		if !strings.Contains(prompt, card.ReversedMeaning) {
+			t.Error("Expected reversed meaning in prompt")
+		}
+		if !strings.Contains(prompt, "shadow aspects") {
+			t.Error("Expected reversed prompt to focus on shadow aspects")
+		}
+	})
+
+	t.Run("MinorArcana", func(t *testing.T) {
+		aceOfSwords, _ := tarot.GetCard(50)
+		prompt := service.buildPrompt(aceOfSwords, tarot.OrientationUpright, "", "")
+
+		if !strings.Contains(prompt, "Arcana: Minor (swords, ace)") {
+			t.Errorf("Expected minor arcana suit and rank in prompt, got: %s", prompt)
+		}
+	})
+}
+
+func TestOpenAIService_MissingAPIKey(t *testing.T) {
+	service := NewOpenAIService("")
+
+	card, _ := tarot.GetCard(0)
+	_, err := service.GenerateEnhancedInterpretation(card, tarot.OrientationUpright, "", "")
+	if err == nil {
+		t.Error("Expected error when API key is not configured")
+	}
+}
+
+// fastRetries keeps retry tests quick.
+var fastRetries = RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+// flakyServer answers with the given statuses in turn and then with a
+// reading. It counts the requests it receives.
+func flakyServer(t *testing.T, calls *atomic.Int32, responses ...func(w http.ResponseWriter)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(responses) {
+			responses[n-1](w)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Steady now."}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func status(code int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}
+}
+
+func TestOpenAIService_Retries(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	t.Run("TransientErrors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := flakyServer(t, &calls, status(http.StatusServiceUnavailable, ""), status(http.StatusBadGateway, ""))
+		service := NewOpenAICompatibleService(server.URL, "", "llama3")
+		service.SetRetryPolicy(fastRetries)
+
+		interpretation, err := service.Interpret(context.Background(), req)
+		if err != nil || interpretation.Text != "Steady now." {
+			t.Fatalf("Expected the third attempt to succeed, got %+v, %v", interpretation, err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		var calls atomic.Int32
+		fail := status(http.StatusInternalServerError, `{"error": {"message": "overloaded"}}`)
+		server := flakyServer(t, &calls, fail, fail, fail)
+		service := NewOpenAICompatibleService(server.URL, "", "llama3")
+		service.SetRetryPolicy(fastRetries)
+
+		_, err := service.Interpret(context.Background(), req)
+		if !errors.Is(err, ErrUpstreamTransient) || !strings.Contains(err.Error(), "overloaded") {
+			t.Errorf("Expected a transient error, got %v", err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("Expected the first attempt and two retries, got %d calls", calls.Load())
+		}
+	})
+
+	t.Run("HonoursRetryAfter", func(t *testing.T) {
+		var calls atomic.Int32
+		server := flakyServer(t, &calls, func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		service := NewOpenAICompatibleService(server.URL, "", "llama3")
+		service.SetRetryPolicy(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second})
+
+		start := time.Now()
+		if _, err := service.Interpret(context.Background(), req); err != nil {
+			t.Fatalf("Interpret returned error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Errorf("Expected to wait for Retry-After, retried after %s", elapsed)
+		}
+	})
+
+	t.Run("RetryAfterTooLong", func(t *testing.T) {
+		var calls atomic.Int32
+		server := flakyServer(t, &calls, func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		service := NewOpenAICompatibleService(server.URL, "", "llama3")
+		service.SetRetryPolicy(fastRetries)
+
+		_, err := service.Interpret(context.Background(), req)
+		var upstream *UpstreamError
+		if !errors.As(err, &upstream) || upstream.Kind != ErrUpstreamRateLimited || upstream.RetryAfter != 2*time.Minute {
+			t.Errorf("Expected a rate limit error with its Retry-After, got %v", err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("Expected no retry, got %d calls", calls.Load())
+		}
+	})
+}
+
+func TestOpenAIService_ClassifiesErrors(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	tests := map[string]struct {
+		response func(w http.ResponseWriter)
+		kind     error
+	}{
+		"Unauthorized":  {status(http.StatusUnauthorized, `{"error": {"message": "Incorrect API key"}}`), ErrUpstreamAuth},
+		"Forbidden":     {status(http.StatusForbidden, ""), ErrUpstreamAuth},
+		"ContentPolicy": {status(http.StatusBadRequest, `{"error": {"message": "rejected", "code": "content_policy_violation"}}`), ErrUpstreamContentPolicy},
+		"NoQuota":       {status(http.StatusTooManyRequests, `{"error": {"message": "quota", "code": "insufficient_quota"}}`), ErrUpstreamRateLimited},
+		"Filtered": {func(w http.ResponseWriter) {
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": ""}, "finish_reason": "content_filter"}]}`))
+		}, ErrUpstreamContentPolicy},
+		"BadRequest": {status(http.StatusBadRequest, `{"error": {"message": "max_tokens is too large"}}`), nil},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := flakyServer(t, &calls, test.response)
+			service := NewOpenAICompatibleService(server.URL, "", "llama3")
+			service.SetRetryPolicy(fastRetries)
+
+			_, err := service.Interpret(context.Background(), req)
+			var upstream *UpstreamError
+			if !errors.As(err, &upstream) || upstream.Kind != test.kind {
+				t.Errorf("Expected kind %v, got %v", test.kind, err)
+			}
+			if calls.Load() != 1 {
+				t.Errorf("Expected no retries, got %d calls", calls.Load())
+			}
+		})
+	}
+}
+
+func TestOpenAIService_Timeouts(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	hang := make(chan struct{})
+	defer close(hang)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Stream {
+			// Start the stream, then stall
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"The\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	service := NewOpenAICompatibleService(server.URL, "", "llama3")
+	service.SetRetryPolicy(RetryPolicy{})
+	service.SetTimeout(50 * time.Millisecond)
+
+	t.Run("Attempt", func(t *testing.T) {
+		_, err := service.Interpret(context.Background(), req)
+		if !errors.Is(err, ErrUpstreamTransient) || !strings.Contains(err.Error(), "no response within 50ms") {
+			t.Errorf("Expected a timeout, got %v", err)
+		}
+	})
+
+	t.Run("StalledStream", func(t *testing.T) {
+		var tokens []string
+		_, err := service.InterpretStream(context.Background(), req, func(token string) error {
+			tokens = append(tokens, token)
+			return nil
+		})
+		if !errors.Is(err, ErrUpstreamTransient) || len(tokens) != 1 {
+			t.Errorf("Expected a timeout after the first token, got %v with %q", err, tokens)
+		}
+	})
+
+	t.Run("CallerCancels", func(t *testing.T) {
+		service.SetTimeout(time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := service.Interpret(ctx, req)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the caller's deadline, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the call to stop at the deadline, took %s", elapsed)
+		}
+	})
+}
+
+func TestOpenAIService_CircuitBreaker(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	var calls atomic.Int32
+	fail := status(http.StatusServiceUnavailable, "")
+	server := flakyServer(t, &calls, fail, fail)
+	service := NewOpenAICompatibleService(server.URL, "", "llama3")
+	service.SetRetryPolicy(RetryPolicy{})
+	breaker := NewCircuitBreaker(2, time.Minute)
+	service.SetCircuitBreaker(breaker)
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.Interpret(context.Background(), req); !errors.Is(err, ErrUpstreamTransient) {
+			t.Fatalf("Expected a transient error, got %v", err)
+		}
+	}
+
+	if _, err := service.Interpret(context.Background(), req); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected the breaker to open, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected no call while the breaker is open, got %d calls", calls.Load())
+	}
+
+	// After the cooldown a trial call goes through and closes the breaker
+	breaker.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := service.Interpret(context.Background(), req); err != nil {
+		t.Fatalf("Expected the trial call to succeed, got %v", err)
+	}
+	if _, err := service.Interpret(context.Background(), req); err != nil {
+		t.Errorf("Expected the breaker to be closed, got %v", err)
+	}
+}
+
+func TestOpenAIService_Usage(t *testing.T) {
+	card, _ := tarot.GetCard(0)
+	req := InterpretationRequest{Card: card, Orientation: tarot.OrientationUpright}
+
+	t.Run("Reported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Leap."}}], "usage": {"prompt_tokens": 312, "completion_tokens": 41}}`))
+		}))
+		defer server.Close()
+
+		interpretation, err := NewOpenAICompatibleService(server.URL, "", "llama3").Interpret(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Interpret returned error: %v", err)
+		}
+		if usage := interpretation.Usage; usage == nil || *usage != (Usage{Model: "llama3", PromptTokens: 312, CompletionTokens: 41}) {
+			t.Errorf("Expected the reported usage, got %+v", usage)
+		}
+	})
+
+	t.Run("Estimated", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Leap before you look."}}]}`))
+		}))
+		defer server.Close()
+
+		interpretation, err := NewOpenAICompatibleService(server.URL, "", "llama3").Interpret(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Interpret returned error: %v", err)
+		}
+		usage := interpretation.Usage
+		if usage == nil || !usage.Estimated || usage.PromptTokens == 0 || usage.CompletionTokens != EstimateTokens("Leap before you look.") {
+			t.Errorf("Expected estimated usage, got %+v", usage)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		var got OpenAIRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"Leap.\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\": [], \"usage\": {\"prompt_tokens\": 300, \"completion_tokens\": 2}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		defer server.Close()
+
+		openai := NewOpenAIService("sk-test")
+		openai.baseURL = server.URL
+		interpretation, err := openai.InterpretStream(context.Background(), req, func(string) error { return nil })
+		if err != nil {
+			t.Fatalf("InterpretStream returned error: %v", err)
+		}
+		if got.StreamOptions == nil || !got.StreamOptions.IncludeUsage {
+			t.Error("Expected the stream to ask for usage")
+		}
+		if usage := interpretation.Usage; usage == nil || usage.Estimated || usage.PromptTokens != 300 || usage.CompletionTokens != 2 {
+			t.Errorf("Expected the reported usage, got %+v", usage)
+		}
+	})
+}
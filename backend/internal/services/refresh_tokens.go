@@ -0,0 +1,155 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"symbol-quest/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Refresh tokens are opaque random strings. Only their SHA-256 hash is
+// stored. Every token belongs to a family, one per login: refreshing
+// marks the presented token as used and issues the next token in the
+// family. A used token coming back means it was copied, so the whole
+// family is revoked and both holders have to log in again.
+
+// StartSession issues an access token and the first refresh token of a
+// new family for user.
+func (s *AuthService) StartSession(user *models.User) (*models.TokenResponse, error) {
+	// Expired tokens are no longer useful for spotting reuse
+	if _, err := s.db.Exec("DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < NOW()", user.ID); err != nil {
+		log.Printf("delete expired refresh tokens: %v", err)
+	}
+
+	refreshToken, err := s.insertRefreshToken(s.db, user.ID, uuid.New())
+	if err != nil {
+		return nil, fmt.Errorf("create refresh token: %w", err)
+	}
+	return s.tokenResponse(user.ID, user.Email, user.SubscriptionTier, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and the next
+// refresh token in its family. The access token carries the user's
+// current subscription tier. It returns ErrInvalidToken for unknown,
+// expired or revoked tokens and ErrRefreshTokenReused when a token that
+// was already exchanged is presented again, which revokes its family.
+func (s *AuthService) Refresh(refreshToken string) (*models.TokenResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID, familyID uuid.UUID
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	var email, tier string
+	err = tx.QueryRow(`
+		SELECT t.id, t.user_id, t.family_id, t.expires_at, t.used_at, t.revoked_at,
+		       u.email, COALESCE(u.subscription_tier, 'free')
+		FROM refresh_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t
+	`, hashToken(refreshToken)).Scan(&tokenID, &userID, &familyID, &expiresAt, &usedAt, &revokedAt, &email, &tier)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case revokedAt.Valid:
+		return nil, ErrInvalidToken
+	case usedAt.Valid:
+		if err := revokeFamily(tx, familyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		log.Printf("refresh token reused for user %s: session %s revoked", userID, familyID)
+		return nil, ErrRefreshTokenReused
+	case time.Now().After(expiresAt):
+		return nil, ErrInvalidToken
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1", tokenID); err != nil {
+		return nil, err
+	}
+	next, err := s.insertRefreshToken(tx, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.tokenResponse(userID, email, tier, next)
+}
+
+// Logout revokes the session the refresh token belongs to. Unknown tokens
+// are ignored, so logging out twice is not an error.
+func (s *AuthService) Logout(refreshToken string) error {
+	var familyID uuid.UUID
+	err := s.db.QueryRow("SELECT family_id FROM refresh_tokens WHERE token_hash = $1", hashToken(refreshToken)).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return revokeFamily(s.db, familyID)
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func revokeFamily(db execer, familyID uuid.UUID) error {
+	_, err := db.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", familyID)
+	return err
+}
+
+func (s *AuthService) insertRefreshToken(db execer, userID, familyID uuid.UUID) (string, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, userID, familyID, hashToken(token), time.Now().Add(s.refreshTTL))
+	return token, err
+}
+
+func (s *AuthService) tokenResponse(userID uuid.UUID, email, tier, refreshToken string) (*models.TokenResponse, error) {
+	expiresAt := time.Now().Add(s.accessTTL)
+	token, err := s.GenerateToken(userID, email, tier)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+	return &models.TokenResponse{Token: token, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// newRandomToken returns 256 random bits, base64url encoded.
+func newRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
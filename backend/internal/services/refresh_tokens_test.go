@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"symbol-quest/internal/models"
+	"testing"
+	"time"
+)
+
+func TestHashToken(t *testing.T) {
+	token, err := newRandomToken()
+	if err != nil {
+		t.Fatalf("newRandomToken returned error: %v", err)
+	}
+	if len(token) != 43 {
+		t.Errorf("Expected 256 bits base64url encoded, got %q", token)
+	}
+	if other, _ := newRandomToken(); other == token {
+		t.Error("Expected refresh tokens to differ")
+	}
+	if hash := hashToken(token); len(hash) != 64 || hash == token || hash != hashToken(token) {
+		t.Errorf("Expected a stable SHA-256 hex digest, got %q", hash)
+	}
+}
+
+func TestAuthService_Sessions(t *testing.T) {
+	db, userID := testDatabase(t)
+	service := NewAuthService(db, "test-secret-key")
+	user := &models.User{ID: userID, Email: userID.String() + "@example.com", SubscriptionTier: "free"}
+
+	t.Run("Rotation", func(t *testing.T) {
+		first, err := service.StartSession(user)
+		if err != nil {
+			t.Fatalf("StartSession returned error: %v", err)
+		}
+		if _, err := service.ValidateToken(first.Token); err != nil {
+			t.Errorf("Expected a valid access token, got %v", err)
+		}
+		if time.Until(first.ExpiresAt) > DefaultAccessTokenTTL {
+			t.Errorf("Expected the access token to expire within %v, got %v", DefaultAccessTokenTTL, first.ExpiresAt)
+		}
+
+		db.Exec("UPDATE users SET subscription_tier = 'premium' WHERE id = $1", userID)
+		second, err := service.Refresh(first.RefreshToken)
+		if err != nil {
+			t.Fatalf("Refresh returned error: %v", err)
+		}
+		if second.RefreshToken == first.RefreshToken {
+			t.Error("Expected a new refresh token")
+		}
+		if claims, _ := service.ValidateToken(second.Token); claims["subscription_tier"] != "premium" {
+			t.Errorf("Expected the new access token to carry the current tier, got %v", claims["subscription_tier"])
+		}
+
+		// Presenting the rotated token again revokes the whole session
+		if _, err := service.Refresh(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+		}
+		if _, err := service.Refresh(second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected the newest token to be revoked too, got %v", err)
+		}
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		tokens, _ := service.StartSession(user)
+		other, _ := service.StartSession(user)
+
+		if err := service.Logout(tokens.RefreshToken); err != nil {
+			t.Fatalf("Logout returned error: %v", err)
+		}
+		if _, err := service.Refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected the session to be revoked, got %v", err)
+		}
+		if _, err := service.Refresh(other.RefreshToken); err != nil {
+			t.Errorf("Expected other sessions to stay valid, got %v", err)
+		}
+		if err := service.Logout("unknown"); err != nil {
+			t.Errorf("Expected an unknown token to be ignored, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		tokens, _ := service.StartSession(user)
+		db.Exec("UPDATE refresh_tokens SET expires_at = NOW() - INTERVAL '1 minute' WHERE token_hash = $1", hashToken(tokens.RefreshToken))
+		if _, err := service.Refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected an expired token to be rejected, got %v", err)
+		}
+	})
+}
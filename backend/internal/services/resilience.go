@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of upstream model API failure. An *UpstreamError matches one of
+// them with errors.Is.
+var (
+	// ErrUpstreamRateLimited means the API asked us to slow down (429).
+	ErrUpstreamRateLimited = errors.New("rate limited")
+	// ErrUpstreamAuth means the API key was missing, wrong or not allowed
+	// to use the model (401, 403).
+	ErrUpstreamAuth = errors.New("authentication failed")
+	// ErrUpstreamContentPolicy means the model refused the prompt or its
+	// answer was filtered.
+	ErrUpstreamContentPolicy = errors.New("content policy violation")
+	// ErrUpstreamTransient covers timeouts, network errors and 5xx
+	// responses that may succeed when tried again.
+	ErrUpstreamTransient = errors.New("temporarily unavailable")
+)
+
+// ErrCircuitOpen is returned without calling the API while the circuit
+// breaker is open after repeated failures.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// UpstreamError is a failed call to a model API.
+type UpstreamError struct {
+	// Provider is the interpreter name, e.g. "openai".
+	Provider string
+	// Kind is one of the ErrUpstream errors, or nil for a failure that
+	// fits none of them, such as a malformed request.
+	Kind error
+	// Status is the HTTP status, or 0 when no response arrived.
+	Status  int
+	Code    string
+	Message string
+	// RetryAfter is how long the API asked us to wait, if it said.
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamError) Error() string {
+	msg := e.Provider + " API error"
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Kind
+}
+
+// retryable reports whether the same request may succeed later. Running
+// out of quota is reported as a 429 but waiting does not help.
+func (e *UpstreamError) retryable() bool {
+	switch e.Kind {
+	case ErrUpstreamTransient:
+		return true
+	case ErrUpstreamRateLimited:
+		return e.Code != "insufficient_quota"
+	}
+	return false
+}
+
+// contentPolicyCodes are the error codes model APIs use for refused
+// prompts.
+var contentPolicyCodes = map[string]bool{
+	"content_policy_violation": true,
+	"content_filter":           true,
+}
+
+// classifyStatus builds the error for a non-2xx response.
+func classifyStatus(provider string, resp *http.Response, code, message string) *UpstreamError {
+	err := &UpstreamError{Provider: provider, Status: resp.StatusCode, Code: code, Message: message}
+	switch status := resp.StatusCode; {
+	case status == http.StatusTooManyRequests:
+		err.Kind = ErrUpstreamRateLimited
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		err.Kind = ErrUpstreamAuth
+	case contentPolicyCodes[code]:
+		err.Kind = ErrUpstreamContentPolicy
+	case status == http.StatusRequestTimeout || status >= 500:
+		err.Kind = ErrUpstreamTransient
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now))
+	}
+	return 0
+}
+
+// RetryPolicy decides how often and how long to wait before retrying a
+// rate limited or transient failure.
+type RetryPolicy struct {
+	// MaxRetries is how many times a request is repeated after the first
+	// attempt. Zero turns retries off.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry. It doubles with each
+	// retry, up to MaxDelay, and is jittered so that clients spread out.
+	BaseDelay time.Duration
+	// MaxDelay also caps a Retry-After: if the API asks us to wait longer
+	// the error is returned straight away.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by the model API interpreters.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 2, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second}
+
+// delay returns how long to wait before retry number retry (counting from
+// zero) after err, or false if err should not be retried.
+func (p RetryPolicy) delay(retry int, err error) (time.Duration, bool) {
+	var upstream *UpstreamError
+	if retry >= p.MaxRetries || !errors.As(err, &upstream) || !upstream.retryable() {
+		return 0, false
+	}
+
+	if upstream.RetryAfter > 0 {
+		return upstream.RetryAfter, upstream.RetryAfter <= p.MaxDelay
+	}
+
+	// Full jitter between half and all of the exponential delay
+	backoff := min(p.MaxDelay, p.BaseDelay<<retry)
+	if backoff <= 0 {
+		return 0, true
+	}
+	half := backoff / 2
+	return half + rand.N(backoff-half+1), true
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Default circuit breaker settings for the model API interpreters.
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// CircuitBreaker stops calls to an API that keeps failing. After threshold
+// consecutive rate limited or transient failures it opens and rejects
+// calls for the cooldown. Then one trial call is let through: success
+// closes the breaker, failure opens it again.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+// NewCircuitBreaker returns a closed breaker. A threshold of zero or less
+// never opens it.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns ErrCircuitOpen if a call must not be made now. Every
+// allowed call must be followed by record.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return nil
+	}
+	if b.now().Before(b.openUntil) || b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// record notes the outcome of an allowed call. Errors other than
+// *UpstreamError, such as the caller giving up, say nothing about the API
+// and are not counted.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+
+	var upstream *UpstreamError
+	switch {
+	case err == nil:
+		b.failures = 0
+	case errors.As(err, &upstream) && upstream.retryable():
+		b.failures++
+		if b.threshold > 0 && b.failures >= b.threshold {
+			b.openUntil = b.now().Add(b.cooldown)
+		}
+	case errors.As(err, &upstream):
+		// The API answered, it just did not like the request
+		b.failures = 0
+	}
+}
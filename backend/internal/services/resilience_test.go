@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"-3":                            0,
+		"Fri, 01 Mar 2024 12:00:30 GMT": 30 * time.Second,
+		"Fri, 01 Mar 2024 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	transient := &UpstreamError{Kind: ErrUpstreamTransient}
+
+	for retry, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			delay, ok := policy.delay(retry, transient)
+			if !ok || delay < want/2 || delay > want {
+				t.Fatalf("Retry %d: expected a delay between %s and %s, got %s, %v", retry, want/2, want, delay, ok)
+			}
+		}
+	}
+
+	if _, ok := policy.delay(3, transient); ok {
+		t.Error("Expected no retry past MaxRetries")
+	}
+	if _, ok := policy.delay(0, &UpstreamError{Kind: ErrUpstreamAuth}); ok {
+		t.Error("Expected auth errors not to be retried")
+	}
+	if _, ok := policy.delay(0, context.Canceled); ok {
+		t.Error("Expected cancellation not to be retried")
+	}
+	if delay, ok := policy.delay(0, &UpstreamError{Kind: ErrUpstreamRateLimited, RetryAfter: 700 * time.Millisecond}); !ok || delay != 700*time.Millisecond {
+		t.Errorf("Expected to wait for Retry-After, got %s, %v", delay, ok)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+	transient := &UpstreamError{Kind: ErrUpstreamTransient}
+
+	// Cancelled calls and rejected requests do not count towards opening it
+	for _, err := range []error{transient, context.Canceled, &UpstreamError{Kind: ErrUpstreamAuth}, transient} {
+		if breaker.allow() != nil {
+			t.Fatal("Expected the breaker to be closed")
+		}
+		breaker.record(err)
+	}
+	if breaker.allow() != nil {
+		t.Fatal("Expected an auth error to reset the failure count")
+	}
+	breaker.record(transient)
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the breaker to open after 2 failures, got %v", err)
+	}
+
+	// One trial call after the cooldown; failing it opens the breaker again
+	now = now.Add(time.Minute)
+	if breaker.allow() != nil {
+		t.Fatal("Expected a trial call after the cooldown")
+	}
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected only one trial call at a time, got %v", err)
+	}
+	breaker.record(transient)
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected a failed trial to reopen the breaker, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	breaker.allow()
+	breaker.record(nil)
+	if breaker.allow() != nil {
+		t.Error("Expected a successful trial to close the breaker")
+	}
+}
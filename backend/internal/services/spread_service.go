@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"symbol-quest/internal/models"
+	"symbol-quest/internal/tarot"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SpreadService struct {
+	db          *sql.DB
+	cardService *CardService
+}
+
+func NewSpreadService(db *sql.DB, cardService *CardService) *SpreadService {
+	return &SpreadService{
+		db:          db,
+		cardService: cardService,
+	}
+}
+
+// ListSpreads returns the built-in layouts followed by the user's own.
+func (s *SpreadService) ListSpreads(userID uuid.UUID) ([]tarot.Spread, error) {
+	spreads := tarot.BuiltInSpreads()
+
+	rows, err := s.db.Query(`
+		SELECT spread_type, name, COALESCE(description, ''), positions
+		FROM custom_spreads
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		spread, err := scanCustomSpread(rows)
+		if err != nil {
+			return nil, err
+		}
+		spreads = append(spreads, *spread)
+	}
+
+	return spreads, rows.Err()
+}
+
+// GetSpread resolves a built-in layout or one of the user's custom layouts.
+func (s *SpreadService) GetSpread(userID uuid.UUID, spreadType string) (*tarot.Spread, error) {
+	if spread, exists := tarot.GetSpread(spreadType); exists {
+		return &spread, nil
+	}
+
+	spread, err := scanCustomSpread(s.db.QueryRow(`
+		SELECT spread_type, name, COALESCE(description, ''), positions
+		FROM custom_spreads
+		WHERE user_id = $1 AND spread_type = $2
+	`, userID, spreadType))
+	if err == sql.ErrNoRows {
+		return nil, ErrSpreadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return spread, nil
+}
+
+// SaveCustomSpread creates or replaces one of the user's custom layouts.
+func (s *SpreadService) SaveCustomSpread(userID uuid.UUID, req models.CreateSpreadRequest) (*tarot.Spread, error) {
+	spreadType := req.Type
+	if spreadType == "" {
+		spreadType = req.Name
+	}
+
+	spread := tarot.Spread{
+		Type:        slugify(spreadType),
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Custom:      true,
+	}
+	for i, position := range req.Positions {
+		spread.Positions = append(spread.Positions, tarot.SpreadPosition{
+			Index:   i,
+			Name:    strings.TrimSpace(position.Name),
+			Meaning: strings.TrimSpace(position.Meaning),
+		})
+	}
+
+	if err := spread.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpread, err)
+	}
+
+	if _, exists := tarot.GetSpread(spread.Type); exists {
+		return nil, fmt.Errorf("%w: %q is a built-in spread", ErrInvalidSpread, spread.Type)
+	}
+
+	positions, err := json.Marshal(spread.Positions)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO custom_spreads (user_id, spread_type, name, description, positions, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (user_id, spread_type)
+		DO UPDATE SET name = $3, description = $4, positions = $5, updated_at = NOW()
+	`, userID, spread.Type, spread.Name, spread.Description, positions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spread, nil
+}
+
+func (s *SpreadService) DeleteCustomSpread(userID uuid.UUID, spreadType string) error {
+	result, err := s.db.Exec(`
+		DELETE FROM custom_spreads WHERE user_id = $1 AND spread_type = $2
+	`, userID, spreadType)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSpreadNotFound
+	}
+
+	return nil
+}
+
+// DrawSpread deals a full spread for the user and stores every card with
+// its position. Spread draws count towards the free tier's daily limit.
+func (s *SpreadService) DrawSpread(ctx context.Context, userID uuid.UUID, spreadType, mood, question, deckName, strategy, timezone string, scope tarot.DeckScope) (*models.SpreadReading, error) {
+	if err := s.cardService.moderation.ScreenQuestion(ctx, userID, ModerationSourceSpread, question); err != nil {
+		return nil, err
+	}
+
+	spread, err := s.GetSpread(userID, spreadType)
+	if err != nil {
+		return nil, err
+	}
+
+	deck, err := resolveDeck(deckName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold the user's row for the whole reading so that parallel draws
+	// cannot both pass the free tier's limit.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := lockDrawUser(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := s.cardService.selectorFor(strategy, user.selectionStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := resolveLocation(user.timezone, timezone)
+	if err != nil {
+		return nil, err
+	}
+	today := localDate(time.Now(), location)
+
+	if !user.isPremium() {
+		if err := checkDailyUsage(tx, userID, today); err != nil {
+			return nil, err
+		}
+	}
+
+	reversalProbability := 0.0
+	if user.reversalsEnabled {
+		reversalProbability = s.cardService.reversalProbability
+	}
+
+	input := tarot.DrawInput{
+		Seed:                tarot.NewSeed(),
+		Deck:                deck.Name,
+		Scope:               scope,
+		Selector:            selector.Name(),
+		Mood:                mood,
+		Question:            question,
+		Recent:              tarot.RecentCards(userID, s.db, tarot.HistoryWindow),
+		ReversalProbability: reversalProbability,
+	}
+	dealt, err := tarot.DrawSpread(*spread, input)
+	if err != nil {
+		return nil, err
+	}
+
+	recentJSON, err := json.Marshal(input.Recent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the layout with the reading so it can be re-dealt even after a
+	// custom spread is edited or deleted.
+	positionsJSON, err := json.Marshal(spread.Positions)
+	if err != nil {
+		return nil, err
+	}
+
+	reading := &models.SpreadReading{
+		ID:         uuid.New(),
+		UserID:     userID,
+		SpreadType: spread.Type,
+		SpreadName: spread.Name,
+		Deck:       deck.Name,
+		Selector:   selector.Name(),
+		Seed:       input.Seed,
+		Commitment: input.Commitment(),
+		DrawDate:   today,
+		Mood:       mood,
+		Question:   question,
+		CreatedAt:  time.Now(),
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO spread_readings (id, user_id, spread_type, spread_name, deck, selector, draw_date, mood, question,
+		                             seed, scope, recent_cards, reversal_probability, positions, commitment, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+	`, reading.ID, userID, spread.Type, spread.Name, deck.Name, selector.Name(), today, mood, question,
+		input.Seed, string(scope), recentJSON, reversalProbability, positionsJSON, reading.Commitment)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dealtCard := range dealt {
+		card, _ := deck.Card(dealtCard.CardID)
+		spreadCard := models.SpreadCard{
+			Position:            dealtCard.Position.Index,
+			PositionName:        dealtCard.Position.Name,
+			PositionMeaning:     dealtCard.Position.Meaning,
+			CardID:              card.ID,
+			CardName:            card.Name,
+			Orientation:         string(dealtCard.Orientation),
+			InterpretationBasic: card.Meaning(dealtCard.Orientation),
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO spread_cards (reading_id, position, position_name, card_id, card_name, orientation, interpretation_basic)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, reading.ID, spreadCard.Position, spreadCard.PositionName, spreadCard.CardID,
+			spreadCard.CardName, spreadCard.Orientation, spreadCard.InterpretationBasic)
+		if err != nil {
+			return nil, err
+		}
+
+		reading.Cards = append(reading.Cards, spreadCard)
+	}
+
+	if err := incrementDailyUsage(tx, userID, today); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return reading, nil
+}
+
+// VerifyReading re-deals one of the user's past spread readings from its
+// stored seed, inputs and layout, and compares each position with the
+// cards that were stored.
+func (s *SpreadService) VerifyReading(userID, readingID uuid.UUID) (*models.DrawVerification, error) {
+	var (
+		input         tarot.DrawInput
+		seed          sql.NullInt64
+		commitment    sql.NullString
+		scope         string
+		recentJSON    []byte
+		positionsJSON []byte
+	)
+
+	err := s.db.QueryRow(`
+		SELECT seed, commitment, deck, scope, selector, COALESCE(mood, ''), COALESCE(question, ''),
+		       COALESCE(recent_cards, '[]'), reversal_probability, COALESCE(positions, '[]')
+		FROM spread_readings
+		WHERE id = $1 AND user_id = $2
+	`, readingID, userID).Scan(
+		&seed, &commitment, &input.Deck, &scope, &input.Selector, &input.Mood, &input.Question,
+		&recentJSON, &input.ReversalProbability, &positionsJSON,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrReadingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !seed.Valid || !commitment.Valid {
+		return nil, ErrDrawNotVerifiable
+	}
+
+	input.Seed = seed.Int64
+	input.Scope = tarot.DeckScope(scope)
+	if err := json.Unmarshal(recentJSON, &input.Recent); err != nil {
+		return nil, err
+	}
+
+	var spread tarot.Spread
+	if err := json.Unmarshal(positionsJSON, &spread.Positions); err != nil {
+		return nil, err
+	}
+
+	dealt, err := tarot.DrawSpread(spread, input)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT position, card_id, orientation FROM spread_cards
+		WHERE reading_id = $1
+	`, readingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[int]models.VerifiedCard)
+	for rows.Next() {
+		var card models.VerifiedCard
+		if err := rows.Scan(&card.Position, &card.CardID, &card.Orientation); err != nil {
+			return nil, err
+		}
+		stored[card.Position] = card
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cards := make([]models.VerifiedCard, 0, len(dealt))
+	for _, dealtCard := range dealt {
+		card, exists := stored[dealtCard.Position.Index]
+		if !exists {
+			card = models.VerifiedCard{Position: dealtCard.Position.Index, CardID: -1}
+		}
+		card.DerivedCardID = dealtCard.CardID
+		card.DerivedOrientation = string(dealtCard.Orientation)
+		cards = append(cards, card)
+	}
+
+	return newDrawVerification(readingID, input, commitment.String, cards), nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCustomSpread(row rowScanner) (*tarot.Spread, error) {
+	var spread tarot.Spread
+	var positions []byte
+
+	if err := row.Scan(&spread.Type, &spread.Name, &spread.Description, &positions); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(positions, &spread.Positions); err != nil {
+		return nil, err
+	}
+
+	spread.Custom = true
+	return &spread, nil
+}
+
+// slugify turns a spread name into a URL-safe type such as "weekly-focus".
+func slugify(value string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range strings.ToLower(strings.TrimSpace(value)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			lastDash = false
+		case !lastDash:
+			b.WriteRune('-')
+			lastDash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimSuffix(slug[:50], "-")
+	}
+	return slug
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"symbol-quest/internal/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Weekly Focus":         "weekly-focus",
+		"  Love & Career!  ":   "love-career",
+		"new---moon":           "new-moon",
+		"Situation/Obstacle 2": "situation-obstacle-2",
+	}
+	for input, expected := range cases {
+		if got := slugify(input); got != expected {
+			t.Errorf("slugify(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestSpreadService_SaveCustomSpread_Validation(t *testing.T) {
+	service := NewSpreadService(nil, NewCardService(nil))
+	userID := uuid.New()
+
+	t.Run("NoPositions", func(t *testing.T) {
+		_, err := service.SaveCustomSpread(userID, models.CreateSpreadRequest{Name: "Empty"})
+		if !errors.Is(err, ErrInvalidSpread) {
+			t.Errorf("Expected ErrInvalidSpread, got %v", err)
+		}
+	})
+
+	t.Run("BuiltInType", func(t *testing.T) {
+		_, err := service.SaveCustomSpread(userID, models.CreateSpreadRequest{
+			Type:      "celtic-cross",
+			Name:      "My Cross",
+			Positions: []models.SpreadPositionRequest{{Name: "Centre"}},
+		})
+		if !errors.Is(err, ErrInvalidSpread) {
+			t.Errorf("Expected ErrInvalidSpread for built-in type, got %v", err)
+		}
+	})
+}
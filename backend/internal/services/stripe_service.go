@@ -6,6 +6,7 @@ import (
 	"errors"
 	"log"
 	"symbol-quest/internal/models"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v76"
@@ -17,6 +18,7 @@ import (
 type StripeService struct {
 	db               *sql.DB
 	webhookSecret    string
+	entitlements     *EntitlementService
 }
 
 func NewStripeService(secretKey string) *StripeService {
@@ -34,6 +36,11 @@ func (s *StripeService) SetWebhookSecret(secret string) {
 	s.webhookSecret = secret
 }
 
+// SetEntitlements lets the webhooks drop cached tiers when they change.
+func (s *StripeService) SetEntitlements(entitlements *EntitlementService) {
+	s.entitlements = entitlements
+}
+
 func (s *StripeService) CreateSubscription(userID uuid.UUID, userEmail string) (string, error) {
 	// Create or get Stripe customer
 	customerParams := &stripe.CustomerParams{
@@ -100,7 +107,7 @@ func (s *StripeService) GetSubscriptionStatus(userID uuid.UUID) (*models.Subscri
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("no active subscription found")
+		return nil, ErrNoSubscription
 	}
 
 	if err != nil {
@@ -180,19 +187,34 @@ func (s *StripeService) saveSubscription(userID uuid.UUID, subscription *stripe.
 			updated_at = NOW()
 	`, uuid.New(), userID, subscription.ID, subscription.Customer.ID,
 		string(subscription.Status),
-		subscription.CurrentPeriodStart,
-		subscription.CurrentPeriodEnd)
+		periodTime(subscription.CurrentPeriodStart),
+		periodTime(subscription.CurrentPeriodEnd))
 
 	return err
 }
 
+// periodTime converts a Stripe timestamp for a TIMESTAMP column.
+func periodTime(unix int64) *time.Time {
+	if unix == 0 {
+		return nil
+	}
+	t := time.Unix(unix, 0).UTC()
+	return &t
+}
+
 func (s *StripeService) handleSubscriptionCreated(subscription *stripe.Subscription) error {
 	userID, err := s.getUserIDFromMetadata(subscription.Metadata)
 	if err != nil {
 		return err
 	}
 
-	return s.saveSubscription(userID, subscription)
+	if err := s.saveSubscription(userID, subscription); err != nil {
+		return err
+	}
+
+	// A subscription can start out active, e.g. with a trial, or
+	// incomplete, which must not end another one the user already has
+	return s.refreshTier(userID)
 }
 
 func (s *StripeService) handleSubscriptionUpdated(subscription *stripe.Subscription) error {
@@ -205,8 +227,8 @@ func (s *StripeService) handleSubscriptionUpdated(subscription *stripe.Subscript
 			updated_at = NOW()
 		WHERE stripe_subscription_id = $4
 	`, string(subscription.Status),
-		subscription.CurrentPeriodStart,
-		subscription.CurrentPeriodEnd,
+		periodTime(subscription.CurrentPeriodStart),
+		periodTime(subscription.CurrentPeriodEnd),
 		subscription.ID)
 
 	if err != nil {
@@ -221,15 +243,7 @@ func (s *StripeService) handleSubscriptionUpdated(subscription *stripe.Subscript
 		return err
 	}
 
-	tier := "free"
-	if subscription.Status == "active" || subscription.Status == "trialing" {
-		tier = "premium"
-	}
-
-	_, err = s.db.Exec("UPDATE users SET subscription_tier = $1, updated_at = NOW() WHERE id = $2", 
-		tier, userID)
-
-	return err
+	return s.refreshTier(userID)
 }
 
 func (s *StripeService) handleSubscriptionDeleted(subscription *stripe.Subscription) error {
@@ -243,7 +257,7 @@ func (s *StripeService) handleSubscriptionDeleted(subscription *stripe.Subscript
 		return err
 	}
 
-	// Update user to free tier
+	// The user stays premium if another subscription is still active
 	var userID uuid.UUID
 	err = s.db.QueryRow("SELECT user_id FROM subscriptions WHERE stripe_subscription_id = $1", 
 		subscription.ID).Scan(&userID)
@@ -251,10 +265,39 @@ func (s *StripeService) handleSubscriptionDeleted(subscription *stripe.Subscript
 		return err
 	}
 
-	_, err = s.db.Exec("UPDATE users SET subscription_tier = 'free', updated_at = NOW() WHERE id = $1", 
-		userID)
+	return s.refreshTier(userID)
+}
 
-	return err
+// setTier stores the user's tier and drops the cached one, so that
+// premium routes open or close on the user's next request.
+func (s *StripeService) setTier(userID uuid.UUID, tier string) error {
+	_, err := s.db.Exec("UPDATE users SET subscription_tier = $1, updated_at = NOW() WHERE id = $2", 
+		tier, userID)
+	if err != nil {
+		return err
+	}
+
+	s.entitlements.Invalidate(userID)
+	return nil
+}
+
+// refreshTier sets the user's tier from all of their subscriptions: they
+// are premium while any of them is active or trialing.
+func (s *StripeService) refreshTier(userID uuid.UUID) error {
+	var premium bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM subscriptions WHERE user_id = $1 AND status IN ($2, $3)
+		)
+	`, userID, string(stripe.SubscriptionStatusActive), string(stripe.SubscriptionStatusTrialing)).Scan(&premium)
+	if err != nil {
+		return err
+	}
+
+	if premium {
+		return s.setTier(userID, "premium")
+	}
+	return s.setTier(userID, "free")
 }
 
 func (s *StripeService) handlePaymentSucceeded(invoice *stripe.Invoice) error {
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"symbol-quest/internal/tarot"
+)
+
+// moodGuidance closes a template reading with advice for the user's mood.
+var moodGuidance = map[string]string{
+	"anxious":       "Anxiety narrows what we can see. Take one slow breath before acting, and choose the smallest step that honours this card.",
+	"excited":       "Your excitement is fuel. Direct it at one clear intention today rather than spreading it across everything at once.",
+	"uncertain":     "Uncertainty is not a verdict, only a pause. Let this card be a question you carry through the day rather than an answer you must force.",
+	"hopeful":       "Hold on to that hope and give it a shape: one concrete action today keeps it grounded.",
+	"peaceful":      "From a peaceful place you can take this message in fully. Notice where it already lives in your life.",
+	"frustrated":    "Frustration often marks the place where something wants to change. Ask what this card is asking you to release.",
+	"curious":       "Follow your curiosity. Look for this card's themes in the conversations and coincidences of your day.",
+	"contemplative": "Give this card some quiet time. Journal on the aspect that stirs you most and see what surfaces.",
+}
+
+// TemplateInterpreter writes a reading from the card's own keywords and
+// light and shadow aspects, without any network calls. It is the last
+// resort when no model is reachable and a predictable stand-in for tests.
+type TemplateInterpreter struct{}
+
+func NewTemplateInterpreter() *TemplateInterpreter {
+	return &TemplateInterpreter{}
+}
+
+func (t *TemplateInterpreter) Name() string {
+	return InterpreterTemplate
+}
+
+func (t *TemplateInterpreter) Interpret(ctx context.Context, req InterpretationRequest) (*Interpretation, error) {
+	card := req.Card
+	reversed := req.Orientation == tarot.OrientationReversed
+
+	var b strings.Builder
+
+	// Opening: the card and what was asked
+	orientation := "upright"
+	if reversed {
+		orientation = "reversed"
+	}
+	fmt.Fprintf(&b, "%s appears %s, bringing %s.", card.Name, orientation, humanList(card.Keywords))
+	if req.Question != "" {
+		fmt.Fprintf(&b, " You asked: \"%s\". Read what follows with that question in mind.", strings.TrimSpace(req.Question))
+	}
+	if req.Mood != "" {
+		fmt.Fprintf(&b, " You came to the cards feeling %s.", strings.ToLower(req.Mood))
+	}
+	fmt.Fprintf(&b, " At its heart this card means: %s.", strings.TrimSuffix(card.Meaning(req.Orientation), "."))
+	if req.Patterns != nil && req.Patterns.CurrentCard > 0 {
+		fmt.Fprintf(&b, " This is not the first time: %s has come to you %s in your last %d readings, so its message may be asking to be heard.",
+			card.Name, timesPhrase(req.Patterns.CurrentCard), req.Patterns.Readings)
+	}
+
+	// Body: reversed readings start from the shadow and work towards the light
+	b.WriteString("\n\n")
+	if reversed {
+		fmt.Fprintf(&b, "Reversed, its energy may be blocked or turned inward. Watch for %s. ", humanList(card.ShadowAspects))
+		fmt.Fprintf(&b, "Naming these shadows is the first step back towards %s.", humanList(card.LightAspects))
+	} else {
+		fmt.Fprintf(&b, "In its light this card offers %s. ", humanList(card.LightAspects))
+		fmt.Fprintf(&b, "Stay honest about its shadow side too: %s.", humanList(card.ShadowAspects))
+	}
+
+	// Close: guidance for the mood
+	b.WriteString("\n\n")
+	if guidance, exists := moodGuidance[strings.ToLower(req.Mood)]; exists {
+		b.WriteString(guidance)
+	} else {
+		fmt.Fprintf(&b, "Carry %s with you today and notice where its message shows up.", card.Name)
+	}
+
+	return &Interpretation{Text: b.String(), Provider: InterpreterTemplate}, nil
+}
+
+// InterpretStream sends the template reading a word at a time so that
+// clients see the same stream shape as from a model.
+func (t *TemplateInterpreter) InterpretStream(ctx context.Context, req InterpretationRequest, onToken func(string) error) (*Interpretation, error) {
+	interpretation, err := t.Interpret(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, word := range strings.SplitAfter(interpretation.Text, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onToken(word); err != nil {
+			return nil, err
+		}
+	}
+	return interpretation, nil
+}
+
+// FollowUp relates the card's meaning to the question without a model. It
+// cannot reason about the earlier turns, so it answers each question on
+// its own.
+func (t *TemplateInterpreter) FollowUp(ctx context.Context, req FollowUpRequest) (*Interpretation, error) {
+	card := req.Card
+	reversed := req.Orientation == tarot.OrientationReversed
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "You asked: \"%s\". ", strings.TrimSpace(req.Message))
+	if reversed {
+		fmt.Fprintf(&b, "Reversed, %s asks you to look first at %s in this part of your life. ", card.Name, humanList(card.ShadowAspects))
+		fmt.Fprintf(&b, "Working through them frees the card's gifts of %s.", humanList(card.LightAspects))
+	} else {
+		fmt.Fprintf(&b, "Here %s brings %s. ", card.Name, humanList(card.Keywords))
+		fmt.Fprintf(&b, "Lean on %s, and stay alert to %s.", humanList(card.LightAspects), humanList(card.ShadowAspects))
+	}
+	fmt.Fprintf(&b, "\n\nAt its heart the card still means: %s.", strings.TrimSuffix(card.Meaning(req.Orientation), "."))
+
+	return &Interpretation{Text: b.String(), Provider: InterpreterTemplate}, nil
+}
+
+// timesPhrase turns 1 into "once", 2 into "twice" and 3 into "3 times".
+func timesPhrase(n int) string {
+	switch n {
+	case 1:
+		return "once"
+	case 2:
+		return "twice"
+	default:
+		return fmt.Sprintf("%d times", n)
+	}
+}
+
+// humanList turns ["sudden-change", "chaos", "awakening"] into
+// "sudden change, chaos and awakening".
+func humanList(terms []string) string {
+	words := make([]string, 0, len(terms))
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		word := strings.ReplaceAll(strings.TrimSpace(term), "-", " ")
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+
+	switch len(words) {
+	case 0:
+		return "its own quiet message"
+	case 1:
+		return words[0]
+	default:
+		return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+	}
+}
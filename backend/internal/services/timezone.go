@@ -0,0 +1,60 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+	_ "time/tzdata" // bundle the zone database so slim containers can resolve user timezones
+
+	"github.com/google/uuid"
+)
+
+// DateLayout is the format of draw_date and usage_date values.
+const DateLayout = "2006-01-02"
+
+// ParseTimezone validates an IANA timezone name such as "Australia/Sydney".
+// The server's own "Local" zone is not accepted.
+func ParseTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return location, nil
+}
+
+// userLocation resolves the timezone that bounds a user's day: their stored
+// preference, then the fallback sent with the request, then UTC.
+func userLocation(db *sql.DB, userID uuid.UUID, fallback string) (*time.Location, error) {
+	var stored string
+	err := db.QueryRow("SELECT timezone FROM users WHERE id = $1", userID).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return resolveLocation(stored, fallback)
+}
+
+// resolveLocation picks the stored timezone if it is valid, then the
+// fallback, then UTC. An invalid fallback is an error.
+func resolveLocation(stored, fallback string) (*time.Location, error) {
+	if location, err := ParseTimezone(stored); err == nil {
+		return location, nil
+	}
+	if fallback == "" {
+		return time.UTC, nil
+	}
+	return ParseTimezone(fallback)
+}
+
+// localDate is the calendar date of now in the given location.
+func localDate(now time.Time, location *time.Location) string {
+	return now.In(location).Format(DateLayout)
+}
+
+// nextMidnight is the start of the day after now in the given location.
+func nextMidnight(now time.Time, location *time.Location) time.Time {
+	year, month, day := now.In(location).Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, location)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTimezone(t *testing.T) {
+	for _, name := range []string{"Australia/Sydney", "America/Los_Angeles", "UTC"} {
+		if _, err := ParseTimezone(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons", "+10:00"} {
+		if _, err := ParseTimezone(name); !errors.Is(err, ErrInvalidTimezone) {
+			t.Errorf("Expected %q to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestLocalDate(t *testing.T) {
+	// 22:00 UTC on 1 March is already 2 March in Sydney but still 1 March
+	// in Los Angeles.
+	now := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+
+	sydney, _ := ParseTimezone("Australia/Sydney")
+	losAngeles, _ := ParseTimezone("America/Los_Angeles")
+
+	if date := localDate(now, sydney); date != "2024-03-02" {
+		t.Errorf("Expected Sydney date 2024-03-02, got %s", date)
+	}
+	if date := localDate(now, losAngeles); date != "2024-03-01" {
+		t.Errorf("Expected Los Angeles date 2024-03-01, got %s", date)
+	}
+}
+
+func TestNextMidnight(t *testing.T) {
+	sydney, _ := ParseTimezone("Australia/Sydney")
+	now := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC) // 09:00 on 2 March in Sydney
+
+	next := nextMidnight(now, sydney)
+	if want := time.Date(2024, 3, 3, 0, 0, 0, 0, sydney); !next.Equal(want) {
+		t.Errorf("Expected next draw at %v, got %v", want, next)
+	}
+	if next.Sub(now) != 15*time.Hour {
+		t.Errorf("Expected 15 hours until the next draw, got %v", next.Sub(now))
+	}
+
+	t.Run("DaylightSaving", func(t *testing.T) {
+		newYork, _ := ParseTimezone("America/New_York")
+		// Clocks go forward at 02:00 on 10 March 2024, so that day is 23 hours long.
+		now := time.Date(2024, 3, 10, 0, 30, 0, 0, newYork)
+		next := nextMidnight(now, newYork)
+		if next.In(newYork).Hour() != 0 || next.In(newYork).Day() != 11 {
+			t.Errorf("Expected local midnight on 11 March, got %v", next.In(newYork))
+		}
+		if next.Sub(now) != 22*time.Hour+30*time.Minute {
+			t.Errorf("Expected 22h30m until the next draw, got %v", next.Sub(now))
+		}
+	})
+}
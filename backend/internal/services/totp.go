@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238). They are the defaults every authenticator
+// app supports: HMAC-SHA1, six digits and a 30 second step.
+const (
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+	// totpSkew is how many steps either side of the current one are
+	// accepted, to allow for clock drift and slow typing.
+	totpSkew = 1
+)
+
+// MFAIssuer names the app in authenticator apps.
+const MFAIssuer = "Symbol Quest"
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret returns a random 160-bit secret, base32 encoded as
+// authenticator apps expect.
+func newTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// totpCode returns the code for one time step (RFC 4226 section 5.3).
+func totpCode(secret []byte, counter uint64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP returns the time step that code is valid for at now, within
+// the allowed skew.
+func matchTOTP(secret, code string, now time.Time) (uint64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := uint64(now.Unix()) / uint64(totpPeriod.Seconds())
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		counter := current + uint64(skew)
+		if hmac.Equal([]byte(totpCode(key, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// provisioningURI returns the otpauth:// URI that authenticator apps read
+// from a QR code.
+func provisioningURI(secret, account string) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s&algorithm=SHA1&digits=%d&period=%d",
+		escape(MFAIssuer), escape(account), secret, escape(MFAIssuer), totpDigits, int(totpPeriod.Seconds()))
+}
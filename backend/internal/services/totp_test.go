@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits
+	secret := []byte("12345678901234567890")
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range tests {
+		if got := totpCode(secret, uint64(unix)/30); got != want {
+			t.Errorf("totpCode at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	if counter, ok := matchTOTP(secret, "050471", now); !ok || counter != 1111111111/30 {
+		t.Errorf("Expected the current code to match, got %d, %v", counter, ok)
+	}
+	// 1111111109 falls in the previous time step
+	if _, ok := matchTOTP(secret, "081804", now); !ok {
+		t.Error("Expected the previous step's code to be accepted")
+	}
+	if _, ok := matchTOTP(secret, "050471", now.Add(2*time.Minute)); ok {
+		t.Error("Expected an old code to be rejected")
+	}
+	if _, ok := matchTOTP(strings.ToLower(secret), "000000", now); ok {
+		t.Error("Expected a wrong code to be rejected")
+	}
+	if _, ok := matchTOTP("not base32!", "050471", now); ok {
+		t.Error("Expected an invalid secret to match nothing")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("Expected a 32 character secret, got %q, %v", secret, err)
+	}
+
+	uri := provisioningURI(secret, "user+tarot@example.com")
+	want := "otpauth://totp/Symbol%20Quest:user%2Btarot%40example.com?secret=" + secret + "&issuer=Symbol%20Quest&algorithm=SHA1&digits=6&period=30"
+	if uri != want {
+		t.Errorf("provisioningURI = %s, want %s", uri, want)
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"symbol-quest/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Features that call a model, as recorded in ai_usage.
+const (
+	UsageFeatureReading = "reading"
+	UsageFeatureChat    = "chat"
+)
+
+// ModelPrice is what a model costs in US dollars per million tokens.
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// ModelPrices are used to estimate spend. Models that are not listed, such
+// as those on a local server, are counted as free.
+var ModelPrices = map[string]ModelPrice{
+	"gpt-3.5-turbo": {Input: 0.50, Output: 1.50},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.60},
+	"gpt-4o":        {Input: 2.50, Output: 10.00},
+}
+
+// DefaultUsageQuotas are the monthly token allowances per subscription
+// tier. Zero means unlimited; unknown tiers get the free allowance.
+var DefaultUsageQuotas = map[string]int{
+	"free":    20000,
+	"premium": 500000,
+}
+
+// UsageService records every model call and enforces the monthly token
+// quotas. Months are calendar months in UTC. A nil *UsageService records
+// nothing and allows everything.
+type UsageService struct {
+	db     *sql.DB
+	quotas map[string]int
+	now    func() time.Time
+}
+
+func NewUsageService(db *sql.DB) *UsageService {
+	quotas := make(map[string]int, len(DefaultUsageQuotas))
+	for tier, quota := range DefaultUsageQuotas {
+		quotas[tier] = quota
+	}
+	return &UsageService{db: db, quotas: quotas, now: time.Now}
+}
+
+// SetQuota sets a tier's monthly token allowance. Zero means unlimited.
+func (s *UsageService) SetQuota(tier string, tokens int) {
+	s.quotas[tier] = max(0, tokens)
+}
+
+// QuotaFor returns the monthly token allowance of a subscription tier.
+func (s *UsageService) QuotaFor(tier string) int {
+	if quota, exists := s.quotas[tier]; exists {
+		return quota
+	}
+	return s.quotas["free"]
+}
+
+// Check returns ErrUsageQuota when the user has used up this month's
+// allowance. Calls already in flight are not counted, so a burst of
+// requests may go slightly over.
+func (s *UsageService) Check(userID uuid.UUID) error {
+	if s == nil {
+		return nil
+	}
+
+	summary, err := s.Summary(userID)
+	if err != nil {
+		return err
+	}
+	if summary.Unlimited || summary.TokensRemaining > 0 {
+		return nil
+	}
+	return ErrUsageQuota.WithDetails(map[string]interface{}{
+		"token_quota": summary.TokenQuota,
+		"tokens_used": summary.TokensUsed,
+		"resets_at":   summary.ResetsAt,
+	})
+}
+
+// Record logs one call that produced interpretation. Failing to record is
+// logged rather than returned, so the user still gets their answer.
+func (s *UsageService) Record(userID uuid.UUID, feature string, interpretation *Interpretation, latency time.Duration) {
+	if s == nil {
+		return
+	}
+
+	usage := interpretation.Usage
+	if usage == nil {
+		usage = &Usage{}
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO ai_usage (user_id, feature, provider, model, prompt_tokens, completion_tokens,
+		                      estimated, latency_ms, cost_usd)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, userID, feature, interpretation.Provider, usage.Model, usage.PromptTokens, usage.CompletionTokens,
+		usage.Estimated, latency.Milliseconds(), EstimateCost(usage))
+	if err != nil {
+		log.Printf("record AI usage: %v", err)
+	}
+}
+
+// EstimateCost prices a call from ModelPrices.
+func EstimateCost(usage *Usage) float64 {
+	price, exists := ModelPrices[usage.Model]
+	if !exists {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+}
+
+// Summary returns the user's usage this month against their quota.
+func (s *UsageService) Summary(userID uuid.UUID) (*models.UsageSummary, error) {
+	var tier string
+	err := s.db.QueryRow("SELECT COALESCE(subscription_tier, 'free') FROM users WHERE id = $1", userID).Scan(&tier)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	start := monthStart(s.now())
+	summary := &models.UsageSummary{
+		Tier:        tier,
+		PeriodStart: start.Format(DateLayout),
+		ResetsAt:    start.AddDate(0, 1, 0),
+		TokenQuota:  s.QuotaFor(tier),
+	}
+	err = s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens + completion_tokens), 0)
+		FROM ai_usage
+		WHERE user_id = $1 AND created_at >= $2
+	`, userID, start).Scan(&summary.Requests, &summary.TokensUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Unlimited = summary.TokenQuota == 0
+	if !summary.Unlimited {
+		summary.TokensRemaining = max(0, summary.TokenQuota-summary.TokensUsed)
+	}
+	return summary, nil
+}
+
+// Report adds up every model call from the start of from to the end of
+// to, both UTC dates, by day and by user. Users are ordered by cost.
+func (s *UsageService) Report(from, to time.Time) (*models.UsageReport, error) {
+	end := to.AddDate(0, 0, 1)
+	report := &models.UsageReport{
+		From:  from.Format(DateLayout),
+		To:    to.Format(DateLayout),
+		Days:  []models.DayUsage{},
+		Users: []models.UserUsage{},
+	}
+
+	rows, err := s.db.Query(`
+		SELECT (created_at AT TIME ZONE 'UTC')::date AS day, COUNT(*),
+		       SUM(prompt_tokens), SUM(completion_tokens), SUM(cost_usd)
+		FROM ai_usage
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY day
+		ORDER BY day
+	`, from, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day models.DayUsage
+		var date time.Time
+		if err := rows.Scan(&date, &day.Requests, &day.PromptTokens, &day.CompletionTokens, &day.CostUSD); err != nil {
+			return nil, err
+		}
+		day.Date = date.Format(DateLayout)
+		report.Days = append(report.Days, day)
+		report.Total.Requests += day.Requests
+		report.Total.PromptTokens += day.PromptTokens
+		report.Total.CompletionTokens += day.CompletionTokens
+		report.Total.CostUSD += day.CostUSD
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`
+		SELECT a.user_id, COALESCE(u.email, ''), COUNT(*),
+		       SUM(a.prompt_tokens), SUM(a.completion_tokens), SUM(a.cost_usd)
+		FROM ai_usage a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.created_at >= $1 AND a.created_at < $2
+		GROUP BY a.user_id, u.email
+		ORDER BY SUM(a.cost_usd) DESC, SUM(a.prompt_tokens + a.completion_tokens) DESC
+	`, from, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.UserUsage
+		if err := rows.Scan(&user.UserID, &user.Email, &user.Requests, &user.PromptTokens, &user.CompletionTokens, &user.CostUSD); err != nil {
+			return nil, err
+		}
+		report.Users = append(report.Users, user)
+	}
+	return report, rows.Err()
+}
+
+// monthStart returns midnight UTC on the first of t's month.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
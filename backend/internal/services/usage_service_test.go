@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		usage Usage
+		want  float64
+	}{
+		{Usage{Model: "gpt-3.5-turbo", PromptTokens: 1000, CompletionTokens: 500}, 0.00125},
+		{Usage{Model: "gpt-4o-mini", PromptTokens: 2000000}, 0.30},
+		{Usage{Model: "llama3", PromptTokens: 1000, CompletionTokens: 1000}, 0},
+		{Usage{}, 0},
+	}
+	for _, test := range tests {
+		if got := EstimateCost(&test.usage); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("EstimateCost(%+v) = %g, want %g", test.usage, got, test.want)
+		}
+	}
+}
+
+func TestUsageService_Quotas(t *testing.T) {
+	service := NewUsageService(nil)
+	service.SetQuota("premium", 0)
+
+	if service.QuotaFor("free") != DefaultUsageQuotas["free"] || service.QuotaFor("trial") != DefaultUsageQuotas["free"] {
+		t.Errorf("Expected free and unknown tiers to get the free quota")
+	}
+	if service.QuotaFor("premium") != 0 || DefaultUsageQuotas["premium"] == 0 {
+		t.Errorf("Expected SetQuota to change only this service's quota")
+	}
+
+	// A nil service meters nothing
+	var unmetered *UsageService
+	if err := unmetered.Check(uuid.New()); err != nil {
+		t.Errorf("Expected a nil service to allow every call, got %v", err)
+	}
+	unmetered.Record(uuid.New(), UsageFeatureReading, &Interpretation{}, time.Second)
+}
+
+func TestMonthStart(t *testing.T) {
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	got := monthStart(time.Date(2024, 3, 1, 8, 0, 0, 0, sydney))
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected the UTC month, got %s", got)
+	}
+}
+
+func TestUsageService_QuotaAndReport(t *testing.T) {
+	db, userID := testDatabase(t)
+	service := NewUsageService(db)
+	service.SetQuota("free", 1000)
+
+	reading := &Interpretation{Provider: InterpreterOpenAI, Usage: &Usage{Model: "gpt-3.5-turbo", PromptTokens: 600, CompletionTokens: 300}}
+	service.Record(userID, UsageFeatureReading, reading, 1200*time.Millisecond)
+
+	summary, err := service.Summary(userID)
+	if err != nil {
+		t.Fatalf("Summary returned error: %v", err)
+	}
+	if summary.Requests != 1 || summary.TokensUsed != 900 || summary.TokensRemaining != 100 || summary.Unlimited {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if err := service.Check(userID); err != nil {
+		t.Errorf("Expected allowance left, got %v", err)
+	}
+
+	service.Record(userID, UsageFeatureChat, &Interpretation{Provider: InterpreterTemplate}, time.Millisecond)
+	service.Record(userID, UsageFeatureChat, reading, time.Second)
+	if err := service.Check(userID); !errors.Is(err, ErrUsageQuota) {
+		t.Errorf("Expected ErrUsageQuota, got %v", err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	report, err := service.Report(today, today)
+	if err != nil {
+		t.Fatalf("Report returned error: %v", err)
+	}
+	found := false
+	for _, user := range report.Users {
+		if user.UserID != userID {
+			continue
+		}
+		found = true
+		if user.Requests != 3 || math.Abs(user.CostUSD-2*EstimateCost(reading.Usage)) > 1e-6 {
+			t.Errorf("Expected 3 calls and the cost of two readings, got %+v", user)
+		}
+	}
+	if !found {
+		t.Errorf("Expected the user in the report, got %+v", report.Users)
+	}
+	if len(report.Days) != 1 || report.Days[0].Date != today.Format(DateLayout) || report.Total.Requests < 3 {
+		t.Errorf("Expected today's totals, got %+v", report)
+	}
+}
@@ -6,6 +6,8 @@ import (
 )
 
 func TestMajorArcanaData(t *testing.T) {
+	MajorArcana := CardsInScope(ScopeMajor)
+
 	// Test that all 22 Major Arcana cards are present
 	expectedCards := 22
 	if len(MajorArcana) != expectedCards {
@@ -51,18 +53,113 @@ func TestMajorArcanaData(t *testing.T) {
 	}
 }
 
+func TestFullDeckData(t *testing.T) {
+	FullDeck := DefaultDeck().Cards
+	MinorArcana := make(map[int]Card)
+	for id, card := range FullDeck {
+		if card.Arcana == ArcanaMinor {
+			MinorArcana[id] = card
+		}
+	}
+
+	if len(MinorArcana) != 56 {
+		t.Errorf("Expected 56 Minor Arcana cards, got %d", len(MinorArcana))
+	}
+
+	if len(FullDeck) != 78 {
+		t.Errorf("Expected 78 cards in the full deck, got %d", len(FullDeck))
+	}
+
+	suitCounts := make(map[string]int)
+	for id, card := range MinorArcana {
+		if card.ID != id {
+			t.Errorf("Card ID mismatch: expected %d, got %d", id, card.ID)
+		}
+
+		if id < 22 || id > 77 {
+			t.Errorf("Minor Arcana card %d is outside the 22-77 range", id)
+		}
+
+		if card.Arcana != ArcanaMinor {
+			t.Errorf("Card %d should be minor arcana, got %q", id, card.Arcana)
+		}
+
+		if card.Suit == "" || card.Rank == "" {
+			t.Errorf("Card %d is missing suit or rank", id)
+		}
+
+		if len(card.Keywords) == 0 || len(card.Elements) == 0 {
+			t.Errorf("Card %d has no keywords or elements", id)
+		}
+
+		if len(card.LightAspects) == 0 || len(card.ShadowAspects) == 0 {
+			t.Errorf("Card %d is missing light or shadow aspects", id)
+		}
+
+		if card.TraditionalMeaning == "" {
+			t.Errorf("Card %d has empty traditional meaning", id)
+		}
+
+		if len(card.MoodWeights) != len(Moods) {
+			t.Errorf("Card %d has %d mood weights, expected %d", id, len(card.MoodWeights), len(Moods))
+		}
+
+		for mood, weight := range card.MoodWeights {
+			if weight < 0.0 || weight > 2.0 {
+				t.Errorf("Card %d has unreasonable mood weight for %s: %f", id, mood, weight)
+			}
+		}
+
+		suitCounts[card.Suit]++
+	}
+
+	for _, suit := range []string{SuitWands, SuitCups, SuitSwords, SuitPentacles} {
+		if suitCounts[suit] != 14 {
+			t.Errorf("Expected 14 cards in suit %s, got %d", suit, suitCounts[suit])
+		}
+	}
+
+	for id, card := range CardsInScope(ScopeMajor) {
+		if card.Arcana != ArcanaMajor {
+			t.Errorf("Card %d should be major arcana, got %q", id, card.Arcana)
+		}
+	}
+}
+
+func TestParseDeckScope(t *testing.T) {
+	cases := map[string]DeckScope{
+		"":      ScopeFull,
+		"full":  ScopeFull,
+		"major": ScopeMajor,
+		"MAJOR": ScopeMajor,
+	}
+	for input, expected := range cases {
+		scope, err := ParseDeckScope(input)
+		if err != nil {
+			t.Errorf("ParseDeckScope(%q) returned error: %v", input, err)
+		}
+		if scope != expected {
+			t.Errorf("ParseDeckScope(%q) = %q, expected %q", input, scope, expected)
+		}
+	}
+
+	if _, err := ParseDeckScope("minor-only"); err == nil {
+		t.Error("Expected error for unknown deck scope")
+	}
+}
+
 func TestSelectIntelligentCard(t *testing.T) {
 	userID := uuid.New()
 
 	t.Run("SelectsValidCard", func(t *testing.T) {
-		cardID := SelectIntelligentCard(userID, nil, "excited", "What should I focus on today?")
+		cardID := SelectIntelligentCard(userID, nil, DefaultDeck(), "excited", "What should I focus on today?", ScopeMajor)
 		
 		if cardID < 0 || cardID > 21 {
 			t.Errorf("Selected invalid card ID: %d", cardID)
 		}
 
-		if _, exists := MajorArcana[cardID]; !exists {
-			t.Errorf("Selected card ID %d does not exist in MajorArcana", cardID)
+		if _, exists := CardsInScope(ScopeMajor)[cardID]; !exists {
+			t.Errorf("Selected card ID %d does not exist in the Major Arcana", cardID)
 		}
 	})
 
@@ -72,7 +169,7 @@ func TestSelectIntelligentCard(t *testing.T) {
 		iterations := 100
 
 		for i := 0; i < iterations; i++ {
-			cardID := SelectIntelligentCard(userID, nil, "anxious", "I need guidance")
+			cardID := SelectIntelligentCard(userID, nil, DefaultDeck(), "anxious", "I need guidance", ScopeMajor)
 			results[cardID]++
 		}
 
@@ -89,8 +186,24 @@ func TestSelectIntelligentCard(t *testing.T) {
 		}
 	})
 
+	t.Run("FullDeckIncludesMinorArcana", func(t *testing.T) {
+		sawMinor := false
+		for i := 0; i < 200 && !sawMinor; i++ {
+			cardID := SelectIntelligentCard(userID, nil, DefaultDeck(), "curious", "", ScopeFull)
+			card, exists := GetCard(cardID)
+			if !exists {
+				t.Fatalf("Selected card ID %d does not exist in the full deck", cardID)
+			}
+			sawMinor = card.Arcana == ArcanaMinor
+		}
+
+		if !sawMinor {
+			t.Error("Expected full-deck draws to include Minor Arcana cards")
+		}
+	})
+
 	t.Run("HandlesEmptyMoodAndQuestion", func(t *testing.T) {
-		cardID := SelectIntelligentCard(userID, nil, "", "")
+		cardID := SelectIntelligentCard(userID, nil, DefaultDeck(), "", "", ScopeMajor)
 		
 		if cardID < 0 || cardID > 21 {
 			t.Errorf("Selected invalid card ID with empty mood/question: %d", cardID)
@@ -99,7 +212,7 @@ func TestSelectIntelligentCard(t *testing.T) {
 }
 
 func TestCalculateCardScore(t *testing.T) {
-	card := MajorArcana[0] // The Fool
+	card, _ := GetCard(0) // The Fool
 
 	t.Run("BaseLine", func(t *testing.T) {
 		score := calculateCardScore(card, "", "")
@@ -185,7 +298,7 @@ func TestGetRecentCards(t *testing.T) {
 	}()
 	
 	userID := uuid.New()
-	cards := getRecentCards(userID, nil, 5)
+	cards := RecentCards(userID, nil, 5)
 	
 	// If we get here, nil database returned empty slice
 	if len(cards) != 0 {
@@ -199,12 +312,12 @@ func BenchmarkSelectIntelligentCard(b *testing.B) {
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		SelectIntelligentCard(userID, nil, "excited", "What should I focus on?")
+		SelectIntelligentCard(userID, nil, DefaultDeck(), "excited", "What should I focus on?", ScopeMajor)
 	}
 }
 
 func BenchmarkCalculateCardScore(b *testing.B) {
-	card := MajorArcana[0]
+	card, _ := GetCard(0)
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
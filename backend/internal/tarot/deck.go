@@ -0,0 +1,322 @@
+package tarot
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultDeckName is the deck used when a draw does not name one.
+const DefaultDeckName = "rider-waite"
+
+// Rider–Waite–Smith suit names. Other decks may use their own, such as
+// the Thoth deck's Disks.
+const (
+	SuitWands     = "wands"
+	SuitCups      = "cups"
+	SuitSwords    = "swords"
+	SuitPentacles = "pentacles"
+)
+
+// Moods lists the mood keys every card must carry a weight for.
+var Moods = []string{"anxious", "excited", "uncertain", "hopeful", "peaceful", "frustrated", "curious", "contemplative"}
+
+//go:embed decks/*.json
+var embeddedDecks embed.FS
+
+// Deck is a named set of cards keyed by card ID. Card IDs identify the same
+// card across decks, so ID 8 is Strength in one deck and Lust in another.
+type Deck struct {
+	Name        string       `json:"name"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Cards       map[int]Card `json:"-"`
+
+	major map[int]Card
+}
+
+type deckFile struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Cards       []Card `json:"cards"`
+}
+
+var (
+	decksMu sync.RWMutex
+	decks   map[string]*Deck
+)
+
+func init() {
+	loaded, err := loadEmbeddedDecks()
+	if err != nil {
+		panic("tarot: invalid embedded deck: " + err.Error())
+	}
+	decks = loaded
+}
+
+// ParseDeck decodes and validates a JSON deck definition.
+func ParseDeck(data []byte) (*Deck, error) {
+	var file deckFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid deck JSON: %w", err)
+	}
+
+	if strings.TrimSpace(file.Name) == "" {
+		return nil, errors.New("deck name is required")
+	}
+	if len(file.Cards) == 0 {
+		return nil, fmt.Errorf("deck %s has no cards", file.Name)
+	}
+
+	deck := &Deck{
+		Name:        file.Name,
+		Title:       file.Title,
+		Description: file.Description,
+		Cards:       make(map[int]Card, len(file.Cards)),
+		major:       make(map[int]Card),
+	}
+	if deck.Title == "" {
+		deck.Title = deck.Name
+	}
+
+	for _, card := range file.Cards {
+		if err := validateCard(card); err != nil {
+			return nil, fmt.Errorf("deck %s: %w", file.Name, err)
+		}
+		if _, exists := deck.Cards[card.ID]; exists {
+			return nil, fmt.Errorf("deck %s: duplicate card ID %d", file.Name, card.ID)
+		}
+
+		deck.Cards[card.ID] = card
+		if card.Arcana == ArcanaMajor {
+			deck.major[card.ID] = card
+		}
+	}
+
+	return deck, nil
+}
+
+func validateCard(card Card) error {
+	if card.ID < 0 {
+		return fmt.Errorf("card %d has a negative ID", card.ID)
+	}
+
+	var missing []string
+	if card.Name == "" {
+		missing = append(missing, "name")
+	}
+	if card.Number == "" {
+		missing = append(missing, "number")
+	}
+	if len(card.Keywords) == 0 {
+		missing = append(missing, "keywords")
+	}
+	if len(card.Elements) == 0 {
+		missing = append(missing, "elements")
+	}
+	if card.TraditionalMeaning == "" {
+		missing = append(missing, "traditional_meaning")
+	}
+	if len(card.ShadowAspects) == 0 {
+		missing = append(missing, "shadow_aspects")
+	}
+	if len(card.LightAspects) == 0 {
+		missing = append(missing, "light_aspects")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("card %d is missing %s", card.ID, strings.Join(missing, ", "))
+	}
+
+	switch card.Arcana {
+	case ArcanaMajor:
+	case ArcanaMinor:
+		if card.Suit == "" || card.Rank == "" {
+			return fmt.Errorf("minor arcana card %d needs a suit and rank", card.ID)
+		}
+	default:
+		return fmt.Errorf("card %d has unknown arcana %q", card.ID, card.Arcana)
+	}
+
+	for _, mood := range Moods {
+		if _, exists := card.MoodWeights[mood]; !exists {
+			return fmt.Errorf("card %d has no weight for mood %q", card.ID, mood)
+		}
+	}
+	for mood, weight := range card.MoodWeights {
+		if !isKnownMood(mood) {
+			return fmt.Errorf("card %d has unknown mood %q", card.ID, mood)
+		}
+		if weight < 0 || weight > 2 {
+			return fmt.Errorf("card %d has mood weight %.2f for %q outside 0-2", card.ID, weight, mood)
+		}
+	}
+
+	return nil
+}
+
+func isKnownMood(mood string) bool {
+	for _, known := range Moods {
+		if known == mood {
+			return true
+		}
+	}
+	return false
+}
+
+// Card looks up a card in the deck.
+func (d *Deck) Card(cardID int) (Card, bool) {
+	card, exists := d.Cards[cardID]
+	return card, exists
+}
+
+// CardsInScope returns the cards a draw with the given scope may select.
+func (d *Deck) CardsInScope(scope DeckScope) map[int]Card {
+	if scope == ScopeMajor {
+		return d.major
+	}
+	return d.Cards
+}
+
+// LoadDecks replaces the registered decks with the embedded decks plus any
+// found at path, which may be a single JSON file or a directory of them.
+// Decks must be JSON; any other file at path is an error rather than being
+// skipped. A deck loaded from path replaces an embedded deck with the same
+// name. It is meant to be called once during startup.
+func LoadDecks(path string) error {
+	loaded, err := loadEmbeddedDecks()
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		files, err := deckFiles(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read deck %s: %w", file, err)
+			}
+			deck, err := ParseDeck(data)
+			if err != nil {
+				return fmt.Errorf("failed to load deck %s: %w", file, err)
+			}
+			loaded[deck.Name] = deck
+		}
+	}
+
+	if _, exists := loaded[DefaultDeckName]; !exists {
+		return fmt.Errorf("default deck %s is missing", DefaultDeckName)
+	}
+
+	decksMu.Lock()
+	decks = loaded
+	decksMu.Unlock()
+	return nil
+}
+
+func loadEmbeddedDecks() (map[string]*Deck, error) {
+	entries, err := embeddedDecks.ReadDir("decks")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]*Deck)
+	for _, entry := range entries {
+		data, err := embeddedDecks.ReadFile("decks/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		deck, err := ParseDeck(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		loaded[deck.Name] = deck
+	}
+
+	return loaded, nil
+}
+
+func deckFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deck path: %w", err)
+	}
+	if !info.IsDir() {
+		if !isDeckFile(path) {
+			return nil, fmt.Errorf("deck %s is not a .json file", path)
+		}
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deck path: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(path, entry.Name())
+		if !isDeckFile(file) {
+			return nil, fmt.Errorf("deck %s is not a .json file", file)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func isDeckFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// GetDeck looks up a registered deck by name. An empty name returns the
+// default deck.
+func GetDeck(name string) (*Deck, bool) {
+	if name == "" {
+		name = DefaultDeckName
+	}
+
+	decksMu.RLock()
+	defer decksMu.RUnlock()
+	deck, exists := decks[name]
+	return deck, exists
+}
+
+// DefaultDeck returns the Rider–Waite–Smith deck or its configured override.
+func DefaultDeck() *Deck {
+	deck, _ := GetDeck(DefaultDeckName)
+	return deck
+}
+
+// Decks lists the registered decks by name.
+func Decks() []*Deck {
+	decksMu.RLock()
+	defer decksMu.RUnlock()
+
+	list := make([]*Deck, 0, len(decks))
+	for _, deck := range decks {
+		list = append(list, deck)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// GetCard looks up a card in the default deck.
+func GetCard(cardID int) (Card, bool) {
+	return DefaultDeck().Card(cardID)
+}
+
+// CardsInScope returns the default deck's cards for the given scope.
+func CardsInScope(scope DeckScope) map[int]Card {
+	return DefaultDeck().CardsInScope(scope)
+}
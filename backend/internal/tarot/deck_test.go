@@ -0,0 +1,155 @@
+package tarot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDeckJSON = `{
+  "name": "test-deck",
+  "title": "Test Deck",
+  "cards": [
+    {
+      "id": 0,
+      "name": "The Beginner",
+      "number": "0",
+      "arcana": "major",
+      "keywords": ["beginnings"],
+      "elements": ["air"],
+      "traditional_meaning": "A fresh start",
+      "shadow_aspects": ["naivety"],
+      "light_aspects": ["openness"],
+      "mood_weights": {"anxious": 1, "excited": 1, "uncertain": 1, "hopeful": 1, "peaceful": 1, "frustrated": 1, "curious": 1, "contemplative": 1}
+    }
+  ]
+}`
+
+func TestEmbeddedDecks(t *testing.T) {
+	riderWaite, exists := GetDeck(DefaultDeckName)
+	if !exists {
+		t.Fatal("Expected the default deck to be registered")
+	}
+
+	thoth, exists := GetDeck("thoth")
+	if !exists {
+		t.Fatal("Expected the Thoth deck to be registered")
+	}
+
+	if len(riderWaite.Cards) != len(thoth.Cards) {
+		t.Errorf("Expected decks of equal size, got %d and %d", len(riderWaite.Cards), len(thoth.Cards))
+	}
+
+	for id := range riderWaite.Cards {
+		if _, exists := thoth.Card(id); !exists {
+			t.Errorf("Card %d is missing from the Thoth deck", id)
+		}
+	}
+
+	if riderWaite.Cards[11].Name != "Justice" || thoth.Cards[11].Name != "Adjustment" {
+		t.Errorf("Expected card 11 to be Justice and Adjustment, got %s and %s", riderWaite.Cards[11].Name, thoth.Cards[11].Name)
+	}
+
+	if riderWaite.Cards[2].ImageryDescription == "" || len(riderWaite.Cards[2].Symbols) == 0 {
+		t.Error("Expected Rider-Waite Major Arcana to carry imagery and symbols")
+	}
+
+	if deck, _ := GetDeck(""); deck != riderWaite {
+		t.Error("Expected an empty deck name to resolve to the default deck")
+	}
+}
+
+func TestParseDeck(t *testing.T) {
+	deck, err := ParseDeck([]byte(testDeckJSON))
+	if err != nil {
+		t.Fatalf("Expected valid deck, got %v", err)
+	}
+
+	if deck.Name != "test-deck" || len(deck.Cards) != 1 {
+		t.Errorf("Unexpected deck contents: %s with %d cards", deck.Name, len(deck.Cards))
+	}
+
+	if len(deck.CardsInScope(ScopeMajor)) != 1 {
+		t.Error("Expected one Major Arcana card in scope")
+	}
+
+	invalid := map[string]string{
+		"MissingName":      strings.Replace(testDeckJSON, `"name": "test-deck",`, "", 1),
+		"MissingMeaning":   strings.Replace(testDeckJSON, `"A fresh start"`, `""`, 1),
+		"MissingMood":      strings.Replace(testDeckJSON, `, "contemplative": 1`, "", 1),
+		"UnknownMood":      strings.Replace(testDeckJSON, `"contemplative": 1`, `"contemplative": 1, "sleepy": 1`, 1),
+		"WeightTooHigh":    strings.Replace(testDeckJSON, `"anxious": 1`, `"anxious": 3`, 1),
+		"UnknownArcana":    strings.Replace(testDeckJSON, `"major"`, `"middle"`, 1),
+		"MinorWithoutSuit": strings.Replace(testDeckJSON, `"major"`, `"minor"`, 1),
+		"InvalidJSON":      "{",
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseDeck([]byte(data)); err == nil {
+				t.Error("Expected deck to be rejected")
+			}
+		})
+	}
+
+	t.Run("DuplicateID", func(t *testing.T) {
+		start := strings.Index(testDeckJSON, "    {")
+		end := strings.LastIndex(testDeckJSON, "}\n  ]")
+		card := testDeckJSON[start : end+1]
+		duplicate := strings.Replace(testDeckJSON, card, card+",\n"+card, 1)
+		if _, err := ParseDeck([]byte(duplicate)); err == nil {
+			t.Error("Expected duplicate card IDs to be rejected")
+		}
+	})
+}
+
+func TestLoadDecks(t *testing.T) {
+	defer LoadDecks("")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test-deck.json"), []byte(testDeckJSON), 0644); err != nil {
+		t.Fatalf("Failed to write deck file: %v", err)
+	}
+
+	if err := LoadDecks(dir); err != nil {
+		t.Fatalf("LoadDecks returned error: %v", err)
+	}
+
+	if _, exists := GetDeck("test-deck"); !exists {
+		t.Error("Expected deck from path to be registered")
+	}
+
+	if _, exists := GetDeck(DefaultDeckName); !exists {
+		t.Error("Expected embedded decks to remain registered")
+	}
+
+	t.Run("InvalidPath", func(t *testing.T) {
+		if err := LoadDecks(filepath.Join(dir, "missing")); err == nil {
+			t.Error("Expected error for missing deck path")
+		}
+	})
+
+	t.Run("InvalidDeckFile", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.json")
+		if err := os.WriteFile(bad, []byte(`{"name": "bad", "cards": []}`), 0644); err != nil {
+			t.Fatalf("Failed to write deck file: %v", err)
+		}
+		if err := LoadDecks(bad); err == nil {
+			t.Error("Expected error for deck without cards")
+		}
+	})
+
+	t.Run("UnsupportedFile", func(t *testing.T) {
+		yamlDir := t.TempDir()
+		yamlDeck := filepath.Join(yamlDir, "deck.yaml")
+		if err := os.WriteFile(yamlDeck, []byte("name: yaml-deck\n"), 0644); err != nil {
+			t.Fatalf("Failed to write deck file: %v", err)
+		}
+		if err := LoadDecks(yamlDeck); err == nil {
+			t.Error("Expected error for a YAML deck file")
+		}
+		if err := LoadDecks(yamlDir); err == nil {
+			t.Error("Expected error for a YAML file in the deck directory")
+		}
+	})
+}
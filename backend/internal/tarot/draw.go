@@ -0,0 +1,118 @@
+package tarot
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// DrawInput is everything needed to reproduce a draw: the same input always
+// deals the same cards in the same orientations.
+type DrawInput struct {
+	Seed                int64
+	Deck                string
+	Scope               DeckScope
+	Selector            string
+	Mood                string
+	Question            string
+	Recent              []int
+	ReversalProbability float64
+}
+
+// DrawResult is a single card dealt by Draw.
+type DrawResult struct {
+	CardID      int
+	Orientation Orientation
+	Explanation Explanation
+}
+
+// NewSeed returns a fresh seed from the operating system's secure source.
+func NewSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("tarot: reading random seed: %v", err))
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// NewRand returns a random source owned by a single draw.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// Selection returns the selection context described by the input, with
+// its own random source seeded from the input seed.
+func (in DrawInput) Selection() Selection {
+	return Selection{
+		Mood:     in.Mood,
+		Question: in.Question,
+		Recent:   in.Recent,
+		Rand:     NewRand(in.Seed),
+	}
+}
+
+// Commitment is a SHA-256 digest of the draw inputs. It is stored with the
+// draw so a later verification can show the inputs were not altered.
+func (in DrawInput) Commitment() string {
+	recent := make([]string, len(in.Recent))
+	for i, cardID := range in.Recent {
+		recent[i] = fmt.Sprint(cardID)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		fmt.Sprint(in.Seed),
+		in.Deck,
+		string(in.Scope),
+		in.Selector,
+		strings.ToLower(in.Mood),
+		in.Question,
+		strings.Join(recent, ","),
+		fmt.Sprint(in.ReversalProbability),
+	}, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Draw deals a single card for the input. It returns an error when the deck
+// or selector named in the input does not exist.
+func Draw(in DrawInput) (DrawResult, error) {
+	deck, selector, err := in.resolve()
+	if err != nil {
+		return DrawResult{}, err
+	}
+
+	selection := in.Selection()
+	choice := selector.Select(deck.CardsInScope(in.Scope), selection)
+	return DrawResult{
+		CardID:      choice.CardID,
+		Orientation: DrawOrientation(selection.Rand, in.ReversalProbability),
+		Explanation: choice.Explanation,
+	}, nil
+}
+
+func (in DrawInput) resolve() (*Deck, Selector, error) {
+	deck, exists := GetDeck(in.Deck)
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown deck %q", in.Deck)
+	}
+	selector, exists := GetSelector(in.Selector)
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown selector %q", in.Selector)
+	}
+	return deck, selector, nil
+}
+
+// sortedIDs returns the candidate IDs in ascending order so that a seeded
+// draw does not depend on map iteration order.
+func sortedIDs(candidates map[int]Card) []int {
+	ids := make([]int, 0, len(candidates))
+	for cardID := range candidates {
+		ids = append(ids, cardID)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,86 @@
+package tarot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrawIsReproducible(t *testing.T) {
+	for _, name := range SelectorNames() {
+		t.Run(name, func(t *testing.T) {
+			in := DrawInput{
+				Seed:                NewSeed(),
+				Deck:                DefaultDeckName,
+				Scope:               ScopeFull,
+				Selector:            name,
+				Mood:                "curious",
+				Question:            "What should I focus on in my career?",
+				Recent:              []int{1, 22, 40},
+				ReversalProbability: 0.5,
+			}
+
+			first, err := Draw(in)
+			if err != nil {
+				t.Fatalf("Draw returned error: %v", err)
+			}
+			for i := 0; i < 20; i++ {
+				if again, _ := Draw(in); again.CardID != first.CardID || again.Orientation != first.Orientation || !reflect.DeepEqual(again.Explanation, first.Explanation) {
+					t.Fatalf("Expected %+v for the same seed, got %+v", first, again)
+				}
+			}
+		})
+	}
+
+	t.Run("SeedsVary", func(t *testing.T) {
+		seen := make(map[int]bool)
+		for seed := int64(0); seed < 50; seed++ {
+			result, _ := Draw(DrawInput{Seed: seed, Selector: SelectorRandom, Scope: ScopeFull})
+			seen[result.CardID] = true
+		}
+		if len(seen) < 10 {
+			t.Errorf("Expected different seeds to deal different cards, got %d distinct cards", len(seen))
+		}
+	})
+}
+
+func TestDrawUnknownInputs(t *testing.T) {
+	if _, err := Draw(DrawInput{Deck: "missing"}); err == nil {
+		t.Error("Expected error for unknown deck")
+	}
+	if _, err := Draw(DrawInput{Selector: "psychic"}); err == nil {
+		t.Error("Expected error for unknown selector")
+	}
+}
+
+func TestDrawInputCommitment(t *testing.T) {
+	in := DrawInput{Seed: 7, Deck: DefaultDeckName, Scope: ScopeFull, Selector: SelectorHistory, Mood: "anxious", Recent: []int{4, 5}}
+
+	if in.Commitment() != in.Commitment() {
+		t.Error("Expected the commitment to be stable")
+	}
+	if len(in.Commitment()) != 64 {
+		t.Errorf("Expected a hex SHA-256 digest, got %q", in.Commitment())
+	}
+
+	changed := []DrawInput{in, in, in, in}
+	changed[0].Seed = 8
+	changed[1].Mood = "hopeful"
+	changed[2].Recent = []int{45}
+	changed[3].ReversalProbability = 0.5
+	for i, other := range changed {
+		if other.Commitment() == in.Commitment() {
+			t.Errorf("Expected change %d to alter the commitment", i)
+		}
+	}
+}
+
+func TestNewSeed(t *testing.T) {
+	if NewSeed() == NewSeed() {
+		t.Error("Expected fresh seeds to differ")
+	}
+	for i := 0; i < 100; i++ {
+		if NewSeed() < 0 {
+			t.Fatal("Expected non-negative seeds")
+		}
+	}
+}
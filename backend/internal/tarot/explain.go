@@ -0,0 +1,124 @@
+package tarot
+
+import "strings"
+
+// Choice is a selected card together with the reasoning behind it.
+type Choice struct {
+	CardID      int
+	Explanation Explanation
+}
+
+// Explanation describes how a selector arrived at a card.
+type Explanation struct {
+	Selector string `json:"selector"`
+	Mood     string `json:"mood,omitempty"`
+	Question string `json:"question,omitempty"`
+	// Candidates is how many cards were in scope for the draw.
+	Candidates int `json:"candidates"`
+	// ExcludedRecent lists the candidates skipped because the user drew
+	// them recently.
+	ExcludedRecent []int `json:"excluded_recent"`
+	// Fallback is set when no card scored above zero and the card was
+	// picked uniformly at random instead.
+	Fallback bool `json:"fallback,omitempty"`
+	// Chosen is the score breakdown of the selected card. It is nil for
+	// purely random selection.
+	Chosen *ScoreBreakdown `json:"chosen,omitempty"`
+}
+
+// ScoreBreakdown itemises the factors multiplied into a card's score.
+type ScoreBreakdown struct {
+	CardID              int      `json:"card_id"`
+	BaseScore           float64  `json:"base_score"`
+	MoodWeight          float64  `json:"mood_weight"`
+	MatchedKeywords     []string `json:"matched_keywords"`
+	KeywordFactor       float64  `json:"keyword_factor"`
+	MatchedMeaningWords []string `json:"matched_meaning_words"`
+	MeaningFactor       float64  `json:"meaning_factor"`
+	MatchedThemes       []string `json:"matched_themes"`
+	ThemeFactor         float64  `json:"theme_factor"`
+	HistoryFactor       float64  `json:"history_factor"`
+	RandomFactor        float64  `json:"random_factor"`
+	Score               float64  `json:"score"`
+}
+
+// scoreCard breaks down the mood and question score of a card before any
+// history or random adjustment.
+func scoreCard(card Card, mood string, question analyzedQuestion) ScoreBreakdown {
+	breakdown := ScoreBreakdown{
+		CardID:              card.ID,
+		BaseScore:           1.0,
+		MoodWeight:          1.0,
+		MatchedKeywords:     []string{},
+		KeywordFactor:       1.0,
+		MatchedMeaningWords: []string{},
+		MeaningFactor:       1.0,
+		MatchedThemes:       []string{},
+		ThemeFactor:         1.0,
+		HistoryFactor:       1.0,
+		RandomFactor:        1.0,
+	}
+
+	// Apply mood weights
+	if mood != "" {
+		if weight, exists := card.MoodWeights[strings.ToLower(mood)]; exists {
+			breakdown.MoodWeight = weight
+		}
+	}
+
+	// Apply question matching
+	if len(question.tokens) > 0 {
+		lexicon := question.lexicon
+
+		// A keyword matches when all of its words appear in the question
+		for _, keyword := range card.Keywords {
+			tokens := Tokenize(keyword)
+			if len(tokens) > 0 && containsAll(question.tokens, tokens) {
+				breakdown.MatchedKeywords = append(breakdown.MatchedKeywords, keyword)
+				breakdown.KeywordFactor *= lexicon.KeywordWeight
+			}
+		}
+
+		// Check traditional meaning, counting each word once
+		seen := make(map[string]bool)
+		for _, token := range Tokenize(card.TraditionalMeaning) {
+			if len(token) >= minStemLength && question.tokens[token] && !seen[token] {
+				seen[token] = true
+				breakdown.MatchedMeaningWords = append(breakdown.MatchedMeaningWords, token)
+				breakdown.MeaningFactor *= lexicon.MeaningWeight
+			}
+		}
+
+		// Check the question's themes against the card's keywords and archetypes
+		if len(question.themes) > 0 {
+			cardTokens := make(map[string]bool)
+			for _, term := range append(append([]string{}, card.Keywords...), card.Archetypes...) {
+				for _, token := range Tokenize(term) {
+					cardTokens[token] = true
+				}
+			}
+			for _, theme := range question.themes {
+				if theme.matches(cardTokens) {
+					breakdown.MatchedThemes = append(breakdown.MatchedThemes, theme.Name)
+					breakdown.ThemeFactor *= lexicon.ThemeWeight
+				}
+			}
+		}
+	}
+
+	breakdown.Score = breakdown.total()
+	return breakdown
+}
+
+func containsAll(set map[string]bool, tokens []string) bool {
+	for _, token := range tokens {
+		if !set[token] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b ScoreBreakdown) total() float64 {
+	return b.BaseScore * b.MoodWeight * b.KeywordFactor * b.MeaningFactor * b.ThemeFactor * b.HistoryFactor * b.RandomFactor
+}
@@ -0,0 +1,82 @@
+package tarot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreCardBreakdown(t *testing.T) {
+	card := Card{
+		ID:                 3,
+		Keywords:           []string{"career", "growth"},
+		TraditionalMeaning: "Steady progress through patience",
+		MoodWeights:        map[string]float64{"anxious": 1.5},
+	}
+
+	breakdown := scoreCard(card, "Anxious", analyzeQuestion("Will my career show progress?"))
+
+	if breakdown.MoodWeight != 1.5 {
+		t.Errorf("Expected mood weight 1.5, got %f", breakdown.MoodWeight)
+	}
+	if len(breakdown.MatchedKeywords) != 1 || breakdown.MatchedKeywords[0] != "career" {
+		t.Errorf("Expected keyword 'career' to match, got %v", breakdown.MatchedKeywords)
+	}
+	if len(breakdown.MatchedMeaningWords) != 1 || breakdown.MatchedMeaningWords[0] != "progress" {
+		t.Errorf("Expected meaning word 'progress' to match, got %v", breakdown.MatchedMeaningWords)
+	}
+
+	if len(breakdown.MatchedThemes) != 2 || breakdown.MatchedThemes[0] != "career" || breakdown.MatchedThemes[1] != "growth" {
+		t.Errorf("Expected themes [career growth], got %v", breakdown.MatchedThemes)
+	}
+
+	expected := 1.5 * 1.2 * 1.1 * 1.1 * 1.1
+	if math.Abs(breakdown.Score-expected) > 1e-9 {
+		t.Errorf("Expected score %f, got %f", expected, breakdown.Score)
+	}
+	if breakdown.Score != calculateCardScore(card, "Anxious", "Will my career show progress?") {
+		t.Error("Expected the breakdown to agree with calculateCardScore")
+	}
+}
+
+func TestSelectorExplanations(t *testing.T) {
+	candidates := CardsInScope(ScopeMajor)
+	selection := Selection{Mood: "anxious", Question: "career", Recent: []int{0, 1, 40}, Rand: NewRand(3)}
+
+	t.Run("History", func(t *testing.T) {
+		choice := HistoryAwareSelector{}.Select(candidates, selection)
+		explanation := choice.Explanation
+
+		if explanation.Selector != SelectorHistory || explanation.Candidates != len(candidates) {
+			t.Errorf("Unexpected explanation header: %+v", explanation)
+		}
+		if len(explanation.ExcludedRecent) != 2 || explanation.ExcludedRecent[0] != 0 || explanation.ExcludedRecent[1] != 1 {
+			t.Errorf("Expected in-scope recent cards [0 1] to be listed, got %v", explanation.ExcludedRecent)
+		}
+		if explanation.Chosen == nil || explanation.Chosen.CardID != choice.CardID {
+			t.Fatalf("Expected a breakdown for the chosen card, got %+v", explanation.Chosen)
+		}
+
+		chosen := explanation.Chosen
+		if chosen.RandomFactor < 0.8 || chosen.RandomFactor > 1.2 {
+			t.Errorf("Random factor %f outside 0.8-1.2", chosen.RandomFactor)
+		}
+		if math.Abs(chosen.Score-chosen.total()) > 1e-9 {
+			t.Errorf("Score %f does not equal the product of its factors %f", chosen.Score, chosen.total())
+		}
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		explanation := RandomSelector{}.Select(candidates, selection).Explanation
+		if explanation.Selector != SelectorRandom || explanation.Chosen != nil {
+			t.Errorf("Expected a random explanation without scores, got %+v", explanation)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		zero := map[int]Card{5: {ID: 5, MoodWeights: map[string]float64{"anxious": 0}}}
+		explanation := WeightedSelector{}.Select(zero, Selection{Mood: "anxious"}).Explanation
+		if !explanation.Fallback || explanation.Chosen != nil {
+			t.Errorf("Expected a fallback explanation, got %+v", explanation)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package tarot
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed lexicon.json
+var embeddedLexicon []byte
+
+// Theme groups words that point to the same life area, such as "partner",
+// "dating" and "marriage" for love. A question that mentions any of a
+// theme's terms favours cards whose keywords or archetypes mention any of
+// them too.
+type Theme struct {
+	Name  string   `json:"name"`
+	Terms []string `json:"terms"`
+
+	stems map[string]bool
+}
+
+// Lexicon configures how a question is matched against cards, including
+// the score factor applied per direct keyword, meaning word and theme.
+type Lexicon struct {
+	KeywordWeight float64 `json:"keyword_weight"`
+	MeaningWeight float64 `json:"meaning_weight"`
+	ThemeWeight   float64 `json:"theme_weight"`
+	Themes        []Theme `json:"themes"`
+}
+
+var (
+	lexiconMu sync.RWMutex
+	lexicon   *Lexicon
+)
+
+func init() {
+	parsed, err := ParseLexicon(embeddedLexicon)
+	if err != nil {
+		panic("tarot: invalid embedded lexicon: " + err.Error())
+	}
+	lexicon = parsed
+}
+
+// ParseLexicon decodes and validates a JSON lexicon.
+func ParseLexicon(data []byte) (*Lexicon, error) {
+	var parsed Lexicon
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid lexicon JSON: %w", err)
+	}
+
+	for name, weight := range map[string]float64{
+		"keyword_weight": parsed.KeywordWeight,
+		"meaning_weight": parsed.MeaningWeight,
+		"theme_weight":   parsed.ThemeWeight,
+	} {
+		if weight < 1 || weight > 2 {
+			return nil, fmt.Errorf("lexicon %s must be between 1 and 2, got %g", name, weight)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range parsed.Themes {
+		theme := &parsed.Themes[i]
+		if strings.TrimSpace(theme.Name) == "" {
+			return nil, errors.New("every lexicon theme needs a name")
+		}
+		if seen[theme.Name] {
+			return nil, fmt.Errorf("duplicate lexicon theme %q", theme.Name)
+		}
+		seen[theme.Name] = true
+
+		theme.stems = make(map[string]bool)
+		for _, term := range append([]string{theme.Name}, theme.Terms...) {
+			for _, token := range Tokenize(term) {
+				theme.stems[token] = true
+			}
+		}
+		if len(theme.stems) == 0 {
+			return nil, fmt.Errorf("lexicon theme %q has no usable terms", theme.Name)
+		}
+	}
+
+	return &parsed, nil
+}
+
+// LoadLexicon replaces the built-in lexicon with the one in the JSON file
+// at path. An empty path restores the built-in lexicon.
+func LoadLexicon(path string) error {
+	data := embeddedLexicon
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseLexicon(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	lexiconMu.Lock()
+	lexicon = parsed
+	lexiconMu.Unlock()
+	return nil
+}
+
+// CurrentLexicon returns the lexicon used for question matching.
+func CurrentLexicon() *Lexicon {
+	lexiconMu.RLock()
+	defer lexiconMu.RUnlock()
+	return lexicon
+}
+
+// matches reports whether any of the tokens belongs to the theme.
+func (t Theme) matches(tokens map[string]bool) bool {
+	for token := range tokens {
+		if t.stems[token] {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzedQuestion is a question reduced to its stemmed tokens and the
+// lexicon themes it mentions, computed once per draw.
+type analyzedQuestion struct {
+	text    string
+	tokens  map[string]bool
+	themes  []Theme
+	lexicon *Lexicon
+}
+
+func analyzeQuestion(question string) analyzedQuestion {
+	analyzed := analyzedQuestion{
+		text:    question,
+		tokens:  make(map[string]bool),
+		lexicon: CurrentLexicon(),
+	}
+
+	for _, token := range Tokenize(question) {
+		analyzed.tokens[token] = true
+	}
+
+	for _, theme := range analyzed.lexicon.Themes {
+		if theme.matches(analyzed.tokens) {
+			analyzed.themes = append(analyzed.themes, theme)
+		}
+	}
+
+	return analyzed
+}
@@ -0,0 +1,52 @@
+package tarot
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Orientation records whether a card was drawn upright or reversed.
+type Orientation string
+
+const (
+	OrientationUpright  Orientation = "upright"
+	OrientationReversed Orientation = "reversed"
+)
+
+// DefaultReversalProbability is the chance of a reversal when none is configured.
+const DefaultReversalProbability = 0.25
+
+// ParseOrientation maps a request value onto an Orientation. An empty value
+// means upright.
+func ParseOrientation(value string) (Orientation, error) {
+	switch Orientation(strings.ToLower(value)) {
+	case "", OrientationUpright:
+		return OrientationUpright, nil
+	case OrientationReversed:
+		return OrientationReversed, nil
+	}
+	return "", fmt.Errorf("unknown orientation %q", value)
+}
+
+// DrawOrientation decides whether a drawn card lands reversed, with the given
+// probability in the range 0–1. It always consumes one value from rng so
+// that later draws from the same source do not depend on the probability.
+func DrawOrientation(rng *rand.Rand, reversalProbability float64) Orientation {
+	if rng.Float64() < reversalProbability {
+		return OrientationReversed
+	}
+	return OrientationUpright
+}
+
+// Meaning returns the card's meaning for the given orientation. Reversed cards
+// fall back to their shadow aspects when no reversed meaning is recorded.
+func (c Card) Meaning(orientation Orientation) string {
+	if orientation != OrientationReversed {
+		return c.TraditionalMeaning
+	}
+	if c.ReversedMeaning != "" {
+		return c.ReversedMeaning
+	}
+	return strings.Join(c.ShadowAspects, ", ")
+}
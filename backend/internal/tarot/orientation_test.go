@@ -0,0 +1,72 @@
+package tarot
+
+import "testing"
+
+func TestParseOrientation(t *testing.T) {
+	cases := map[string]Orientation{
+		"":         OrientationUpright,
+		"upright":  OrientationUpright,
+		"reversed": OrientationReversed,
+		"Reversed": OrientationReversed,
+	}
+	for input, expected := range cases {
+		orientation, err := ParseOrientation(input)
+		if err != nil {
+			t.Errorf("ParseOrientation(%q) returned error: %v", input, err)
+		}
+		if orientation != expected {
+			t.Errorf("ParseOrientation(%q) = %q, expected %q", input, orientation, expected)
+		}
+	}
+
+	if _, err := ParseOrientation("sideways"); err == nil {
+		t.Error("Expected error for unknown orientation")
+	}
+}
+
+func TestDrawOrientation(t *testing.T) {
+	rng := NewRand(1)
+
+	t.Run("NeverReversed", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			if DrawOrientation(rng, 0) != OrientationUpright {
+				t.Fatal("Expected upright card with zero reversal probability")
+			}
+		}
+	})
+
+	t.Run("AlwaysReversed", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			if DrawOrientation(rng, 1) != OrientationReversed {
+				t.Fatal("Expected reversed card with reversal probability of 1")
+			}
+		}
+	})
+}
+
+func TestCardMeaning(t *testing.T) {
+	card, _ := GetCard(16) // The Tower
+
+	if card.Meaning(OrientationUpright) != card.TraditionalMeaning {
+		t.Errorf("Expected upright meaning %q, got %q", card.TraditionalMeaning, card.Meaning(OrientationUpright))
+	}
+
+	if card.Meaning(OrientationReversed) != card.ReversedMeaning {
+		t.Errorf("Expected reversed meaning %q, got %q", card.ReversedMeaning, card.Meaning(OrientationReversed))
+	}
+
+	t.Run("EveryCardHasReversedMeaning", func(t *testing.T) {
+		for id, card := range DefaultDeck().Cards {
+			if card.ReversedMeaning == "" {
+				t.Errorf("Card %d has empty reversed meaning", id)
+			}
+		}
+	})
+
+	t.Run("FallsBackToShadowAspects", func(t *testing.T) {
+		bare := Card{TraditionalMeaning: "Upright", ShadowAspects: []string{"fear", "doubt"}}
+		if bare.Meaning(OrientationReversed) != "fear, doubt" {
+			t.Errorf("Expected shadow aspect fallback, got %q", bare.Meaning(OrientationReversed))
+		}
+	})
+}
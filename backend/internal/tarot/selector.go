@@ -0,0 +1,179 @@
+package tarot
+
+import (
+	"math/rand"
+	"sort"
+)
+
+const (
+	SelectorRandom   = "random"
+	SelectorWeighted = "weighted"
+	SelectorHistory  = "history"
+
+	// DefaultSelectorName is the strategy used when neither the request
+	// nor the user asks for one.
+	DefaultSelectorName = SelectorHistory
+
+	// RecentExclusionCount is how many of the user's latest cards the
+	// history-aware selector never repeats.
+	RecentExclusionCount = 5
+
+	// HistoryWindow is how many past draws are loaded for selection.
+	HistoryWindow = 20
+)
+
+// Selection carries the context a Selector may use to pick a card.
+type Selection struct {
+	Mood     string
+	Question string
+	// Recent holds the user's previously drawn card IDs, most recent first.
+	Recent []int
+	// Exclude holds card IDs that must not be selected, such as cards
+	// already dealt into another position of the same spread.
+	Exclude []int
+	// Rand is the draw's own random source. Selectors fall back to a
+	// freshly seeded source when it is nil.
+	Rand *rand.Rand
+}
+
+func (s Selection) rng() *rand.Rand {
+	if s.Rand == nil {
+		return NewRand(NewSeed())
+	}
+	return s.Rand
+}
+
+// Selector picks one card from a set of candidates and explains why.
+type Selector interface {
+	Name() string
+	Select(candidates map[int]Card, selection Selection) Choice
+}
+
+// RandomSelector draws uniformly at random, ignoring mood, question and
+// history.
+type RandomSelector struct{}
+
+func (RandomSelector) Name() string {
+	return SelectorRandom
+}
+
+func (RandomSelector) Select(candidates map[int]Card, selection Selection) Choice {
+	ids := availableIDs(candidates, selection.Exclude)
+	return Choice{
+		CardID: ids[selection.rng().Intn(len(ids))],
+		Explanation: Explanation{
+			Selector:       SelectorRandom,
+			Candidates:     len(candidates),
+			ExcludedRecent: []int{},
+		},
+	}
+}
+
+// WeightedSelector scores cards by mood and question keywords with a
+// random factor of 0.8 to 1.2, and does not look at history.
+type WeightedSelector struct{}
+
+func (WeightedSelector) Name() string {
+	return SelectorWeighted
+}
+
+func (WeightedSelector) Select(candidates map[int]Card, selection Selection) Choice {
+	choice := selectBestCard(selection.rng(), candidates, selection.Exclude, selection.Mood, selection.Question, nil)
+	choice.Explanation.Selector = SelectorWeighted
+	choice.Explanation.ExcludedRecent = []int{}
+	return choice
+}
+
+// HistoryAwareSelector uses the weighted score, never repeats the user's
+// RecentExclusionCount latest cards and down-weights cards that appear
+// further back in their history.
+type HistoryAwareSelector struct{}
+
+func (HistoryAwareSelector) Name() string {
+	return SelectorHistory
+}
+
+func (HistoryAwareSelector) Select(candidates map[int]Card, selection Selection) Choice {
+	recent := selection.Recent
+	if len(recent) > RecentExclusionCount {
+		recent = recent[:RecentExclusionCount]
+	}
+
+	// Fall back to the hard exclusions alone when history would leave
+	// nothing to draw, e.g. a small scope and a long streak of draws.
+	excluded := append(append([]int{}, selection.Exclude...), recent...)
+	excludedRecent := []int{}
+	if hasAvailable(candidates, excluded) {
+		for _, cardID := range recent {
+			if _, inScope := candidates[cardID]; inScope && !contains(excludedRecent, cardID) && !contains(selection.Exclude, cardID) {
+				excludedRecent = append(excludedRecent, cardID)
+			}
+		}
+	} else {
+		excluded = selection.Exclude
+	}
+
+	counts := make(map[int]int)
+	for _, cardID := range selection.Recent {
+		counts[cardID]++
+	}
+
+	choice := selectBestCard(selection.rng(), candidates, excluded, selection.Mood, selection.Question, func(cardID int) float64 {
+		return 1 / float64(1+counts[cardID])
+	})
+	choice.Explanation.Selector = SelectorHistory
+	choice.Explanation.ExcludedRecent = excludedRecent
+	return choice
+}
+
+var selectors = map[string]Selector{
+	SelectorRandom:   RandomSelector{},
+	SelectorWeighted: WeightedSelector{},
+	SelectorHistory:  HistoryAwareSelector{},
+}
+
+// GetSelector looks up a selection strategy by name. An empty name
+// returns the default strategy.
+func GetSelector(name string) (Selector, bool) {
+	if name == "" {
+		name = DefaultSelectorName
+	}
+	selector, exists := selectors[name]
+	return selector, exists
+}
+
+// SelectorNames lists the available selection strategies.
+func SelectorNames() []string {
+	names := make([]string, 0, len(selectors))
+	for name := range selectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasAvailable reports whether any candidate is not excluded.
+func hasAvailable(candidates map[int]Card, excluded []int) bool {
+	for cardID := range candidates {
+		if !contains(excluded, cardID) {
+			return true
+		}
+	}
+	return false
+}
+
+// availableIDs returns the candidate IDs that are not excluded, or every
+// candidate ID if all of them are excluded.
+func availableIDs(candidates map[int]Card, excluded []int) []int {
+	var ids, allIDs []int
+	for _, cardID := range sortedIDs(candidates) {
+		allIDs = append(allIDs, cardID)
+		if !contains(excluded, cardID) {
+			ids = append(ids, cardID)
+		}
+	}
+	if len(ids) == 0 {
+		return allIDs
+	}
+	return ids
+}
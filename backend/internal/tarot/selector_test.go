@@ -0,0 +1,119 @@
+package tarot
+
+import "testing"
+
+func TestGetSelector(t *testing.T) {
+	for _, name := range []string{SelectorRandom, SelectorWeighted, SelectorHistory} {
+		selector, exists := GetSelector(name)
+		if !exists {
+			t.Fatalf("Expected selector %q to exist", name)
+		}
+		if selector.Name() != name {
+			t.Errorf("Expected selector name %q, got %q", name, selector.Name())
+		}
+	}
+
+	if selector, _ := GetSelector(""); selector.Name() != DefaultSelectorName {
+		t.Errorf("Expected empty name to resolve to %q, got %q", DefaultSelectorName, selector.Name())
+	}
+
+	if _, exists := GetSelector("psychic"); exists {
+		t.Error("Expected unknown selector to be rejected")
+	}
+
+	if names := SelectorNames(); len(names) != 3 || names[0] != SelectorHistory {
+		t.Errorf("Unexpected selector names: %v", names)
+	}
+}
+
+func TestSelectorsHonourExclusions(t *testing.T) {
+	candidates := CardsInScope(ScopeMajor)
+	var exclude []int
+	for cardID := range candidates {
+		if cardID != 7 {
+			exclude = append(exclude, cardID)
+		}
+	}
+
+	for _, name := range SelectorNames() {
+		selector, _ := GetSelector(name)
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				cardID := selector.Select(candidates, Selection{Mood: "anxious", Exclude: exclude}).CardID
+				if cardID != 7 {
+					t.Fatalf("Expected the only available card 7, got %d", cardID)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomSelectorIgnoresMood(t *testing.T) {
+	candidates := map[int]Card{
+		0: {ID: 0, MoodWeights: map[string]float64{"excited": 2}},
+		1: {ID: 1, MoodWeights: map[string]float64{"excited": 0}},
+	}
+
+	counts := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		counts[RandomSelector{}.Select(candidates, Selection{Mood: "excited"}).CardID]++
+	}
+
+	if counts[1] < 400 {
+		t.Errorf("Expected a zero-weight card to be drawn about half the time, got %d/1000", counts[1])
+	}
+}
+
+func TestWeightedSelectorFollowsMood(t *testing.T) {
+	candidates := map[int]Card{
+		0: {ID: 0, MoodWeights: map[string]float64{"excited": 2}},
+		1: {ID: 1, MoodWeights: map[string]float64{"excited": 0.5}},
+	}
+
+	for i := 0; i < 100; i++ {
+		if cardID := (WeightedSelector{}).Select(candidates, Selection{Mood: "excited", Recent: []int{0}}).CardID; cardID != 0 {
+			t.Fatalf("Expected the heavily weighted card regardless of history, got %d", cardID)
+		}
+	}
+}
+
+func TestHistoryAwareSelector(t *testing.T) {
+	candidates := CardsInScope(ScopeMajor)
+
+	t.Run("SkipsRecentCards", func(t *testing.T) {
+		recent := []int{0, 1, 2, 3, 4}
+		for i := 0; i < 100; i++ {
+			cardID := HistoryAwareSelector{}.Select(candidates, Selection{Mood: "curious", Recent: recent}).CardID
+			if contains(recent, cardID) {
+				t.Fatalf("Expected recent card %d to be skipped", cardID)
+			}
+		}
+	})
+
+	t.Run("PenalisesOlderHistory", func(t *testing.T) {
+		pair := map[int]Card{
+			0: {ID: 0, MoodWeights: map[string]float64{"excited": 1}},
+			1: {ID: 1, MoodWeights: map[string]float64{"excited": 1}},
+		}
+		recent := []int{5, 6, 7, 8, 9, 0, 0, 0}
+
+		counts := make(map[int]int)
+		for i := 0; i < 200; i++ {
+			counts[HistoryAwareSelector{}.Select(pair, Selection{Mood: "excited", Recent: recent}).CardID]++
+		}
+		if counts[1] != 200 {
+			t.Errorf("Expected the card absent from history every time, got %d/200", counts[1])
+		}
+	})
+
+	t.Run("FallsBackWhenHistoryCoversScope", func(t *testing.T) {
+		pair := map[int]Card{
+			0: {ID: 0, MoodWeights: map[string]float64{"excited": 1}},
+			1: {ID: 1, MoodWeights: map[string]float64{"excited": 1}},
+		}
+		cardID := HistoryAwareSelector{}.Select(pair, Selection{Mood: "excited", Recent: []int{0, 1}}).CardID
+		if _, exists := pair[cardID]; !exists {
+			t.Errorf("Expected a card from the candidates, got %d", cardID)
+		}
+	})
+}
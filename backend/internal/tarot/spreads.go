@@ -0,0 +1,151 @@
+package tarot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxSpreadPositions caps the size of custom layouts.
+const MaxSpreadPositions = 12
+
+// SpreadPosition is a named slot in a spread layout.
+type SpreadPosition struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Meaning string `json:"meaning"`
+}
+
+// Spread is a layout of positions that cards are dealt into.
+type Spread struct {
+	Type        string           `json:"type"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Positions   []SpreadPosition `json:"positions"`
+	Custom      bool             `json:"custom"`
+}
+
+// SpreadCard is a card dealt into a spread position.
+type SpreadCard struct {
+	Position    SpreadPosition `json:"position"`
+	CardID      int            `json:"card_id"`
+	Orientation Orientation    `json:"orientation"`
+}
+
+var Spreads = map[string]Spread{
+	"three-card": {
+		Type:        "three-card",
+		Name:        "Past, Present, Future",
+		Description: "A three-card timeline showing where you have been, where you stand and where things are heading.",
+		Positions: []SpreadPosition{
+			{Index: 0, Name: "Past", Meaning: "Influences and events that have shaped the situation"},
+			{Index: 1, Name: "Present", Meaning: "The current energy surrounding you"},
+			{Index: 2, Name: "Future", Meaning: "The likely direction if nothing changes"},
+		},
+	},
+	"situation-action-outcome": {
+		Type:        "situation-action-outcome",
+		Name:        "Situation, Action, Outcome",
+		Description: "A practical three-card spread for deciding what to do next.",
+		Positions: []SpreadPosition{
+			{Index: 0, Name: "Situation", Meaning: "What is really going on"},
+			{Index: 1, Name: "Action", Meaning: "The step that would serve you best"},
+			{Index: 2, Name: "Outcome", Meaning: "Where that action is likely to lead"},
+		},
+	},
+	"celtic-cross": {
+		Type:        "celtic-cross",
+		Name:        "Celtic Cross",
+		Description: "The classic ten-card spread for an in-depth look at a question.",
+		Positions: []SpreadPosition{
+			{Index: 0, Name: "Present", Meaning: "The heart of the matter"},
+			{Index: 1, Name: "Challenge", Meaning: "What crosses you or stands in the way"},
+			{Index: 2, Name: "Foundation", Meaning: "The root cause beneath the situation"},
+			{Index: 3, Name: "Recent Past", Meaning: "What is passing out of your life"},
+			{Index: 4, Name: "Crown", Meaning: "Your conscious goal or best possible outcome"},
+			{Index: 5, Name: "Near Future", Meaning: "What is coming into your life"},
+			{Index: 6, Name: "Self", Meaning: "Your attitude and how you see yourself"},
+			{Index: 7, Name: "Environment", Meaning: "The people and influences around you"},
+			{Index: 8, Name: "Hopes and Fears", Meaning: "What you hope for and what you dread"},
+			{Index: 9, Name: "Outcome", Meaning: "Where the situation is heading"},
+		},
+	},
+}
+
+// GetSpread looks up a built-in spread by type.
+func GetSpread(spreadType string) (Spread, bool) {
+	spread, exists := Spreads[spreadType]
+	return spread, exists
+}
+
+// BuiltInSpreads lists the built-in spreads, smallest layouts first.
+func BuiltInSpreads() []Spread {
+	spreads := make([]Spread, 0, len(Spreads))
+	for _, spread := range Spreads {
+		spreads = append(spreads, spread)
+	}
+	sort.Slice(spreads, func(i, j int) bool {
+		if len(spreads[i].Positions) != len(spreads[j].Positions) {
+			return len(spreads[i].Positions) < len(spreads[j].Positions)
+		}
+		return spreads[i].Type < spreads[j].Type
+	})
+	return spreads
+}
+
+// Validate checks that a spread has a type, a name and between one and
+// MaxSpreadPositions uniquely named positions.
+func (s Spread) Validate() error {
+	if strings.TrimSpace(s.Type) == "" || strings.TrimSpace(s.Name) == "" {
+		return errors.New("spread type and name are required")
+	}
+
+	if len(s.Positions) == 0 || len(s.Positions) > MaxSpreadPositions {
+		return fmt.Errorf("spread must have between 1 and %d positions", MaxSpreadPositions)
+	}
+
+	seen := make(map[string]bool)
+	for _, position := range s.Positions {
+		name := strings.ToLower(strings.TrimSpace(position.Name))
+		if name == "" {
+			return errors.New("every spread position needs a name")
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate spread position %q", position.Name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// DrawSpread deals one card into each position of the spread from a
+// single seeded source, so the same input always deals the same spread.
+// No card is dealt twice, and each card is reversed with the input's
+// reversal probability.
+func DrawSpread(spread Spread, in DrawInput) ([]SpreadCard, error) {
+	deck, selector, err := in.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := deck.CardsInScope(in.Scope)
+	if len(spread.Positions) > len(candidates) {
+		return nil, errors.New("spread has more positions than cards in the deck")
+	}
+
+	selection := in.Selection()
+	cards := make([]SpreadCard, 0, len(spread.Positions))
+	for _, position := range spread.Positions {
+		cardID := selector.Select(candidates, selection).CardID
+		selection.Exclude = append(selection.Exclude, cardID)
+		cards = append(cards, SpreadCard{
+			Position:    position,
+			CardID:      cardID,
+			Orientation: DrawOrientation(selection.Rand, in.ReversalProbability),
+		})
+	}
+
+	return cards, nil
+}
@@ -0,0 +1,144 @@
+package tarot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuiltInSpreads(t *testing.T) {
+	spreads := BuiltInSpreads()
+	if len(spreads) != len(Spreads) {
+		t.Fatalf("Expected %d built-in spreads, got %d", len(Spreads), len(spreads))
+	}
+
+	for _, spread := range spreads {
+		if err := spread.Validate(); err != nil {
+			t.Errorf("Built-in spread %s is invalid: %v", spread.Type, err)
+		}
+
+		for i, position := range spread.Positions {
+			if position.Index != i {
+				t.Errorf("Spread %s position %q has index %d, expected %d", spread.Type, position.Name, position.Index, i)
+			}
+		}
+	}
+
+	celticCross, exists := GetSpread("celtic-cross")
+	if !exists {
+		t.Fatal("Expected celtic-cross spread to exist")
+	}
+	if len(celticCross.Positions) != 10 {
+		t.Errorf("Expected Celtic Cross to have 10 positions, got %d", len(celticCross.Positions))
+	}
+}
+
+func TestSpreadValidate(t *testing.T) {
+	valid := Spread{Type: "weekly", Name: "Weekly", Positions: []SpreadPosition{{Name: "Monday"}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid spread, got %v", err)
+	}
+
+	t.Run("MissingName", func(t *testing.T) {
+		spread := Spread{Type: "weekly", Positions: valid.Positions}
+		if spread.Validate() == nil {
+			t.Error("Expected error for missing name")
+		}
+	})
+
+	t.Run("NoPositions", func(t *testing.T) {
+		spread := Spread{Type: "weekly", Name: "Weekly"}
+		if spread.Validate() == nil {
+			t.Error("Expected error for spread without positions")
+		}
+	})
+
+	t.Run("TooManyPositions", func(t *testing.T) {
+		spread := Spread{Type: "big", Name: "Big"}
+		for i := 0; i <= MaxSpreadPositions; i++ {
+			spread.Positions = append(spread.Positions, SpreadPosition{Index: i, Name: fmt.Sprintf("P%d", i)})
+		}
+		if spread.Validate() == nil {
+			t.Error("Expected error for spread with too many positions")
+		}
+	})
+
+	t.Run("DuplicatePositions", func(t *testing.T) {
+		spread := Spread{Type: "dup", Name: "Dup", Positions: []SpreadPosition{{Name: "Past"}, {Name: "past"}}}
+		if spread.Validate() == nil {
+			t.Error("Expected error for duplicate position names")
+		}
+	})
+}
+
+func TestDrawSpread(t *testing.T) {
+	spread, _ := GetSpread("celtic-cross")
+
+	t.Run("NoDuplicates", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			cards, err := DrawSpread(spread, DrawInput{Seed: NewSeed(), Scope: ScopeMajor, Selector: SelectorWeighted, Mood: "anxious", Question: "career", ReversalProbability: 0.5})
+			if err != nil {
+				t.Fatalf("DrawSpread returned error: %v", err)
+			}
+
+			if len(cards) != len(spread.Positions) {
+				t.Fatalf("Expected %d cards, got %d", len(spread.Positions), len(cards))
+			}
+
+			seen := make(map[int]bool)
+			for j, card := range cards {
+				if seen[card.CardID] {
+					t.Fatalf("Card %d dealt twice in one spread", card.CardID)
+				}
+				seen[card.CardID] = true
+
+				if card.Position.Name != spread.Positions[j].Name {
+					t.Errorf("Card dealt into position %q, expected %q", card.Position.Name, spread.Positions[j].Name)
+				}
+			}
+		}
+	})
+
+	t.Run("WholeDeck", func(t *testing.T) {
+		whole := Spread{Type: "all", Name: "All"}
+		for i := 0; i < len(CardsInScope(ScopeMajor)); i++ {
+			whole.Positions = append(whole.Positions, SpreadPosition{Index: i, Name: fmt.Sprintf("P%d", i)})
+		}
+
+		cards, err := DrawSpread(whole, DrawInput{Seed: NewSeed(), Scope: ScopeMajor, Selector: SelectorHistory, Recent: []int{0, 1, 2, 3, 4}})
+		if err != nil {
+			t.Fatalf("DrawSpread returned error: %v", err)
+		}
+
+		seen := make(map[int]bool)
+		for _, card := range cards {
+			seen[card.CardID] = true
+		}
+		if len(seen) != len(CardsInScope(ScopeMajor)) {
+			t.Errorf("Expected every Major Arcana card once, got %d distinct cards", len(seen))
+		}
+	})
+
+	t.Run("SameSeedSameSpread", func(t *testing.T) {
+		in := DrawInput{Seed: 42, Scope: ScopeFull, Selector: SelectorHistory, Mood: "hopeful", Recent: []int{3, 9}, ReversalProbability: 0.5}
+		first, _ := DrawSpread(spread, in)
+		for i := 0; i < 10; i++ {
+			again, _ := DrawSpread(spread, in)
+			for j := range first {
+				if again[j] != first[j] {
+					t.Fatalf("Position %d differs between draws with the same seed: %+v vs %+v", j, first[j], again[j])
+				}
+			}
+		}
+	})
+
+	t.Run("MorePositionsThanCards", func(t *testing.T) {
+		tooBig := Spread{Type: "huge", Name: "Huge"}
+		for i := 0; i <= len(CardsInScope(ScopeMajor)); i++ {
+			tooBig.Positions = append(tooBig.Positions, SpreadPosition{Index: i, Name: fmt.Sprintf("P%d", i)})
+		}
+
+		if _, err := DrawSpread(tooBig, DrawInput{Seed: NewSeed(), Scope: ScopeMajor, Selector: SelectorRandom}); err == nil {
+			t.Error("Expected error when spread is larger than the deck")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package tarot
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are common English words that carry no meaning for matching a
+// question to a card.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "above": true, "after": true, "again": true, "against": true,
+	"all": true, "am": true, "an": true, "and": true, "any": true, "are": true, "as": true,
+	"at": true, "be": true, "because": true, "been": true, "before": true, "being": true,
+	"below": true, "between": true, "both": true, "but": true, "by": true, "can": true,
+	"could": true, "did": true, "do": true, "does": true, "doing": true, "don": true,
+	"down": true, "during": true, "each": true, "few": true, "for": true, "from": true,
+	"further": true, "get": true, "going": true, "had": true, "has": true, "have": true,
+	"having": true, "he": true, "her": true, "here": true, "hers": true, "herself": true,
+	"him": true, "himself": true, "his": true, "how": true, "i": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "its": true, "itself": true, "just": true, "ll": true,
+	"m": true, "me": true, "might": true, "more": true, "most": true, "my": true, "myself": true,
+	"no": true, "nor": true, "not": true, "now": true, "of": true, "off": true, "on": true,
+	"once": true, "only": true, "or": true, "other": true, "our": true, "ours": true,
+	"ourselves": true, "out": true, "over": true, "own": true, "re": true, "s": true,
+	"same": true, "she": true, "should": true, "so": true, "some": true, "such": true,
+	"t": true, "than": true, "that": true, "the": true, "their": true, "theirs": true,
+	"them": true, "themselves": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "through": true, "to": true, "too": true, "under": true,
+	"until": true, "up": true, "ve": true, "very": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true, "who": true,
+	"whom": true, "why": true, "will": true, "with": true, "would": true, "you": true,
+	"your": true, "yours": true, "yourself": true, "yourselves": true,
+}
+
+// derivationalSuffixes are stripped, or replaced, after plurals and verb
+// endings. Only the first matching suffix is applied.
+var derivationalSuffixes = []struct{ suffix, replacement string }{
+	{"ational", "ate"},
+	{"ization", "ize"},
+	{"fulness", "ful"},
+	{"iveness", "ive"},
+	{"ousness", "ous"},
+	{"ivity", ""},
+	{"ation", "ate"},
+	{"ment", ""},
+	{"ness", ""},
+	{"ity", ""},
+	{"ful", ""},
+	{"ive", ""},
+	{"ly", ""},
+}
+
+// minStemLength stops suffix stripping from reducing short words to
+// meaningless fragments.
+const minStemLength = 3
+
+// Tokenize splits text into lowercase words, drops stop words and stems
+// what remains. Hyphenated keywords such as "new-beginnings" become
+// separate tokens.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(word) < 2 || stopWords[word] {
+			continue
+		}
+		tokens = append(tokens, Stem(word))
+	}
+	return tokens
+}
+
+// Stem reduces an English word to a crude root so that inflected forms
+// match: "relationships" and "relationship", or "changing", "changes" and
+// "change". It is a light suffix stripper, not a full Porter stemmer; what
+// matters is that questions and card keywords are stemmed the same way.
+func Stem(word string) string {
+	word = strings.ToLower(word)
+	if len(word) <= minStemLength {
+		return word
+	}
+
+	// Plurals
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies") && len(word) > minStemLength+2:
+		word = strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	// Verb endings
+	switch {
+	case strings.HasSuffix(word, "ied") && len(word) > minStemLength+2:
+		word = strings.TrimSuffix(word, "ied") + "y"
+	case strings.HasSuffix(word, "ing") && hasVowel(word[:len(word)-3]) && len(word)-3 >= minStemLength:
+		word = undouble(word[:len(word)-3])
+	case strings.HasSuffix(word, "ed") && hasVowel(word[:len(word)-2]) && len(word)-2 >= minStemLength:
+		word = undouble(word[:len(word)-2])
+	}
+
+	for _, rule := range derivationalSuffixes {
+		if strings.HasSuffix(word, rule.suffix) && len(word)-len(rule.suffix) >= minStemLength {
+			word = strings.TrimSuffix(word, rule.suffix) + rule.replacement
+			break
+		}
+	}
+
+	// A trailing "e" is dropped so that "love", "loved" and "loving" agree.
+	if strings.HasSuffix(word, "e") && len(word) > minStemLength {
+		word = strings.TrimSuffix(word, "e")
+	}
+
+	return word
+}
+
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
+
+// undouble turns "runn" into "run" and "stopp" into "stop", leaving the
+// doubled l, s and z of words like "fall", "pass" and "buzz" alone.
+func undouble(word string) string {
+	n := len(word)
+	if n >= 2 && word[n-1] == word[n-2] && !strings.ContainsAny(word[n-1:], "aeiouylsz") {
+		return word[:n-1]
+	}
+	return word
+}
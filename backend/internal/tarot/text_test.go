@@ -0,0 +1,119 @@
+package tarot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	groups := [][]string{
+		{"love", "loves", "loved", "loving"},
+		{"change", "changes", "changed", "changing"},
+		{"relationship", "relationships"},
+		{"career", "careers"},
+		{"create", "creation", "creative", "creativity"},
+		{"study", "studies", "studied", "studying"},
+		{"begin", "beginnings"},
+		{"stop", "stopped", "stopping"},
+	}
+
+	for _, group := range groups {
+		want := Stem(group[0])
+		for _, word := range group[1:] {
+			if got := Stem(word); got != want {
+				t.Errorf("Expected %q to stem like %q (%q), got %q", word, group[0], want, got)
+			}
+		}
+	}
+
+	for _, word := range []string{"see", "need", "thing", "fall", "pass", "focus"} {
+		if len(Stem(word)) < minStemLength {
+			t.Errorf("Stem(%q) = %q is shorter than %d letters", word, Stem(word), minStemLength)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("What should I do about my new-beginnings and relationships?")
+	want := []string{"new", "begin", "relationship"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if tokens := Tokenize("Is it the one for me?"); len(tokens) != 1 || tokens[0] != "one" {
+		t.Errorf("Expected stop words to be dropped, got %v", tokens)
+	}
+}
+
+func TestAnalyzeQuestionThemes(t *testing.T) {
+	analyzed := analyzeQuestion("Should I leave my job for a new venture with my partner?")
+
+	var themes []string
+	for _, theme := range analyzed.themes {
+		themes = append(themes, theme.Name)
+	}
+	for _, want := range []string{"love", "career", "change"} {
+		found := false
+		for _, theme := range themes {
+			found = found || theme == want
+		}
+		if !found {
+			t.Errorf("Expected theme %q in %v", want, themes)
+		}
+	}
+}
+
+func TestSemanticMatchingFavoursThemedCards(t *testing.T) {
+	lovers, _ := GetCard(6)
+	hermit, _ := GetCard(9)
+
+	question := "Will my marriage survive?"
+	if calculateCardScore(lovers, "", question) <= calculateCardScore(hermit, "", question) {
+		t.Error("Expected The Lovers to outscore The Hermit for a marriage question")
+	}
+}
+
+func TestParseLexicon(t *testing.T) {
+	if _, err := ParseLexicon(embeddedLexicon); err != nil {
+		t.Fatalf("Embedded lexicon is invalid: %v", err)
+	}
+
+	invalid := map[string]string{
+		"InvalidJSON":   "{",
+		"WeightTooLow":  `{"keyword_weight": 0.5, "meaning_weight": 1.1, "theme_weight": 1.1}`,
+		"UnnamedTheme":  `{"keyword_weight": 1.2, "meaning_weight": 1.1, "theme_weight": 1.1, "themes": [{"terms": ["x"]}]}`,
+		"DuplicateName": `{"keyword_weight": 1.2, "meaning_weight": 1.1, "theme_weight": 1.1, "themes": [{"name": "love"}, {"name": "love"}]}`,
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseLexicon([]byte(data)); err == nil {
+				t.Error("Expected lexicon to be rejected")
+			}
+		})
+	}
+}
+
+func TestLoadLexicon(t *testing.T) {
+	defer LoadLexicon("")
+
+	path := filepath.Join(t.TempDir(), "lexicon.json")
+	data := `{"keyword_weight": 1.2, "meaning_weight": 1.1, "theme_weight": 1.5, "themes": [{"name": "pets", "terms": ["dog", "cat"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write lexicon: %v", err)
+	}
+
+	if err := LoadLexicon(path); err != nil {
+		t.Fatalf("LoadLexicon returned error: %v", err)
+	}
+
+	analyzed := analyzeQuestion("Will my dogs be happy?")
+	if len(analyzed.themes) != 1 || analyzed.themes[0].Name != "pets" {
+		t.Errorf("Expected the custom pets theme, got %+v", analyzed.themes)
+	}
+
+	if err := LoadLexicon(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for a missing lexicon file")
+	}
+}
@@ -11,8 +11,10 @@ export function Auth({ onClose }: AuthProps) {
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
   const [isLoading, setIsLoading] = useState(false);
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+  const [code, setCode] = useState('');
 
-  const { login, register } = useAuth();
+  const { login, completeMfaLogin, register } = useAuth();
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -20,8 +22,14 @@ export function Auth({ onClose }: AuthProps) {
     setIsLoading(true);
 
     try {
-      if (isLogin) {
-        await login(email, password);
+      if (mfaToken) {
+        await completeMfaLogin(mfaToken, code);
+      } else if (isLogin) {
+        const pending = await login(email, password);
+        if (pending) {
+          setMfaToken(pending);
+          return;
+        }
       } else {
         await register(email, password);
       }
@@ -49,6 +57,24 @@ export function Auth({ onClose }: AuthProps) {
         </div>
 
         <form onSubmit={handleSubmit} className="space-y-4">
+          {mfaToken ? (
+          <div>
+            <label className="block text-sm font-medium text-purple-200 mb-2">
+              Authentication code
+            </label>
+            <input
+              type="text"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              className="w-full px-4 py-3 bg-white/10 border border-purple-500/30 rounded-lg text-white placeholder-purple-300 focus:outline-none focus:border-purple-400 focus:bg-white/20 transition-all"
+              placeholder="6-digit code or a recovery code"
+              autoComplete="one-time-code"
+              autoFocus
+              required
+            />
+          </div>
+          ) : (
+          <>
           <div>
             <label className="block text-sm font-medium text-purple-200 mb-2">
               Email
@@ -77,6 +103,8 @@ export function Auth({ onClose }: AuthProps) {
               minLength={8}
             />
           </div>
+          </>
+          )}
 
           {error && (
             <div className="bg-red-500/20 border border-red-500/30 rounded-lg p-3 text-red-200 text-sm">
@@ -89,7 +117,9 @@ export function Auth({ onClose }: AuthProps) {
             disabled={isLoading}
             className="w-full bg-gradient-to-r from-purple-600 to-indigo-600 hover:from-purple-700 hover:to-indigo-700 text-white font-medium py-3 rounded-lg transition-all duration-200 disabled:opacity-50 disabled:cursor-not-allowed"
           >
-            {isLoading 
+            {mfaToken
+              ? (isLoading ? 'Verifying...' : 'Verify')
+              : isLoading 
               ? (isLogin ? 'Signing In...' : 'Creating Account...') 
               : (isLogin ? 'Sign In' : 'Create Account')
             }
@@ -100,6 +130,8 @@ export function Auth({ onClose }: AuthProps) {
           <button
             onClick={() => {
               setIsLogin(!isLogin);
+              setMfaToken(null);
+              setCode('');
               setError('');
             }}
             className="text-purple-300 hover:text-white transition-colors"
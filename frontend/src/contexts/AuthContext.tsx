@@ -13,7 +13,9 @@ interface AuthContextType {
   user: User | null;
   isAuthenticated: boolean;
   isLoading: boolean;
-  login: (email: string, password: string) => Promise<void>;
+  // Resolves to an mfa token when a second step is needed
+  login: (email: string, password: string) => Promise<string | null>;
+  completeMfaLogin: (mfaToken: string, code: string) => Promise<void>;
   register: (email: string, password: string) => Promise<void>;
   logout: () => Promise<void>;
   refreshUser: () => Promise<void>;
@@ -68,12 +70,17 @@ export function AuthProvider({ children }: AuthProviderProps) {
   };
 
   const login = async (email: string, password: string) => {
-    try {
-      const response = await apiService.login(email, password);
-      setUser(response.user);
-    } catch (error) {
-      throw error;
+    const response = await apiService.login(email, password);
+    if ('mfa_required' in response) {
+      return response.mfa_token;
     }
+    setUser(response.user);
+    return null;
+  };
+
+  const completeMfaLogin = async (mfaToken: string, code: string) => {
+    const response = await apiService.completeMfaLogin(mfaToken, code);
+    setUser(response.user);
   };
 
   const register = async (email: string, password: string) => {
@@ -100,6 +107,7 @@ export function AuthProvider({ children }: AuthProviderProps) {
     isAuthenticated,
     isLoading,
     login,
+    completeMfaLogin,
     register,
     logout,
     refreshUser,
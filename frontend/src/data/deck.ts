@@ -0,0 +1,41 @@
+import type { TarotCard } from '../types/card';
+import riderWaite from '../../../backend/internal/tarot/decks/rider-waite.json';
+
+// A card as stored in the backend's deck files
+interface DeckCard {
+  id: number;
+  name: string;
+  number: string;
+  keywords: string[];
+  archetypes: string[];
+  elements: string[];
+  astrology?: string;
+  traditional_meaning: string;
+  shadow_aspects: string[];
+  light_aspects: string[];
+  imagery_description?: string;
+  colors?: string[];
+  symbols?: string[];
+  mood_weights: Record<string, number>;
+}
+
+/**
+ * The default deck, read from the backend's deck file so that the offline
+ * draw and the API use the same cards
+ */
+export const defaultDeck: TarotCard[] = (riderWaite.cards as DeckCard[]).map(card => ({
+  id: card.id,
+  name: card.name,
+  number: card.number,
+  keywords: card.keywords,
+  archetypes: card.archetypes,
+  elements: card.elements,
+  astrology: card.astrology,
+  traditionalMeaning: card.traditional_meaning,
+  shadowAspects: card.shadow_aspects,
+  lightAspects: card.light_aspects,
+  imageryDescription: card.imagery_description ?? '',
+  colors: card.colors ?? [],
+  symbols: card.symbols ?? [],
+  moodWeights: card.mood_weights,
+}));
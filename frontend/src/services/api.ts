@@ -12,7 +12,53 @@ class APIError extends Error {
   }
 }
 
+interface AuthTokens {
+  token: string;
+  refresh_token: string;
+  expires_at: string;
+}
+
+// Returned by login instead of tokens when the account has two-factor
+// authentication; completeMfaLogin finishes signing in
+export interface MfaChallenge {
+  mfa_required: true;
+  mfa_token: string;
+  expires_at: string;
+}
+
+// The card, mood and question come from the user's stored draw on draw_date.
+export interface EnhancedStreamParams {
+  draw_date: string;
+  persona?: string;
+  regenerate?: boolean;
+}
+
+export interface EnhancedInterpretation {
+  interpretation: string;
+  provider: string;
+  persona?: string;
+  template?: string;
+  cached: boolean;
+}
+
+// Splits one Server-Sent Event into its name and JSON payload
+function parseEvent(block: string): { event: string; data: any } {
+  let event = 'message';
+  const data: string[] = [];
+  for (const line of block.split('\n')) {
+    if (line.startsWith('event:')) {
+      event = line.slice(6).trim();
+    } else if (line.startsWith('data:')) {
+      data.push(line.slice(5).trim());
+    }
+  }
+  return { event, data: JSON.parse(data.join('\n') || 'null') };
+}
+
 class ApiService {
+  // A refresh token works once, so parallel requests share one refresh
+  private refreshing: Promise<boolean> | null = null;
+
   private getAuthHeaders(): HeadersInit {
     const token = localStorage.getItem('auth_token');
     return {
@@ -21,6 +67,54 @@ class ApiService {
     };
   }
 
+  private storeTokens(tokens: Partial<AuthTokens>): void {
+    if (tokens.token) {
+      localStorage.setItem('auth_token', tokens.token);
+    }
+    if (tokens.refresh_token) {
+      localStorage.setItem('refresh_token', tokens.refresh_token);
+    }
+  }
+
+  // Sends an authenticated request. An expired access token is refreshed
+  // once and the request retried.
+  private async authFetch(url: string, init: RequestInit = {}): Promise<Response> {
+    const response = await fetch(url, { ...init, headers: this.getAuthHeaders() });
+    if (response.status !== 401 || !localStorage.getItem('refresh_token')) {
+      return response;
+    }
+
+    if (!(await this.refreshSession())) {
+      return response;
+    }
+    return fetch(url, { ...init, headers: this.getAuthHeaders() });
+  }
+
+  private refreshSession(): Promise<boolean> {
+    if (!this.refreshing) {
+      this.refreshing = (async () => {
+        try {
+          const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ refresh_token: localStorage.getItem('refresh_token') }),
+          });
+          if (!response.ok) {
+            this.clearAuth();
+            return false;
+          }
+          this.storeTokens(await response.json());
+          return true;
+        } catch {
+          return false;
+        } finally {
+          this.refreshing = null;
+        }
+      })();
+    }
+    return this.refreshing;
+  }
+
   private async handleResponse<T>(response: Response): Promise<T> {
     if (!response.ok) {
       let errorMessage = `HTTP Error ${response.status}`;
@@ -45,57 +139,142 @@ class ApiService {
   }
 
   // Authentication
-  async register(email: string, password: string): Promise<{ token: string; user: any }> {
+  async register(email: string, password: string): Promise<AuthTokens & { user: any }> {
     const response = await fetch(`${API_BASE_URL}/auth/register`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ email, password }),
     });
 
-    const data = await this.handleResponse<{ token: string; user: any }>(response);
-    
-    // Store token in localStorage
-    if (data.token) {
-      localStorage.setItem('auth_token', data.token);
-    }
-    
+    const data = await this.handleResponse<AuthTokens & { user: any }>(response);
+    this.storeTokens(data);
     return data;
   }
 
-  async login(email: string, password: string): Promise<{ token: string; user: any }> {
+  async login(email: string, password: string): Promise<(AuthTokens & { user: any }) | MfaChallenge> {
     const response = await fetch(`${API_BASE_URL}/auth/login`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ email, password }),
     });
 
-    const data = await this.handleResponse<{ token: string; user: any }>(response);
-    
-    // Store token in localStorage
-    if (data.token) {
-      localStorage.setItem('auth_token', data.token);
+    const data = await this.handleResponse<(AuthTokens & { user: any }) | MfaChallenge>(response);
+    if (!('mfa_required' in data)) {
+      this.storeTokens(data);
     }
-    
     return data;
   }
 
+  // The code is from the user's authenticator app, or a recovery code
+  async completeMfaLogin(mfaToken: string, code: string): Promise<AuthTokens & { user: any }> {
+    const response = await fetch(`${API_BASE_URL}/auth/mfa/login`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ mfa_token: mfaToken, code }),
+    });
+
+    const data = await this.handleResponse<AuthTokens & { user: any }>(response);
+    this.storeTokens(data);
+    return data;
+  }
+
+  async setupMfa(): Promise<{ secret: string; provisioning_uri: string }> {
+    const response = await this.authFetch(`${API_BASE_URL}/auth/mfa/setup`, {
+      method: 'POST',
+    });
+
+    return this.handleResponse(response);
+  }
+
+  async enableMfa(code: string): Promise<{ mfa_enabled: boolean; recovery_codes: string[] }> {
+    const response = await this.authFetch(`${API_BASE_URL}/auth/mfa/enable`, {
+      method: 'POST',
+      body: JSON.stringify({ code }),
+    });
+
+    return this.handleResponse(response);
+  }
+
+  async disableMfa(password: string, code: string): Promise<{ mfa_enabled: boolean }> {
+    const response = await this.authFetch(`${API_BASE_URL}/auth/mfa/disable`, {
+      method: 'POST',
+      body: JSON.stringify({ password, code }),
+    });
+
+    return this.handleResponse(response);
+  }
+
   async logout(): Promise<void> {
     try {
-      await fetch(`${API_BASE_URL}/auth/logout`, {
-        method: 'POST',
-        headers: this.getAuthHeaders(),
-      });
+      const refreshToken = localStorage.getItem('refresh_token');
+      if (refreshToken) {
+        await fetch(`${API_BASE_URL}/auth/logout`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+      }
     } catch (error) {
       console.warn('Logout request failed:', error);
     } finally {
-      localStorage.removeItem('auth_token');
+      this.clearAuth();
     }
   }
 
+  async forgotPassword(email: string): Promise<{ message: string }> {
+    const response = await fetch(`${API_BASE_URL}/auth/forgot`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ email }),
+    });
+
+    return this.handleResponse(response);
+  }
+
+  // Resetting the password signs out every session, including this one
+  async resetPassword(token: string, password: string): Promise<{ message: string }> {
+    const response = await fetch(`${API_BASE_URL}/auth/reset`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token, password }),
+    });
+
+    const data = await this.handleResponse<{ message: string }>(response);
+    this.clearAuth();
+    return data;
+  }
+
+  async verifyEmail(token: string): Promise<{ message: string }> {
+    const response = await fetch(`${API_BASE_URL}/auth/verify`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token }),
+    });
+
+    return this.handleResponse(response);
+  }
+
+  async unlockAccount(token: string): Promise<{ message: string }> {
+    const response = await fetch(`${API_BASE_URL}/auth/unlock`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token }),
+    });
+
+    return this.handleResponse(response);
+  }
+
+  async resendVerification(): Promise<{ message: string }> {
+    const response = await this.authFetch(`${API_BASE_URL}/auth/verify/resend`, {
+      method: 'POST',
+    });
+
+    return this.handleResponse(response);
+  }
+
   async getProfile(): Promise<any> {
-    const response = await fetch(`${API_BASE_URL}/auth/profile`, {
+    const response = await this.authFetch(`${API_BASE_URL}/auth/profile`, {
       method: 'GET',
-      headers: this.getAuthHeaders(),
     });
 
     return this.handleResponse(response);
@@ -103,9 +282,8 @@ class ApiService {
 
   // Card draws
   async performDailyDraw(mood: string, question: string): Promise<{ success: boolean; card: any }> {
-    const response = await fetch(`${API_BASE_URL}/draws/daily`, {
+    const response = await this.authFetch(`${API_BASE_URL}/draws/daily`, {
       method: 'POST',
-      headers: this.getAuthHeaders(),
       body: JSON.stringify({ mood, question }),
     });
 
@@ -113,9 +291,8 @@ class ApiService {
   }
 
   async getDrawHistory(limit: number = 20): Promise<{ draws: any[]; count: number }> {
-    const response = await fetch(`${API_BASE_URL}/draws/history?limit=${limit}`, {
+    const response = await this.authFetch(`${API_BASE_URL}/draws/history?limit=${limit}`, {
       method: 'GET',
-      headers: this.getAuthHeaders(),
     });
 
     return this.handleResponse(response);
@@ -128,9 +305,8 @@ class ApiService {
     draws_today: number;
     limit: number;
   }> {
-    const response = await fetch(`${API_BASE_URL}/draws/today`, {
+    const response = await this.authFetch(`${API_BASE_URL}/draws/today`, {
       method: 'GET',
-      headers: this.getAuthHeaders(),
     });
 
     return this.handleResponse(response);
@@ -148,25 +324,73 @@ class ApiService {
 
   // Enhanced interpretations (premium)
   async getEnhancedInterpretation(
-    cardId: number, 
-    mood: string, 
-    question: string, 
-    drawDate?: string
-  ): Promise<{ interpretation: string }> {
-    const response = await fetch(`${API_BASE_URL}/interpretations/enhanced`, {
+    drawDate: string,
+    persona?: string,
+    regenerate?: boolean
+  ): Promise<EnhancedInterpretation> {
+    const response = await this.authFetch(`${API_BASE_URL}/interpretations/enhanced`, {
       method: 'POST',
-      headers: this.getAuthHeaders(),
-      body: JSON.stringify({ card_id: cardId, mood, question, draw_date: drawDate }),
+      body: JSON.stringify({ draw_date: drawDate, persona, regenerate }),
     });
 
     return this.handleResponse(response);
   }
 
+  // Streams an enhanced interpretation, passing text to onToken as it is
+  // written. The stream endpoint needs the Authorization header, which
+  // EventSource cannot send, so its events are read from a fetch body.
+  async streamEnhancedInterpretation(
+    params: EnhancedStreamParams,
+    onToken: (text: string) => void,
+    signal?: AbortSignal
+  ): Promise<EnhancedInterpretation> {
+    const query = new URLSearchParams();
+    for (const [key, value] of Object.entries(params)) {
+      if (value !== undefined && value !== '') {
+        query.set(key, String(value));
+      }
+    }
+
+    const response = await this.authFetch(`${API_BASE_URL}/interpretations/enhanced/stream?${query}`, {
+      method: 'GET',
+      signal,
+    });
+    if (!response.ok || !response.body) {
+      return this.handleResponse(response);
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += decoder.decode(value, { stream: true });
+
+      // Events are separated by a blank line
+      let end: number;
+      while ((end = buffer.indexOf('\n\n')) !== -1) {
+        const { event, data } = parseEvent(buffer.slice(0, end));
+        buffer = buffer.slice(end + 2);
+
+        if (event === 'token') {
+          onToken(data.text);
+        } else if (event === 'done') {
+          return data;
+        } else if (event === 'error') {
+          throw new APIError(data.message || 'Interpretation failed');
+        }
+      }
+    }
+    throw new APIError('Interpretation stream ended unexpectedly');
+  }
+
   // Subscriptions
   async createSubscription(): Promise<{ client_secret: string; message: string }> {
-    const response = await fetch(`${API_BASE_URL}/subscriptions/create`, {
+    const response = await this.authFetch(`${API_BASE_URL}/subscriptions/create`, {
       method: 'POST',
-      headers: this.getAuthHeaders(),
     });
 
     return this.handleResponse(response);
@@ -177,9 +401,8 @@ class ApiService {
     status: 'free' | 'premium';
     message?: string;
   }> {
-    const response = await fetch(`${API_BASE_URL}/subscriptions/status`, {
+    const response = await this.authFetch(`${API_BASE_URL}/subscriptions/status`, {
       method: 'GET',
-      headers: this.getAuthHeaders(),
     });
 
     return this.handleResponse(response);
@@ -206,6 +429,7 @@ class ApiService {
 
   clearAuth(): void {
     localStorage.removeItem('auth_token');
+    localStorage.removeItem('refresh_token');
   }
 }
 
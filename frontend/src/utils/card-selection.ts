@@ -1,5 +1,5 @@
 import type { TarotCard, UserContext, Mood } from '../types/card';
-import majorArcanaData from '../data/major-arcana.json';
+import { defaultDeck } from '../data/deck';
 
 /**
  * Weighted random selection from an array of items with weights
@@ -75,7 +75,7 @@ function calculateQuestionRelevance(question: string, keywords: string[]): numbe
  */
 export function selectCard(userContext: UserContext): TarotCard {
   const { mood, question } = userContext;
-  const cards = majorArcanaData.cards as TarotCard[];
+  const cards = defaultDeck;
   
   // Apply mood-based weighting
   const moodWeightedCards = cards.map(card => ({
@@ -6,6 +6,12 @@ import tailwindcss from '@tailwindcss/vite'
 // https://vite.dev/config/
 export default defineConfig({
   plugins: [react(), tailwindcss()],
+  server: {
+    fs: {
+      // Card data is read from the backend's deck files
+      allow: ['.', '../backend/internal/tarot/decks'],
+    },
+  },
   test: {
     globals: true,
     environment: 'jsdom',